@@ -6,10 +6,12 @@ import (
 	"fmt"
 	"log"
 	"math/big"
+	"strings"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/params"
+	tabsadjust "github.com/whilei/go-tabs-adjust"
 )
 
 var big1 = big.NewInt(1)
@@ -37,8 +39,14 @@ func main() {
 	rpcTarget := flag.String("target", "/tmp/geth.ipc", "URL for rpc endpoint")
 	startBlock := flag.Int64("start", 0, "start block (inclusive)")
 	endBlock := flag.Int64("end", 0, "end block (inclusive)")
+	adjustment := flag.String("tabs.adjustment", "sign:2049", "TABS adjustment algorithm: "+strings.Join(tabsadjust.Names, ", ")+", with parameters")
 	flag.Parse()
 
+	tabsAdjuster, err := tabsadjust.Parse(*adjustment)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
 	c, err := ethclient.Dial(*rpcTarget)
 	if err != nil {
 		log.Fatalln(err)
@@ -46,7 +54,7 @@ func main() {
 
 	ctx := context.Background()
 
-	lastBlockTABS := new(big.Int).Mul(big.NewInt(7000), big.NewInt(params.Ether))
+	lastBlock := tabsadjust.Genesis(new(big.Int).Mul(big.NewInt(7000), big.NewInt(params.Ether)))
 
 	for i := big.NewInt(*startBlock); i.Cmp(big.NewInt(*endBlock)) <= 0; i.Add(i, big1) {
 		b, err := c.BlockByNumber(ctx, i)
@@ -76,31 +84,17 @@ func main() {
 		}
 		tab.Add(tab, minerBal)
 
-		tabs := new(big.Int).Set(lastBlockTABS)
-
-		delta := big.NewInt(0)
-		tabCmp := tab.Cmp(lastBlockTABS)
-		if tabCmp > 0 {
-			delta.Div(lastBlockTABS, big.NewInt(2049))
-
-		} else if tabCmp < 0 {
-			delta.Div(lastBlockTABS, big.NewInt(-2049))
-
-		} else {
-			// noop on TABS (== lastBlockTABS)
-		}
-
-		tabs.Add(lastBlockTABS, delta)
+		block := lastBlock.Next(tabsAdjuster, tab)
 
 		fmt.Println(output{
 			blockNumber:    i,
 			blockHash:      b.Hash(),
 			blockTAB:       tab,
-			blockTABS:      tabs,
-			deltaMagnitude: tabCmp,
+			blockTABS:      block.TABS(),
+			deltaMagnitude: block.TABS().Cmp(lastBlock.TABS()),
 		}.String())
 
-		lastBlockTABS.Set(tabs)
+		lastBlock = block
 	}
 }
 
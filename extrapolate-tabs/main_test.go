@@ -3,10 +3,13 @@ package main
 import (
 	"fmt"
 	"math"
+	"math/big"
 	"testing"
 	"time"
 
+	"github.com/ethereum/go-ethereum/params"
 	"github.com/montanaflynn/stats"
+	tabsadjust "github.com/whilei/go-tabs-adjust"
 	exprand "golang.org/x/exp/rand"
 	"golang.org/x/image/colornames"
 	"gonum.org/v1/gonum/stat/distuv"
@@ -48,10 +51,26 @@ import (
 // 	}
 // }
 
+func toWei(ether float64) *big.Int {
+	wei, _ := new(big.Float).Mul(big.NewFloat(ether), big.NewFloat(params.Ether)).Int(nil)
+	return wei
+}
+
+func fromWei(wei *big.Int) float64 {
+	ether, _ := new(big.Float).Quo(new(big.Float).SetInt(wei), big.NewFloat(params.Ether)).Float64()
+	return ether
+}
+
 func TestTABSAdjustment1(t *testing.T) {
 
 	dataSize := 1000000
 
+	// The ceil-ratio adjustment, its numerator clamped to [-2, 1], over 2049.
+	tabsAdjuster, err := tabsadjust.Ceil(2049, -2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	runExperiment := func(rander distuv.Rander, name string, valsOper func(int, []float64, func()) bool) {
 		simulatedTABVals := []float64{}
 		for i := 0; i < dataSize; i++ {
@@ -64,6 +83,7 @@ func TestTABSAdjustment1(t *testing.T) {
 		med, _ := stats.Median(simulatedTABVals)
 		t.Logf("%s | mean: %v med: %v\n", name, mean, med)
 		tabs := med
+		tabsWei := toWei(tabs)
 
 		tabsPlottable := plotter.XYs{}
 		meansPlottable := plotter.XYs{}
@@ -76,16 +96,19 @@ func TestTABSAdjustment1(t *testing.T) {
 
 			// MODIFY TABS
 
-			ratio := tabs / f           // eg. 0.84, 1.02, 1.58, 2.37
-			ratio = math.Ceil(ratio)    // eg. 0, 1, 2, 3
-			deltaNumerator := 1 - ratio // eg. 1, 0, -1, -2
-			deltaDenominator := float64(2049)
-			// set upper and lower bounds
-			deltaNumerator = math.Min(deltaNumerator, 1)  // ceiling
-			deltaNumerator = math.Max(deltaNumerator, -2) // floor
-
-			adjustment := deltaNumerator / deltaDenominator
-			tabs += tabs * adjustment
+			// ratio := tabs / f           // eg. 0.84, 1.02, 1.58, 2.37
+			// ratio = math.Ceil(ratio)    // eg. 0, 1, 2, 3
+			// deltaNumerator := 1 - ratio // eg. 1, 0, -1, -2
+			// deltaDenominator := float64(2049)
+			// // set upper and lower bounds
+			// deltaNumerator = math.Min(deltaNumerator, 1)  // ceiling
+			// deltaNumerator = math.Max(deltaNumerator, -2) // floor
+			//
+			// adjustment := deltaNumerator / deltaDenominator
+			// tabs += tabs * adjustment
+			// =>
+			tabsWei = tabsAdjuster.Adjust(tabsadjust.Genesis(tabsWei), toWei(f))
+			tabs = fromWei(tabsWei)
 
 			// =>
 
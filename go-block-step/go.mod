@@ -4,7 +4,10 @@ go 1.16
 
 require (
 	github.com/montanaflynn/stats v0.6.6
+	github.com/whilei/go-tabs-adjust v0.0.0
 	golang.org/x/exp v0.0.0-20191002040644-a1355ae1e2c3
 	gonum.org/v1/gonum v0.9.3
 	gonum.org/v1/plot v0.9.0
 )
+
+replace github.com/whilei/go-tabs-adjust => ../go-tabs-adjust
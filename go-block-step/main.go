@@ -11,6 +11,7 @@ import (
 	"image/color"
 	"log"
 	"math"
+	"math/big"
 	"math/rand"
 	"os"
 	"path/filepath"
@@ -18,6 +19,7 @@ import (
 	"time"
 
 	"github.com/montanaflynn/stats"
+	tabsadjust "github.com/whilei/go-tabs-adjust"
 	exprand "golang.org/x/exp/rand"
 	"gonum.org/v1/gonum/stat/distuv"
 	"gonum.org/v1/plot"
@@ -735,14 +737,25 @@ func decideTime(tickElapses []int) (winnerIndex int) {
 	return -1
 }
 
+// tabsAdjuster is decideTDTABS' TABS adjustment: 127/128, 1 or 129/128 of the reference TABS,
+// as the balance is below, at or above it.
+var tabsAdjuster, _ = tabsadjust.Sign(128)
+
+// getTABS is the TABS of a block whose author has the balance, relative to the reference TABS (its parent's).
+// The adjustment is go-tabs-adjust's, on the values as wei.
 func getTABS(referenceTABS float64, balance float64) float64 {
-	tabs := float64(1)
-	if balance < referenceTABS {
-		tabs = float64(127) / float64(128)
-	} else if balance > referenceTABS {
-		tabs = float64(129) / float64(128)
+	reference := toWei(referenceTABS)
+	if reference.Sign() == 0 {
+		return 1
 	}
-	return tabs
+	tabs := tabsAdjuster.Adjust(tabsadjust.Genesis(reference), toWei(balance))
+	ratio, _ := new(big.Rat).SetFrac(tabs, reference).Float64()
+	return ratio
+}
+
+func toWei(ether float64) *big.Int {
+	wei, _ := new(big.Float).Mul(big.NewFloat(ether), big.NewFloat(1e18)).Int(nil)
+	return wei
 }
 
 // decideTDTABS is a naive estimation of TDTABS arbitration.
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"strconv"
+	"sync"
+
+	"gonum.org/v1/gonum/stat"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// batchMetrics are the minerResults fields aggregated over the replicates of a batch, in output order.
+var batchMetrics = []struct {
+	name  string
+	value func(r minerResults) float64
+}{
+	{"headI", func(r minerResults) float64 { return float64(r.HeadI) }},
+	{"headTABS", func(r minerResults) float64 { return float64(r.HeadTABS) }},
+	{"wins", func(r minerResults) float64 { return float64(r.Wins) }},
+	{"winRate", func(r minerResults) float64 { return r.WinRate }},
+	{"relativeRevenue", func(r minerResults) float64 { return r.RelativeRevenue }},
+	{"hashrateShare", func(r minerResults) float64 { return r.HashrateShare }},
+	{"tabRel", func(r minerResults) float64 { return r.TABRel }},
+	{"twinAdvantage", func(r minerResults) float64 {
+		if r.Twin == "" {
+			return math.NaN()
+		}
+		return r.TwinAdvantage
+	}},
+	{"kMean", func(r minerResults) float64 { return r.KMean }},
+	{"intervalsMeanSeconds", func(r minerResults) float64 { return r.IntervalsMeanSeconds }},
+	{"difficultiesRelGenesisMean", func(r minerResults) float64 { return r.DifficultiesRelGenesisMean }},
+	{"balance", func(r minerResults) float64 { return float64(r.Balance) }},
+	{"income", func(r minerResults) float64 { return r.Ledger.Income() }},
+	{"expenses", func(r minerResults) float64 { return r.Ledger.Expenses() }},
+	{"profit", func(r minerResults) float64 { return r.Ledger.Profit() }},
+	{"sold", func(r minerResults) float64 { return r.Ledger.Sold }},
+	{"fees", func(r minerResults) float64 { return r.Fees }},
+	{"rejected", func(r minerResults) float64 { return float64(r.Rejected) }},
+	{"objectiveArbitrations", func(r minerResults) float64 { return float64(r.ConsensusObjectiveArbitrations) }},
+	{"decisiveArbitrationRate", func(r minerResults) float64 { return r.DecisiveArbitrationRate }},
+	{"reorgMagnitudesMean", func(r minerResults) float64 { return r.ReorgMagnitudesMean }},
+	{"uncleRate", func(r minerResults) float64 { return r.UncleRate }},
+	{"hopsMean", func(r minerResults) float64 { return r.HopsMean }},
+	{"propagationSecondsMean", func(r minerResults) float64 { return r.PropagationSecondsMean }},
+}
+
+// metricSummary describes one metric over the replicates of a batch.
+// Replicates where the metric is undefined (NaN, eg. the mean reorg magnitude of a miner that never reorged)
+// are not counted. The confidence interval is the Student's t interval of the mean;
+// with fewer than two samples it collapses to the mean.
+type metricSummary struct {
+	N        int     `json:"n"`
+	Mean     float64 `json:"mean"`
+	SD       float64 `json:"sd"`
+	CI95Low  float64 `json:"ci95Low"`
+	CI95High float64 `json:"ci95High"`
+}
+
+func (s metricSummary) String() string {
+	return fmt.Sprintf("%0.3f±%0.3f", s.Mean, s.CI95High-s.Mean)
+}
+
+func summarizeMetric(samples []float64) metricSummary {
+	xs := make([]float64, 0, len(samples))
+	for _, x := range samples {
+		if !math.IsNaN(x) {
+			xs = append(xs, x)
+		}
+	}
+	s := metricSummary{N: len(xs)}
+	if s.N == 0 {
+		return s
+	}
+	if s.N == 1 {
+		s.Mean, s.CI95Low, s.CI95High = xs[0], xs[0], xs[0]
+		return s
+	}
+	s.Mean, s.SD = stat.MeanStdDev(xs, nil)
+	t := distuv.StudentsT{Mu: 0, Sigma: 1, Nu: float64(s.N - 1)}.Quantile(0.975)
+	half := t * s.SD / math.Sqrt(float64(s.N))
+	s.CI95Low, s.CI95High = s.Mean-half, s.Mean+half
+	return s
+}
+
+// minerSummary aggregates one miner's results over the replicates of a batch.
+// Miners are matched across replicates by index; a scenario builds the same miners for every seed.
+type minerSummary struct {
+	Index              int                      `json:"index"`
+	Address            string                   `json:"address"`
+	ConsensusAlgorithm string                   `json:"consensusAlgorithm"`
+	Strategy           string                   `json:"strategy"`
+	TxPolicy           string                   `json:"txPolicy,omitempty"`
+	SellPolicy         string                   `json:"sellPolicy,omitempty"`
+	TimestampPolicy    string                   `json:"timestampPolicy"`
+	HashrateRel        float64                  `json:"hashrateRel"`
+	Metrics            map[string]metricSummary `json:"metrics"`
+}
+
+// parallel calls fn(0) .. fn(n-1) on up to workers goroutines and returns the first error.
+func parallel(n, workers int, fn func(i int) error) error {
+	if workers < 1 {
+		workers = 1
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	jobs := make(chan int)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if err := fn(i); err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return firstErr
+}
+
+// runBatch runs n replicates of the scenario on up to workers goroutines.
+// Replicate i is seeded with sc.Seed+i, so a batch is reproducible as a whole and replicate by replicate.
+// results[i] holds the miner results of replicate i.
+func runBatch(sc *Scenario, n, workers int) (results [][]minerResults, err error) {
+	results = make([][]minerResults, n)
+	err = parallel(n, workers, func(i int) error {
+		miners, err := runReplicate(sc, sc.Seed+int64(i))
+		if err != nil {
+			return fmt.Errorf("replicate %d: %w", i, err)
+		}
+		results[i] = resultsOf(miners)
+		return nil
+	})
+	return results, err
+}
+
+// runReplicate runs the scenario headless (no drawing, no plots) with the given seed
+// and returns its miners.
+func runReplicate(sc *Scenario, seed int64) ([]*Miner, error) {
+	config := sc.config()
+	config.Seed = seed
+	sim := NewSimulation(config)
+
+	miners, err := sc.miners(sim, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := sim.run(miners, nil); err != nil {
+		return nil, err
+	}
+	return miners, nil
+}
+
+// summarizeBatch aggregates the replicates' results miner by miner.
+func summarizeBatch(results [][]minerResults) []minerSummary {
+	if len(results) == 0 {
+		return nil
+	}
+	summaries := make([]minerSummary, len(results[0]))
+	for i, r := range results[0] {
+		ms := minerSummary{
+			Index:              i,
+			Address:            r.Address,
+			ConsensusAlgorithm: r.ConsensusAlgorithm.String(),
+			Strategy:           r.Strategy.String(),
+			TxPolicy:           txPolicyName(r.TxPolicy),
+			SellPolicy:         sellPolicyName(r.SellPolicy),
+			TimestampPolicy:    r.TimestampPolicy.String(),
+			HashrateRel:        r.HashrateRel,
+			Metrics:            map[string]metricSummary{},
+		}
+		for _, metric := range batchMetrics {
+			xs := make([]float64, len(results))
+			for j, rs := range results {
+				xs[j] = metric.value(rs[i])
+			}
+			ms.Metrics[metric.name] = summarizeMetric(xs)
+		}
+		summaries[i] = ms
+	}
+	return summaries
+}
+
+// writeBatchCSV writes the summary in long form: one row per miner and metric.
+func writeBatchCSV(filename string, summaries []minerSummary) error {
+	records := [][]string{{"miner", "address", "consensus", "strategy", "tx_policy", "sell_policy", "timestamp_policy", "hashrate", "metric", "n", "mean", "sd", "ci95_low", "ci95_high"}}
+	for _, ms := range summaries {
+		for _, metric := range batchMetrics {
+			s := ms.Metrics[metric.name]
+			records = append(records, []string{
+				strconv.Itoa(ms.Index), ms.Address, ms.ConsensusAlgorithm, ms.Strategy, ms.TxPolicy, ms.SellPolicy, ms.TimestampPolicy, formatFloat(ms.HashrateRel),
+				metric.name, strconv.Itoa(s.N), formatFloat(s.Mean), formatFloat(s.SD), formatFloat(s.CI95Low), formatFloat(s.CI95High),
+			})
+		}
+	}
+	return writeCSV(filename, records)
+}
+
+func writeBatchJSON(filename string, summaries []minerSummary) error {
+	b, err := json.MarshalIndent(summaries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, b, os.ModePerm)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestSummarizeMetric(t *testing.T) {
+	s := summarizeMetric([]float64{1, 2, 3, math.NaN()})
+	if s.N != 3 || s.Mean != 2 || s.SD != 1 {
+		t.Fatalf("got %+v", s)
+	}
+	// t(0.975, 2) = 4.303
+	if half := s.CI95High - s.Mean; math.Abs(half-4.303/math.Sqrt(3)) > 1e-3 {
+		t.Fatalf("bad ci half width: %v", half)
+	}
+	if s.Mean-s.CI95Low != s.CI95High-s.Mean {
+		t.Fatalf("asymmetric ci: %+v", s)
+	}
+	if s := summarizeMetric([]float64{5}); s.CI95Low != 5 || s.CI95High != 5 {
+		t.Fatalf("got %+v", s)
+	}
+}
+
+func TestRunBatch(t *testing.T) {
+	sc := defaultScenario()
+	sc.Name = "batch"
+	sc.Seed = 7
+	sc.ConsensusAlgorithm = TDTABS.String()
+	sc.Population.Count = 4
+	sc.Globals.TickSamples = 10 * 60 * 30
+
+	parallel, err := runBatch(sc, 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, err := runBatch(sc, 3, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parallel, serial) {
+		t.Fatal("parallel and serial batches differ")
+	}
+	if reflect.DeepEqual(parallel[0], parallel[1]) {
+		t.Fatal("replicates are identical")
+	}
+
+	summary := summarizeBatch(parallel)
+	if len(summary) != 4 {
+		t.Fatalf("got %d miner summaries, want 4", len(summary))
+	}
+	for _, ms := range summary {
+		s := ms.Metrics["winRate"]
+		if s.N != 3 || s.CI95Low > s.Mean || s.CI95High < s.Mean {
+			t.Errorf("%s: bad win rate summary %+v", ms.Address, s)
+		}
+	}
+}
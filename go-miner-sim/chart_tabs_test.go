@@ -15,7 +15,9 @@ func TestPlotTABSDescending(t *testing.T) {
 	p.Title.Text = "TABS Adjustment Algorithms: Constant Numerator vs. Consecutive-Falls Stepping Numerator"
 	p.Legend.Top = true
 
-	tabsAdjustmentDenominator = 4096
+	config := DefaultSimulationConfig()
+	config.TabsAdjustmentDenominator = 4096
+	sim := NewSimulation(config)
 
 	data := plotter.XYs{}
 	dataStep := plotter.XYs{}
@@ -27,7 +29,7 @@ func TestPlotTABSDescending(t *testing.T) {
 
 	sequentialFalls := int64(0)
 	for i := int64(1); i <= 4*60*24; i++ {
-		tabs = getTABS(tabs, localTAB)
+		tabs = sim.getTABS(tabs, localTAB)
 		data = append(data, plotter.XY{X: float64(i), Y: float64(tabs)})
 
 		if localTAB >= tabsStep {
@@ -35,7 +37,7 @@ func TestPlotTABSDescending(t *testing.T) {
 		} else {
 			sequentialFalls++
 		}
-		tabsStep = getTABS_step(tabsStep, sequentialFalls, localTAB)
+		tabsStep = sim.getTABS_step(tabsStep, sequentialFalls, localTAB)
 		dataStep = append(dataStep, plotter.XY{X: float64(i), Y: float64(tabsStep)})
 	}
 
@@ -63,7 +65,9 @@ func TestPlotConsensusScore(t *testing.T) {
 	p.Title.Text = "TDTABS Adjustment Algorithm Experiment: 51% Stake Attack, < 50% Miner Attack"
 	p.Legend.Top = true
 
-	tabsAdjustmentDenominator = 4096
+	config := DefaultSimulationConfig()
+	config.TabsAdjustmentDenominator = 4096
+	sim := NewSimulation(config)
 
 	data := plotter.XYs{}
 	dataStep := plotter.XYs{}
@@ -76,7 +80,7 @@ func TestPlotConsensusScore(t *testing.T) {
 	sequentialFalls := int64(0)
 	for i := int64(1); i <= 4*60*24; i++ {
 
-		tabs = getTABS(tabs, localTAB)
+		tabs = sim.getTABS(tabs, localTAB)
 		data = append(data, plotter.XY{X: float64(i), Y: float64(tabs)})
 
 		if localTAB >= tabsStep {
@@ -84,7 +88,7 @@ func TestPlotConsensusScore(t *testing.T) {
 		} else {
 			sequentialFalls++
 		}
-		tabsStep = getTABS_step(tabsStep, sequentialFalls, localTAB)
+		tabsStep = sim.getTABS_step(tabsStep, sequentialFalls, localTAB)
 		dataStep = append(dataStep, plotter.XY{X: float64(i), Y: float64(tabsStep)})
 
 	}
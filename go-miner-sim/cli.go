@@ -0,0 +1,531 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	tabsadjust "github.com/whilei/go-tabs-adjust"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `Usage: go-miner-sim <command> [flags]
+
+Commands:
+	run	Run a single scenario and write miner logs and plots.
+	batch	Run seeded replicates of a scenario in parallel and summarize the miners' results.
+	sweep	Run replicates over a grid of parameters and write a results table and heatmaps.
+	doublespend	Run a double-spend attack over a grid of attacker shares and compare its success rate with the analytic curves.
+
+Use "go-miner-sim <command> -h" for the flags of a command.
+`)
+}
+
+// scenarioFlags are the flags that describe a scenario, shared by the commands that run one.
+type scenarioFlags struct {
+	fs *flag.FlagSet
+
+	scenarioPath  *string
+	name          *string
+	consensus     *string
+	denominator   *int64
+	adjustment    *string
+	miners        *int64
+	dist          *string
+	latency       *float64
+	latencyDist   *string
+	bandwidth     *float64
+	validation    *float64
+	validationGas *float64
+	duration      *time.Duration
+	attacker      *bool
+	seed          *int64
+	engine        *string
+	difficulty    *string
+	difficultyAt  *int64
+	mempool       *bool
+	mempoolFit    *string
+	txPolicy      *string
+	economics     *bool
+	electricity   *float64
+	settlePeriod  *time.Duration
+	sellPolicy    *string
+	strategy      *string
+	timestamp     *string
+	partition     *string
+
+	topology       *string
+	topologyDegree *int
+	topologyRewire *float64
+	topologyEdges  *string
+}
+
+func addScenarioFlags(fs *flag.FlagSet) *scenarioFlags {
+	def := DefaultSimulationConfig()
+	return &scenarioFlags{
+		fs:            fs,
+		scenarioPath:  fs.String("scenario", "", "scenario file (.yaml, .yml, .json); other flags given explicitly override it"),
+		name:          fs.String("name", "", "scenario name (default: derived from consensus and denominator, eg. tdtabs_128)"),
+		consensus:     fs.String("consensus", TD.String(), "consensus algorithm: "+strings.Join(forkChoiceNames, ", ")),
+		denominator:   fs.Int64("tabs.denominator", def.TabsAdjustmentDenominator, "TABS adjustment denominator"),
+		adjustment:    fs.String("tabs.adjustment", "", "TABS adjustment algorithm for all miners, replacing the consensus algorithm's: "+strings.Join(tabsadjust.Names, ", ")+", with parameters, eg. ema:64"),
+		miners:        fs.Int64("miners", def.CountMiners, "number of miners"),
+		dist:          fs.String("hashrate.dist", def.HashrateDist.String(), "miner hashrate distribution: equal, longtail"),
+		latency:       fs.Float64("latency", def.LatencySeconds, "block propagation latency (seconds)"),
+		latencyDist:   fs.String("latency.dist", "", "per-link latency distribution, replacing -latency: <seconds>, uniform:<min>:<max>, lognormal:<median>:<sigma>, empirical:<file>"),
+		bandwidth:     fs.Float64("bandwidth", 0, "link bandwidth (bytes/second); bigger blocks propagate slower (0: unlimited)"),
+		validation:    fs.Float64("validation", 0, "per-hop block validation time (seconds)"),
+		validationGas: fs.Float64("validation.gas", 0, "per-hop block validation speed (gas/second), added to -validation (0: off)"),
+		duration:      fs.Duration("duration", time.Duration(def.TickSamples/def.TicksPerSecond)*time.Second, "simulated duration"),
+		attacker:      fs.Bool("attacker", false, "install the rich, withholding attack miner"),
+		seed:          fs.Int64("seed", 0, "random seed; runs with the same seed and scenario are identical (0: seed from the clock)"),
+		engine:        fs.String("engine", EngineTick, "simulation engine: tick, event"),
+		difficulty:    fs.String("difficulty", DifficultySimple, "difficulty calculator: simple, byzantium, constantinople, london, etc, constant"),
+		difficultyAt:  fs.Int64("difficulty.start", 0, "chain block number at genesis, for the difficulty bombs"),
+		mempool:       fs.Bool("mempool", false, "model the transaction pool (fitted to Ethereum Classic) instead of drawing each height's pool TAB"),
+		mempoolFit:    fs.String("mempool.fit", "", "directory of go-tabs-scraper block files to fit the mempool model to; implies -mempool"),
+		txPolicy:      fs.String("tx.policy", FeeGreedy.String(), "population miners' transaction selection: "+strings.Join(txPolicyNames, ", ")+", mix:<balance weight>"),
+		economics:     fs.Bool("economics", false, "charge the miners' electricity and settle their balances periodically"),
+		electricity:   fs.Float64("economics.electricity", 0, "electricity cost as a fraction of expected block rewards; implies -economics (0: the default, 0.7)"),
+		settlePeriod:  fs.Duration("economics.period", 0, "how often miners settle; implies -economics (0: the default, 1h)"),
+		strategy:      fs.String("strategy", Honest.String(), "population miners' strategy: "+strings.Join(strategyNames, ", ")+", with parameters, eg. stubborn:LF"),
+		sellPolicy:    fs.String("sell.policy", Hold.String(), "population miners' sell policy: "+strings.Join(sellPolicyNames, ", ")+", sell:<fraction>, keep:<amount>"),
+		timestamp:     fs.String("timestamp.policy", HonestTimestamp.String(), "population miners' timestamp policy: "+strings.Join(timestampPolicyNames, ", ")+", earliest:<seconds>, offset:<seconds>"),
+		partition:     fs.String("partition", "", "split the miners in two at a time for a duration, eg. 1h:10m (a duration of 0 never heals)"),
+
+		topology:       fs.String("topology", TopologyCoinFlip, "neighbor graph: coinflip, complete, erdos-renyi, random-regular, small-world, scale-free, file"),
+		topologyDegree: fs.Int("topology.degree", 4, "peers per miner (random-regular, small-world, scale-free)"),
+		topologyRewire: fs.Float64("topology.rewire", 0.1, "small-world rewiring probability"),
+		topologyEdges:  fs.String("topology.edges", "", "edge list file for the file topology: lines of <a> <b> [latencySeconds]"),
+	}
+}
+
+// scenario builds the scenario described by the (parsed) flags.
+func (f *scenarioFlags) scenario() (*Scenario, error) {
+	def := DefaultSimulationConfig()
+
+	sc := defaultScenario()
+	if *f.scenarioPath != "" {
+		var err error
+		sc, err = loadScenario(*f.scenarioPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	set := map[string]bool{}
+	f.fs.Visit(func(fl *flag.Flag) {
+		set[fl.Name] = true
+	})
+	if *f.scenarioPath == "" {
+		// Without a scenario file the flags describe everything, defaults included.
+		f.fs.VisitAll(func(fl *flag.Flag) {
+			set[fl.Name] = true
+		})
+		set["name"] = *f.name != ""
+	}
+
+	if set["consensus"] {
+		sc.ConsensusAlgorithm = *f.consensus
+	}
+	if set["tabs.denominator"] {
+		sc.Globals.TabsAdjustmentDenominator = *f.denominator
+	}
+	if set["tabs.adjustment"] {
+		sc.Globals.TabsAdjustment = *f.adjustment
+	}
+	if set["miners"] || set["hashrate.dist"] {
+		if sc.Population == nil {
+			sc.Population = &ScenarioPopulation{Count: def.CountMiners, HashrateDist: def.HashrateDist.String()}
+		}
+		if set["miners"] {
+			sc.Population.Count = *f.miners
+		}
+		if set["hashrate.dist"] {
+			sc.Population.HashrateDist = *f.dist
+		}
+	}
+	if set["latency"] {
+		sc.Globals.LatencySeconds = f.latency
+	}
+	if set["latency.dist"] {
+		sc.Globals.LatencyDist = *f.latencyDist
+	}
+	if set["bandwidth"] {
+		sc.Globals.BandwidthBytesPerSecond = *f.bandwidth
+	}
+	if set["validation"] {
+		sc.Globals.ValidationSeconds = *f.validation
+	}
+	if set["validation.gas"] {
+		sc.Globals.ValidationGasPerSecond = *f.validationGas
+	}
+	if set["duration"] {
+		tps := sc.Globals.TicksPerSecond
+		if tps == 0 {
+			tps = def.TicksPerSecond
+		}
+		sc.Globals.TickSamples = tps * int64(f.duration.Seconds())
+	}
+	if set["seed"] {
+		sc.Seed = *f.seed
+	}
+	if set["engine"] {
+		sc.Globals.Engine = *f.engine
+	}
+	if set["difficulty"] {
+		sc.Globals.Difficulty = *f.difficulty
+	}
+	if set["difficulty.start"] {
+		sc.Globals.DifficultyStartNumber = *f.difficultyAt
+	}
+	if set["mempool"] || set["mempool.fit"] {
+		switch {
+		case *f.mempool || *f.mempoolFit != "":
+			if sc.Globals.Mempool == nil {
+				sc.Globals.Mempool = &ScenarioMempool{}
+			}
+			if *f.mempoolFit != "" {
+				sc.Globals.Mempool.Fit = *f.mempoolFit
+			}
+		default:
+			sc.Globals.Mempool = nil
+		}
+	}
+	if set["tx.policy"] && sc.Population != nil {
+		sc.Population.TxPolicy = *f.txPolicy
+	}
+	if set["economics"] || set["economics.electricity"] || set["economics.period"] {
+		switch {
+		case *f.economics || *f.electricity != 0 || *f.settlePeriod != 0:
+			if sc.Globals.Economics == nil {
+				sc.Globals.Economics = &ScenarioEconomics{}
+			}
+			if *f.electricity != 0 {
+				sc.Globals.Economics.ElectricityCostRatio = *f.electricity
+			}
+			if *f.settlePeriod != 0 {
+				sc.Globals.Economics.PeriodSeconds = f.settlePeriod.Seconds()
+			}
+		default:
+			sc.Globals.Economics = nil
+		}
+	}
+	if set["strategy"] && sc.Population != nil {
+		sc.Population.Strategy = *f.strategy
+	}
+	if set["sell.policy"] && sc.Population != nil {
+		sc.Population.SellPolicy = *f.sellPolicy
+	}
+	if set["timestamp.policy"] && sc.Population != nil {
+		sc.Population.TimestampPolicy = *f.timestamp
+	}
+	if set["partition"] && *f.partition != "" {
+		e, err := parsePartition(*f.partition)
+		if err != nil {
+			return nil, err
+		}
+		sc.Globals.NetworkEvents = append(sc.Globals.NetworkEvents, e)
+	}
+	if set["topology"] || set["topology.degree"] || set["topology.rewire"] || set["topology.edges"] {
+		t := ScenarioTopology{Kind: TopologyCoinFlip, Degree: *f.topologyDegree, Rewire: *f.topologyRewire}
+		if sc.Globals.Topology != nil {
+			t = *sc.Globals.Topology
+		}
+		if set["topology"] {
+			t.Kind = *f.topology
+		}
+		if set["topology.degree"] {
+			t.Degree = *f.topologyDegree
+		}
+		if set["topology.rewire"] {
+			t.Rewire = *f.topologyRewire
+		}
+		if set["topology.edges"] {
+			t.EdgesFile = *f.topologyEdges
+		}
+		sc.Globals.Topology = &t
+	}
+	if *f.attacker {
+		sc.Miners = append(sc.Miners, attackMinerSpec)
+	}
+	if set["name"] {
+		sc.Name = *f.name
+	} else if *f.scenarioPath == "" {
+		algo, _ := parseConsensusAlgorithm(sc.ConsensusAlgorithm)
+		switch algo {
+		case TD:
+			sc.Name = "td"
+		case TDTABS:
+			sc.Name = fmt.Sprintf("tdtabs_%d", *f.denominator)
+		case TDTABS_step:
+			sc.Name = fmt.Sprintf("tdtabs_%d_tabsStep", *f.denominator)
+		default:
+			sc.Name = strings.ToLower(algo.String())
+		}
+	}
+
+	if err := sc.validate(); err != nil {
+		return nil, err
+	}
+	return sc, nil
+}
+
+// parsePartition parses a -partition value, <at>:<duration>.
+func parsePartition(s string) (ScenarioNetworkEvent, error) {
+	e := ScenarioNetworkEvent{Kind: NetworkPartition}
+	fields := strings.Split(s, ":")
+	if len(fields) != 2 {
+		return e, fmt.Errorf("partition %q: want <at>:<duration>, eg. 1h:10m", s)
+	}
+	at, err := time.ParseDuration(fields[0])
+	if err != nil {
+		return e, fmt.Errorf("partition %q: %w", s, err)
+	}
+	duration, err := time.ParseDuration(fields[1])
+	if err != nil {
+		return e, fmt.Errorf("partition %q: %w", s, err)
+	}
+	e.AtSeconds, e.DurationSeconds = at.Seconds(), duration.Seconds()
+	return e, nil
+}
+
+func cmdRun(args []string) error {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	sf := addScenarioFlags(fs)
+	outRoot := fs.String("out", "out", "root output directory; results are written to <out>/<name>")
+	fs.Parse(args)
+
+	sc, err := sf.scenario()
+	if err != nil {
+		return err
+	}
+	return sc.run(*outRoot, log.Println)
+}
+
+func cmdBatch(args []string) error {
+	fs := flag.NewFlagSet("batch", flag.ExitOnError)
+	sf := addScenarioFlags(fs)
+	outRoot := fs.String("out", "out", "root output directory; the summary is written to <out>/<name>")
+	replicates := fs.Int("n", 20, "number of replicates; replicate i uses seed <seed>+i")
+	workers := fs.Int("workers", runtime.NumCPU(), "number of replicates run in parallel")
+	fs.Parse(args)
+
+	sc, err := sf.scenario()
+	if err != nil {
+		return err
+	}
+	if sc.Seed == 0 {
+		sc.Seed = time.Now().UnixNano()
+	}
+	log.Println("Batch", sc.Name, "replicates", *replicates, "seed", sc.Seed)
+
+	results, err := runBatch(sc, *replicates, *workers)
+	if err != nil {
+		return err
+	}
+	summary := summarizeBatch(results)
+
+	outDir := filepath.Join(*outRoot, sc.Name)
+	if err := os.MkdirAll(outDir, os.ModePerm); err != nil {
+		return err
+	}
+	if err := writeBatchCSV(filepath.Join(outDir, "batch_summary.csv"), summary); err != nil {
+		return err
+	}
+	if err := writeBatchJSON(filepath.Join(outDir, "batch_summary.json"), summary); err != nil {
+		return err
+	}
+	for _, ms := range summary {
+		log.Printf("a=%s winr=%s k_mean=%s reorgs.mag_mean=%s\n", ms.Address, ms.Metrics["winRate"], ms.Metrics["kMean"], ms.Metrics["reorgMagnitudesMean"])
+		if ms.Strategy != Honest.String() {
+			log.Printf("a=%s strategy=%s revenue.rel=%s hashrate.share=%s tab.rel=%s twin.advantage=%s\n", ms.Address, ms.Strategy, ms.Metrics["relativeRevenue"], ms.Metrics["hashrateShare"], ms.Metrics["tabRel"], ms.Metrics["twinAdvantage"])
+		}
+		if ms.TimestampPolicy != HonestTimestamp.String() {
+			log.Printf("a=%s timestamp_policy=%s revenue.rel=%s hashrate.share=%s rejected=%s\n", ms.Address, ms.TimestampPolicy, ms.Metrics["relativeRevenue"], ms.Metrics["hashrateShare"], ms.Metrics["rejected"])
+		}
+		if ms.TxPolicy != "" {
+			log.Printf("a=%s tx_policy=%s fees=%s objective_arbs=%s\n", ms.Address, ms.TxPolicy, ms.Metrics["fees"], ms.Metrics["objectiveArbitrations"])
+		}
+		if ms.SellPolicy != "" {
+			log.Printf("a=%s sell_policy=%s profit=%s sold=%s balance=%s\n", ms.Address, ms.SellPolicy, ms.Metrics["profit"], ms.Metrics["sold"], ms.Metrics["balance"])
+		}
+	}
+	return nil
+}
+
+func cmdSweep(args []string) error {
+	def := DefaultSimulationConfig()
+
+	fs := flag.NewFlagSet("sweep", flag.ExitOnError)
+	scenarioPath := fs.String("scenario", "", "base scenario file (.yaml, .yml, .json); swept values override it")
+	name := fs.String("name", "sweep", "sweep name; results are written to <out>/<name>")
+	outRoot := fs.String("out", "out", "root output directory")
+	consensus := fs.String("consensus", "TD,TDTABS", "comma-separated consensus algorithms")
+	denominators := fs.String("tabs.denominator", "64,128,4096", "TABS adjustment denominators (list or start:stop:step)")
+	latencies := fs.String("latency", "0.5,1,2", "block propagation latencies in seconds (list or start:stop:step)")
+	miners := fs.String("miners", strconv.FormatInt(def.CountMiners, 10), "miner counts (list or start:stop:step)")
+	neighborRates := fs.String("neighbor.rate", formatFloat(def.MinerNeighborRate), "miner neighbor rates (list or start:stop:step)")
+	postpones := fs.String("receive.postpone", formatFloat(def.ReceivePostponeSeconds), "received block postponements in seconds (list or start:stop:step)")
+	duration := fs.Duration("duration", time.Duration(def.TickSamples/def.TicksPerSecond)*time.Second, "simulated duration of each replicate")
+	replicates := fs.Int("n", 5, "number of replicates per cell; replicate i uses seed <seed>+i")
+	workers := fs.Int("workers", runtime.NumCPU(), "number of replicates run in parallel")
+	seed := fs.Int64("seed", 0, "base random seed (0: seed from the clock)")
+	engine := fs.String("engine", "", "simulation engine: tick, event (default: the base scenario's, or tick)")
+	fs.Parse(args)
+
+	base := defaultScenario()
+	if *scenarioPath != "" {
+		var err error
+		base, err = loadScenario(*scenarioPath)
+		if err != nil {
+			return err
+		}
+	}
+	base.Name = *name
+	tps := base.Globals.TicksPerSecond
+	if tps == 0 {
+		tps = def.TicksPerSecond
+	}
+	base.Globals.TickSamples = tps * int64(duration.Seconds())
+	if *seed != 0 {
+		base.Seed = *seed
+	}
+	if base.Seed == 0 {
+		base.Seed = time.Now().UnixNano()
+	}
+	if *engine != "" {
+		base.Globals.Engine = *engine
+	}
+
+	grid := sweepGrid{}
+	for _, c := range strings.Split(*consensus, ",") {
+		grid.Consensus = append(grid.Consensus, strings.TrimSpace(c))
+	}
+	var err error
+	if grid.TabsAdjustmentDenominator, err = parseIntGrid(*denominators); err != nil {
+		return fmt.Errorf("tabs.denominator: %w", err)
+	}
+	if grid.LatencySeconds, err = parseGrid(*latencies); err != nil {
+		return fmt.Errorf("latency: %w", err)
+	}
+	if grid.CountMiners, err = parseIntGrid(*miners); err != nil {
+		return fmt.Errorf("miners: %w", err)
+	}
+	if grid.MinerNeighborRate, err = parseGrid(*neighborRates); err != nil {
+		return fmt.Errorf("neighbor.rate: %w", err)
+	}
+	if grid.ReceivePostponeSeconds, err = parseGrid(*postpones); err != nil {
+		return fmt.Errorf("receive.postpone: %w", err)
+	}
+	for _, cell := range grid.cells() {
+		if err := cell.scenario(base).validate(); err != nil {
+			return fmt.Errorf("%s: %w", cell, err)
+		}
+	}
+
+	log.Println("Sweep", base.Name, "cells", len(grid.cells()), "replicates", *replicates, "seed", base.Seed)
+	rows, err := runSweep(base, grid, *replicates, *workers, log.Println)
+	if err != nil {
+		return err
+	}
+
+	outDir := filepath.Join(*outRoot, base.Name)
+	if err := os.MkdirAll(outDir, os.ModePerm); err != nil {
+		return err
+	}
+	if err := writeSweepCSV(filepath.Join(outDir, "sweep.csv"), rows); err != nil {
+		return err
+	}
+	if err := writeSweepSummaryCSV(filepath.Join(outDir, "sweep_summary.csv"), rows); err != nil {
+		return err
+	}
+	return plotSweepHeatmaps(outDir, grid, rows)
+}
+
+func cmdDoubleSpend(args []string) error {
+	fs := flag.NewFlagSet("doublespend", flag.ExitOnError)
+	scenarioPath := fs.String("scenario", "", "base scenario file (.yaml, .yml, .json) of the honest miners; the attacker is added to it")
+	name := fs.String("name", "doublespend", "name; results are written to <out>/<name>")
+	outRoot := fs.String("out", "out", "root output directory")
+	consensus := fs.String("consensus", "TD,TDTABS", "comma-separated consensus algorithms")
+	hashrateShares := fs.String("hashrate.share", "0.1:0.45:0.05", "attacker shares of the hashrate (list or start:stop:step)")
+	balanceShares := fs.String("balance.share", "0.1,0.5", "attacker shares of the balances (list or start:stop:step)")
+	confirmations := fs.Int64("confirmations", 6, "confirmations the merchant waits for")
+	giveUp := fs.Int64("giveup", 50, "how many blocks behind the public chain the attacker gives up")
+	duration := fs.Duration("duration", 2*time.Hour, "simulated duration of each replicate; an attack unreleased by then failed")
+	replicates := fs.Int("n", 20, "number of replicates per cell; replicate i uses seed <seed>+i")
+	workers := fs.Int("workers", runtime.NumCPU(), "number of replicates run in parallel")
+	seed := fs.Int64("seed", 0, "base random seed (0: seed from the clock)")
+	engine := fs.String("engine", "", "simulation engine: tick, event (default: the base scenario's, or tick)")
+	fs.Parse(args)
+
+	base := defaultScenario()
+	if *scenarioPath != "" {
+		var err error
+		base, err = loadScenario(*scenarioPath)
+		if err != nil {
+			return err
+		}
+	}
+	base.Name = *name
+	tps := base.Globals.TicksPerSecond
+	if tps == 0 {
+		tps = DefaultSimulationConfig().TicksPerSecond
+	}
+	base.Globals.TickSamples = tps * int64(duration.Seconds())
+	if *seed != 0 {
+		base.Seed = *seed
+	}
+	if base.Seed == 0 {
+		base.Seed = time.Now().UnixNano()
+	}
+	if *engine != "" {
+		base.Globals.Engine = *engine
+	}
+
+	strategy := fmt.Sprintf("doublespend:%d:%d", *confirmations, *giveUp)
+	if _, err := parseStrategy(strategy); err != nil {
+		return err
+	}
+	grid := doubleSpendGrid{}
+	for _, c := range strings.Split(*consensus, ",") {
+		grid.Consensus = append(grid.Consensus, strings.TrimSpace(c))
+	}
+	var err error
+	if grid.HashrateShare, err = parseGrid(*hashrateShares); err != nil {
+		return fmt.Errorf("hashrate.share: %w", err)
+	}
+	if grid.BalanceShare, err = parseGrid(*balanceShares); err != nil {
+		return fmt.Errorf("balance.share: %w", err)
+	}
+	for _, cell := range grid.cells() {
+		if _, err := cell.scenario(base, strategy); err != nil {
+			return fmt.Errorf("%s: %w", cell, err)
+		}
+	}
+
+	log.Println("Double-spend", base.Name, "cells", len(grid.cells()), "replicates", *replicates, "confirmations", *confirmations, "seed", base.Seed)
+	rows, err := runDoubleSpend(base, grid, strategy, *replicates, *workers, log.Println)
+	if err != nil {
+		return err
+	}
+
+	outDir := filepath.Join(*outRoot, base.Name)
+	if err := os.MkdirAll(outDir, os.ModePerm); err != nil {
+		return err
+	}
+	if err := writeDoubleSpendCSV(filepath.Join(outDir, "doublespend.csv"), rows); err != nil {
+		return err
+	}
+	if err := writeDoubleSpendSummaryCSV(filepath.Join(outDir, "doublespend_summary.csv"), rows, *confirmations); err != nil {
+		return err
+	}
+	return plotDoubleSpend(outDir, grid, rows, *confirmations)
+}
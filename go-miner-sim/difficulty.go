@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Difficulty calculators.
+//
+//	simple          the simplified Homestead/EIP-100 formula, getBlockDifficulty (default)
+//	byzantium       go-ethereum's, with the EIP-649 bomb delay
+//	constantinople  go-ethereum's, with the EIP-1234 bomb delay
+//	london          go-ethereum's, with the EIP-3554 bomb delay
+//	etc             Ethereum Classic's: Homestead, the ECIP-1010 bomb pause, its ECIP-1041 removal, then EIP-100 at Atlantis
+//	constant        every block has the genesis difficulty, a control
+//
+// The bombs go off by block number; SimulationConfig.DifficultyStartNumber places the simulation's genesis on the chain.
+const (
+	DifficultySimple         = "simple"
+	DifficultyByzantium      = "byzantium"
+	DifficultyConstantinople = "constantinople"
+	DifficultyLondon         = "london"
+	DifficultyETC            = "etc"
+	DifficultyConstant       = "constant"
+)
+
+// difficultyCalculator returns the difficulty of a block with the timestamp (in ticks) built on parent.
+type difficultyCalculator func(s *Simulation, parent *Block, timestamp int64) int64
+
+var difficultyCalculators = map[string]difficultyCalculator{
+	DifficultySimple: func(s *Simulation, parent *Block, timestamp int64) int64 {
+		return s.getBlockDifficulty(parent, len(parent.uncles) > 0, timestamp-parent.s)
+	},
+	DifficultyByzantium:      gethDifficulty(ethash.DynamicDifficultyCalculator(big.NewInt(3_000_000))),
+	DifficultyConstantinople: gethDifficulty(ethash.DynamicDifficultyCalculator(big.NewInt(5_000_000))),
+	DifficultyLondon:         gethDifficulty(ethash.DynamicDifficultyCalculator(big.NewInt(9_700_000))),
+	DifficultyETC:            etcDifficulty,
+	DifficultyConstant: func(s *Simulation, _ *Block, _ int64) int64 {
+		return s.genesisBlock.d
+	},
+}
+
+func validDifficulty(name string) error {
+	if name == "" {
+		return nil
+	}
+	if _, ok := difficultyCalculators[name]; !ok {
+		return fmt.Errorf("unknown difficulty calculator: %q (want simple, byzantium, constantinople, london, etc or constant)", name)
+	}
+	return nil
+}
+
+// calcDifficulty returns the difficulty of a block with the timestamp (in ticks) built on parent,
+// by the simulation's calculator.
+func (s *Simulation) calcDifficulty(parent *Block, timestamp int64) int64 {
+	calc, ok := difficultyCalculators[s.Difficulty]
+	if !ok {
+		calc = difficultyCalculators[DifficultySimple]
+	}
+	return calc(s, parent, timestamp)
+}
+
+// number is the block's number on the chain the simulation stands in for.
+func (s *Simulation) number(b *Block) int64 {
+	return s.DifficultyStartNumber + b.i
+}
+
+// gethDifficulty adapts a go-ethereum difficulty calculator,
+// giving it a header with the parent's number, timestamp (in seconds), difficulty and uncles.
+func gethDifficulty(calc func(time uint64, parent *types.Header) *big.Int) difficultyCalculator {
+	return func(s *Simulation, parent *Block, timestamp int64) int64 {
+		header := &types.Header{
+			Number:     big.NewInt(s.number(parent)),
+			Time:       uint64(parent.s / s.TicksPerSecond),
+			Difficulty: big.NewInt(parent.d),
+			UncleHash:  types.EmptyUncleHash,
+		}
+		if len(parent.uncles) > 0 {
+			header.UncleHash = common.Hash{1} // anything else
+		}
+		d := calc(uint64(timestamp/s.TicksPerSecond), header)
+		if !d.IsInt64() {
+			return explodedDifficulty
+		}
+		return d.Int64()
+	}
+}
+
+// explodedDifficulty stands for a difficulty past the int64 range: the bomb went off.
+// No block can carry it (see accumulateWeights); a simulation starting there is rejected by checkDifficulty.
+const explodedDifficulty = math.MaxInt64
+
+// checkDifficulty tells whether the bomb has gone off by the simulation's start number.
+func (s *Simulation) checkDifficulty() error {
+	g := s.genesisBlock
+	if s.calcDifficulty(g, g.s+s.TicksPerSecond) == explodedDifficulty {
+		return fmt.Errorf("difficulty %s: the bomb exploded at start number %d", s.Difficulty, s.DifficultyStartNumber)
+	}
+	return nil
+}
+
+// accumulateWeights returns the total difficulty and TD×TABS of a block with the difficulty and TABS built on parent,
+// or an error if they overflow int64, as they do once the bomb has grown the difficulty far enough.
+func accumulateWeights(parent *Block, d, tabs int64) (td, ttdtabs int64, err error) {
+	if d > math.MaxInt64-parent.td {
+		return 0, 0, fmt.Errorf("total difficulty overflows with a difficulty of %d", d)
+	}
+	if tabs > 0 && (d > math.MaxInt64/tabs || tabs*d > math.MaxInt64-parent.ttdtabs) {
+		return 0, 0, fmt.Errorf("total TD×TABS overflows with a difficulty of %d and TABS of %d", d, tabs)
+	}
+	return parent.td + d, parent.ttdtabs + tabs*d, nil
+}
+
+// Ethereum Classic's difficulty bomb schedule and forks.
+const (
+	etcBombPauseBlock    = 3_000_000 // ECIP-1010: the bomb is paused...
+	etcBombContinueBlock = 5_000_000 // ...and continues, two million blocks behind
+	etcBombDefuseBlock   = 5_900_000 // ECIP-1041: and then it's removed
+	etcAtlantisBlock     = 8_772_000 // EIP-100
+
+	minimumDifficulty = 131072
+)
+
+// etcDifficulty is Ethereum Classic's difficulty: Homestead's adjustment up to Atlantis, EIP-100's from then on,
+// and the bomb as scheduled by ECIP-1010 and ECIP-1041.
+func etcDifficulty(s *Simulation, parent *Block, timestamp int64) int64 {
+	n := s.number(parent) + 1
+	interval := (timestamp - parent.s) / s.TicksPerSecond
+
+	var adj int64
+	if n >= etcAtlantisBlock {
+		adj = 1 - interval/9
+		if len(parent.uncles) > 0 {
+			adj = 2 - interval/9
+		}
+	} else {
+		adj = 1 - interval/10
+	}
+	if adj < -99 {
+		adj = -99
+	}
+	d := parent.d + parent.d/2048*adj
+	if d < minimumDifficulty {
+		d = minimumDifficulty
+	}
+
+	var period int64
+	switch {
+	case n < etcBombPauseBlock:
+		period = n / 100_000
+	case n < etcBombContinueBlock:
+		period = etcBombPauseBlock / 100_000
+	case n < etcBombDefuseBlock:
+		period = (n - (etcBombContinueBlock - etcBombPauseBlock)) / 100_000
+	}
+	if period > 1 {
+		d += 1 << uint(period-2)
+	}
+	return d
+}
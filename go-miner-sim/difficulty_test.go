@@ -0,0 +1,82 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestSimulation_CalcDifficulty(t *testing.T) {
+	config := DefaultSimulationConfig()
+	tps := config.TicksPerSecond
+	const d = 10_000_000_000
+	parent := &Block{i: 99, s: 1000 * tps, d: d}
+	uncled := &Block{i: 99, s: 1000 * tps, d: d, uncles: Blocks{{}}}
+
+	for _, c := range []struct {
+		calc   string
+		start  int64
+		parent *Block
+		secs   int64 // interval
+		want   int64
+	}{
+		{DifficultySimple, 0, parent, 5, d + d/2048},
+		{DifficultySimple, 0, uncled, 20, d},
+		{DifficultyByzantium, 0, parent, 5, d + d/2048},
+		{DifficultyByzantium, 0, parent, 18, d - d/2048},
+		{DifficultyByzantium, 0, uncled, 18, d},
+		{DifficultyConstantinople, 0, parent, 30, d - 2*(d/2048)},
+		{DifficultyLondon, 0, parent, 2000, d - 99*(d/2048)},
+		// London's bomb at mainnet's block 13,000,000: 2^((13M - 9.7M) / 100k - 2)
+		{DifficultyLondon, 13_000_000 - 100, parent, 9, d + 1<<31},
+		// Byzantium's, there, is well past going off.
+		{DifficultyByzantium, 13_000_000 - 100, parent, 9, explodedDifficulty},
+		// ETC: Homestead's 10 second steps,
+		{DifficultyETC, 0, parent, 10, d},
+		{DifficultyETC, 0, uncled, 9, d + d/2048},
+		// the bomb before, during and after the pause, and after its removal,
+		{DifficultyETC, 2_500_000 - 100, parent, 10, d + 1<<23},
+		{DifficultyETC, 4_000_000 - 100, parent, 10, d + 1<<28},
+		{DifficultyETC, 5_500_000 - 100, parent, 10, d + 1<<33},
+		{DifficultyETC, 6_000_000 - 100, parent, 10, d},
+		// and EIP-100's uncles at Atlantis.
+		{DifficultyETC, 9_000_000 - 100, uncled, 9, d + d/2048},
+		{DifficultyConstant, 0, parent, 100, genesisDifficulty},
+	} {
+		config.Difficulty = c.calc
+		config.DifficultyStartNumber = c.start
+		sim := NewSimulation(config)
+		if got := sim.calcDifficulty(c.parent, c.parent.s+c.secs*tps); got != c.want {
+			t.Errorf("%s at %d, +%ds: got %d, want %d", c.calc, c.start, c.secs, got, c.want)
+		}
+	}
+}
+
+// TestDifficultyBomb checks the blocks the bomb makes: their weights accumulate while they fit,
+// mining stops the run with an error once they don't, and a run can't start with the bomb gone off.
+func TestDifficultyBomb(t *testing.T) {
+	m, _ := strategyMiner(t, "honest")
+	m.sim.Difficulty = DifficultyLondon
+	m.sim.DifficultyStartNumber = 13_000_000 - 1
+	g := m.sim.genesisBlock
+	b := mine(m)
+	if b == g || b.d != g.d+1<<31 || b.td != g.td+b.d || b.ttdtabs != g.ttdtabs+b.tabs*b.d || m.sim.err != nil {
+		t.Fatalf("block %s, ttdtabs %d, err %v", b, b.ttdtabs, m.sim.err)
+	}
+
+	// 2^60 fits a difficulty, but not the block's TD×TABS.
+	m.sim.DifficultyStartNumber = 9_700_000 + 6_200_000 - 1
+	if head := mine(m); head != b || m.sim.err == nil {
+		t.Errorf("overflowing block mined: head %s, err %v", head, m.sim.err)
+	}
+
+	sc := defaultScenario()
+	sc.Globals.Difficulty = DifficultyByzantium
+	sc.Globals.DifficultyStartNumber = 13_000_000
+	sim := NewSimulation(sc.config())
+	miners, err := sc.miners(sim, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sim.run(miners, nil); err == nil {
+		t.Error("run started with the bomb gone off")
+	}
+}
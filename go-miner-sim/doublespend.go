@@ -0,0 +1,399 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/plotutil"
+)
+
+// The double-spend harness: once its head is doubleSpendStartHeight high, the attacker forks from it,
+// paying a merchant in the public block after it (the target), and mines a private chain without the payment.
+// It releases the chain once the merchant has seen the target confirmed (the target and the blocks on it)
+// and the private chain beats the public one by the attacker's fork choice.
+// The attack succeeds if most of the other miners end up on the attacker's chain.
+//
+// Replicated over a grid of the attacker's hashrate and balance shares, the success rate is held against
+// the analytic probabilities of Nakamoto (2008) and Rosenfeld (2014), which know nothing of latency, TABS or giving up.
+
+// doubleSpendStartHeight is how high the attacker's head is when it forks, so the attack starts on a settled network.
+const doubleSpendStartHeight = 10
+
+// DoubleSpend attacks a payment with six confirmations, giving up on its private chain fifty blocks behind.
+// "doublespend:<confirmations>[:<give up>]" sets both.
+var DoubleSpend Strategy = &doubleSpend{name: "doublespend", confirmations: 6, giveUp: 50}
+
+type doubleSpend struct {
+	honest
+	name          string
+	confirmations int64
+	giveUp        int64 // blocks behind the public chain
+}
+
+// doubleSpendAttack is the state of a miner's double-spend.
+type doubleSpendAttack struct {
+	fork     *Block // the head the private chain forks from
+	target   *Block // the public block after the fork, paying the merchant
+	released bool
+	gaveUp   bool
+}
+
+func (a *doubleSpendAttack) active() bool {
+	return a != nil && !a.released && !a.gaveUp
+}
+
+// height is the height of the miner's private chain.
+func (a *doubleSpendAttack) height(m *Miner) int64 {
+	if tip := m.privateTip(); tip != nil {
+		return tip.i
+	}
+	return a.fork.i
+}
+
+func (d *doubleSpend) String() string { return d.name }
+
+// Prefer keeps the miner on its private chain while the attack is on, until it gives up.
+func (d *doubleSpend) Prefer(m *Miner, a, b *Block) *Block {
+	if m.attack.active() && b.miner != m.Address && b.i <= m.attack.height(m)+d.giveUp {
+		return a
+	}
+	return nil
+}
+
+func (d *doubleSpend) Mined(m *Miner, b *Block) Blocks {
+	switch {
+	case m.attack == nil:
+		if b.i >= doubleSpendStartHeight {
+			m.attack = &doubleSpendAttack{fork: b}
+		}
+	case m.attack.active():
+		return d.resolve(m)
+	}
+	return m.withheld
+}
+
+func (d *doubleSpend) Received(m *Miner, b *Block) Blocks {
+	a := m.attack
+	switch {
+	case a == nil:
+		if m.head.i >= doubleSpendStartHeight {
+			m.attack = &doubleSpendAttack{fork: m.head}
+		}
+		return nil
+	case !a.active():
+		return nil
+	}
+	if a.target == nil && m.publicHeight() > a.fork.i {
+		t := m.publicHead
+		for t != nil && t.i > a.fork.i+1 {
+			t = m.Blocks.GetParent(t)
+		}
+		a.target = t
+	}
+	return d.resolve(m)
+}
+
+// resolve releases the private chain once the target is confirmed and the chain beats the public one,
+// and gives it up once the public chain is too far ahead.
+func (d *doubleSpend) resolve(m *Miner) Blocks {
+	a, tip := m.attack, m.privateTip()
+	if m.publicHeight() > a.height(m)+d.giveUp {
+		a.gaveUp = true
+		m.withheld = nil
+		return nil
+	}
+	if tip == nil || a.target == nil || m.publicHeight()-a.target.i+1 < d.confirmations || !m.privateBeats() {
+		return nil
+	}
+	a.released = true
+	return m.withheld
+}
+
+func parseDoubleSpend(s string, fields []string) (Strategy, error) {
+	d := &doubleSpend{name: s, giveUp: 50}
+	if len(fields) < 2 || len(fields) > 3 {
+		return nil, fmt.Errorf("strategy %q: want doublespend:<confirmations>[:<give up>]", s)
+	}
+	var err error
+	if d.confirmations, err = strconv.ParseInt(fields[1], 10, 64); err != nil || d.confirmations < 1 {
+		return nil, fmt.Errorf("strategy %q: want at least one confirmation", s)
+	}
+	if len(fields) == 3 {
+		if d.giveUp, err = strconv.ParseInt(fields[2], 10, 64); err != nil || d.giveUp < 0 {
+			return nil, fmt.Errorf("strategy %q: bad give up", s)
+		}
+	}
+	return d, nil
+}
+
+// doubleSpendOutcome tells whether the attacker released its private chain,
+// and whether the attack succeeded: whether most of the other miners have the attacker's block after the fork.
+func doubleSpendOutcome(attacker *Miner, miners []*Miner) (released, succeeded bool) {
+	a := attacker.attack
+	if a == nil || !a.released {
+		return false, false
+	}
+	on, others := 0, 0
+	for _, m := range miners {
+		if m == attacker {
+			continue
+		}
+		others++
+		if b, ok := m.canon[a.fork.i+1]; ok && b.miner == attacker.Address {
+			on++
+		}
+	}
+	return true, 2*on > others
+}
+
+// nakamotoDoubleSpend is the probability that an attacker with q of the hashrate ever catches up
+// with a payment z blocks deep (Nakamoto 2008, section 11).
+func nakamotoDoubleSpend(q float64, z int64) float64 {
+	p := 1 - q
+	if q >= p {
+		return 1
+	}
+	lambda := float64(z) * q / p
+	sum := 1.0
+	poisson := math.Exp(-lambda)
+	for k := int64(0); k <= z; k++ {
+		if k > 0 {
+			poisson *= lambda / float64(k)
+		}
+		sum -= poisson * (1 - math.Pow(q/p, float64(z-k)))
+	}
+	return sum
+}
+
+// rosenfeldDoubleSpend is the probability that an attacker with q of the hashrate double-spends a payment
+// the merchant waits n confirmations for, the attacker's blocks in the meantime being negative binomial (Rosenfeld 2014).
+func rosenfeldDoubleSpend(q float64, n int64) float64 {
+	p := 1 - q
+	if q >= p {
+		return 1
+	}
+	sum := 1.0
+	binomial := 1.0 // C(m+n-1, m)
+	for m := int64(0); m <= n; m++ {
+		if m > 0 {
+			binomial *= float64(m+n-1) / float64(m)
+		}
+		sum -= binomial * (math.Pow(p, float64(n))*math.Pow(q, float64(m)) - math.Pow(p, float64(m))*math.Pow(q, float64(n)))
+	}
+	return sum
+}
+
+// doubleSpendGrid holds the values to run the double-spend for; it runs their Cartesian product.
+type doubleSpendGrid struct {
+	Consensus     []string
+	HashrateShare []float64
+	BalanceShare  []float64
+}
+
+// doubleSpendCell is one point of a doubleSpendGrid: the attacker's shares of the network's hashrate and balances.
+type doubleSpendCell struct {
+	Consensus     string
+	HashrateShare float64
+	BalanceShare  float64
+}
+
+func (g doubleSpendGrid) cells() (cells []doubleSpendCell) {
+	for _, c := range g.Consensus {
+		for _, q := range g.HashrateShare {
+			for _, b := range g.BalanceShare {
+				cells = append(cells, doubleSpendCell{c, q, b})
+			}
+		}
+	}
+	return cells
+}
+
+func (c doubleSpendCell) String() string {
+	return fmt.Sprintf("%s hashrate=%g balance=%g", c.Consensus, c.HashrateShare, c.BalanceShare)
+}
+
+// doubleSpendAttacker is the attacker's address (red).
+const doubleSpendAttacker = "ff0000"
+
+// scenario returns a copy of the base scenario with the cell's consensus algorithm and a double-spend attacker
+// with the cell's shares of the hashrate and balances of the base scenario's miners and itself.
+func (c doubleSpendCell) scenario(base *Scenario, strategy string) (*Scenario, error) {
+	if c.HashrateShare <= 0 || c.HashrateShare >= 1 || c.BalanceShare < 0 || c.BalanceShare >= 1 {
+		return nil, fmt.Errorf("%s: want shares in (0, 1) and [0, 1)", c)
+	}
+	sc := *base
+	sc.Name = fmt.Sprintf("%s_%s", base.Name, c)
+	sc.ConsensusAlgorithm = c.Consensus
+	if err := sc.validate(); err != nil {
+		return nil, err
+	}
+
+	miners, err := sc.miners(NewSimulation(sc.config()), nil)
+	if err != nil {
+		return nil, err
+	}
+	hashrate, balance := 0.0, 0.0
+	for _, m := range miners {
+		hashrate += m.Hashrate
+		balance += float64(m.Balance)
+	}
+	attacker := ScenarioMiner{
+		Address:  doubleSpendAttacker,
+		Hashrate: hashrate * c.HashrateShare / (1 - c.HashrateShare),
+		Balance:  func(i int64) *int64 { return &i }(int64(balance * c.BalanceShare / (1 - c.BalanceShare))),
+		Strategy: strategy,
+	}
+	sc.Miners = append(append([]ScenarioMiner{}, base.Miners...), attacker)
+	return &sc, sc.validate()
+}
+
+// doubleSpendRow is the outcome of one replicate of one cell.
+type doubleSpendRow struct {
+	doubleSpendCell
+	Replicate int
+	Seed      int64
+	Released  bool
+	Succeeded bool
+}
+
+// runDoubleSpend runs n replicates of the double-spend in every cell of the grid on up to workers goroutines,
+// the attacker waiting for the confirmations. Replicate i of every cell is seeded with base.Seed+i.
+// Rows are ordered by cell, then replicate.
+func runDoubleSpend(base *Scenario, grid doubleSpendGrid, strategy string, n, workers int, logf func(args ...interface{})) ([]doubleSpendRow, error) {
+	cells := grid.cells()
+	rows := make([]doubleSpendRow, len(cells)*n)
+	err := parallel(len(rows), workers, func(j int) error {
+		cell, i := cells[j/n], j%n
+		sc, err := cell.scenario(base, strategy)
+		if err != nil {
+			return fmt.Errorf("%s: %w", cell, err)
+		}
+		seed := base.Seed + int64(i)
+		miners, err := runReplicate(sc, seed)
+		if err != nil {
+			return fmt.Errorf("%s replicate %d: %w", cell, i, err)
+		}
+		row := doubleSpendRow{doubleSpendCell: cell, Replicate: i, Seed: seed}
+		row.Released, row.Succeeded = doubleSpendOutcome(miners[len(miners)-1], miners)
+		rows[j] = row
+		if logf != nil {
+			logf("Done", cell, "replicate", i, "succeeded", row.Succeeded)
+		}
+		return nil
+	})
+	return rows, err
+}
+
+func boolFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// writeDoubleSpendCSV writes the outcome of every replicate.
+func writeDoubleSpendCSV(filename string, rows []doubleSpendRow) error {
+	records := [][]string{{"consensus", "hashrate_share", "balance_share", "replicate", "seed", "released", "succeeded"}}
+	for _, row := range rows {
+		records = append(records, []string{
+			row.Consensus, formatFloat(row.HashrateShare), formatFloat(row.BalanceShare),
+			strconv.Itoa(row.Replicate), strconv.FormatInt(row.Seed, 10),
+			strconv.FormatBool(row.Released), strconv.FormatBool(row.Succeeded),
+		})
+	}
+	return writeCSV(filename, records)
+}
+
+// writeDoubleSpendSummaryCSV writes the release and success rates per cell, with the success rate's
+// 95% confidence interval and the analytic probabilities for the confirmations.
+func writeDoubleSpendSummaryCSV(filename string, rows []doubleSpendRow, confirmations int64) error {
+	records := [][]string{{"consensus", "hashrate_share", "balance_share", "confirmations", "n",
+		"released_rate", "success_rate", "ci95_low", "ci95_high", "nakamoto", "rosenfeld"}}
+	for start := 0; start < len(rows); {
+		end := start
+		for end < len(rows) && rows[end].doubleSpendCell == rows[start].doubleSpendCell {
+			end++
+		}
+		released, succeeded := []float64{}, []float64{}
+		for _, row := range rows[start:end] {
+			released = append(released, boolFloat(row.Released))
+			succeeded = append(succeeded, boolFloat(row.Succeeded))
+		}
+		c, s := rows[start].doubleSpendCell, summarizeMetric(succeeded)
+		records = append(records, []string{
+			c.Consensus, formatFloat(c.HashrateShare), formatFloat(c.BalanceShare), strconv.FormatInt(confirmations, 10),
+			strconv.Itoa(s.N), formatFloat(summarizeMetric(released).Mean),
+			formatFloat(s.Mean), formatFloat(s.CI95Low), formatFloat(s.CI95High),
+			formatFloat(nakamotoDoubleSpend(c.HashrateShare, confirmations)),
+			formatFloat(rosenfeldDoubleSpend(c.HashrateShare, confirmations)),
+		})
+		start = end
+	}
+	return writeCSV(filename, records)
+}
+
+// plotDoubleSpend draws, for each consensus algorithm, the success rate against the attacker's hashrate share,
+// one line per balance share, over the analytic curves.
+func plotDoubleSpend(outDir string, grid doubleSpendGrid, rows []doubleSpendRow, confirmations int64) error {
+	analytic := func(f func(q float64, z int64) float64) plotter.XYs {
+		xys := plotter.XYs{}
+		for q := 0.0; q <= 0.5+1e-9; q += 0.01 {
+			xys = append(xys, plotter.XY{X: q, Y: f(q, confirmations)})
+		}
+		return xys
+	}
+
+	for _, consensus := range grid.Consensus {
+		p := plot.New()
+		p.Title.Text = fmt.Sprintf("%s: double-spend success, %d confirmations", consensus, confirmations)
+		p.X.Label.Text = "attacker hashrate share"
+		p.Y.Label.Text = "success rate"
+		p.Y.Min, p.Y.Max = 0, 1
+		p.Legend.Top = true
+		p.Legend.Left = true
+
+		for i, f := range []struct {
+			name string
+			f    func(q float64, z int64) float64
+		}{{"Nakamoto", nakamotoDoubleSpend}, {"Rosenfeld", rosenfeldDoubleSpend}} {
+			l, err := plotter.NewLine(analytic(f.f))
+			if err != nil {
+				return err
+			}
+			l.Dashes = plotutil.Dashes(i + 1)
+			p.Add(l)
+			p.Legend.Add(f.name, l)
+		}
+
+		for i, b := range grid.BalanceShare {
+			xys := plotter.XYs{}
+			for _, q := range grid.HashrateShare {
+				xs := []float64{}
+				for _, row := range rows {
+					if row.Consensus == consensus && row.HashrateShare == q && row.BalanceShare == b {
+						xs = append(xs, boolFloat(row.Succeeded))
+					}
+				}
+				xys = append(xys, plotter.XY{X: q, Y: summarizeMetric(xs).Mean})
+			}
+			l, s, err := plotter.NewLinePoints(xys)
+			if err != nil {
+				return err
+			}
+			l.Color, s.Color = plotutil.Color(i), plotutil.Color(i)
+			p.Add(l, s)
+			p.Legend.Add(fmt.Sprintf("balance share %g", b), l, s)
+		}
+
+		filename := filepath.Join(outDir, fmt.Sprintf("doublespend_%s.png", strings.ToLower(consensus)))
+		if err := p.Save(600, 450, filename); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDoubleSpendProbabilities(t *testing.T) {
+	for _, c := range []struct {
+		f    func(q float64, z int64) float64
+		q    float64
+		z    int64
+		want float64
+	}{
+		// Nakamoto's tables.
+		{nakamotoDoubleSpend, 0.1, 1, 0.2045873},
+		{nakamotoDoubleSpend, 0.1, 5, 0.0009137},
+		{nakamotoDoubleSpend, 0.3, 5, 0.1773523},
+		{nakamotoDoubleSpend, 0.3, 10, 0.0416605},
+		// Rosenfeld's: 2q for one confirmation.
+		{rosenfeldDoubleSpend, 0.1, 1, 0.2},
+		{rosenfeldDoubleSpend, 0.1, 2, 0.056},
+		{rosenfeldDoubleSpend, 0.5, 6, 1},
+		{nakamotoDoubleSpend, 0.6, 6, 1},
+	} {
+		if got := c.f(c.q, c.z); math.Abs(got-c.want) > 1e-7 {
+			t.Errorf("q=%v z=%d: got %v, want %v", c.q, c.z, got, c.want)
+		}
+	}
+}
+
+func TestDoubleSpend(t *testing.T) {
+	m, honest := strategyMiner(t, "doublespend:2:3")
+	h := m.sim.genesisBlock
+	for i := 0; i < doubleSpendStartHeight; i++ {
+		h = honest(h)
+	}
+	if m.attack == nil || m.attack.fork != h {
+		t.Fatalf("attack %+v, want the fork at %s", m.attack, h)
+	}
+
+	// The private chain, a block lighter than the public one, waits.
+	mine(m)
+	target := honest(h)
+	mine(m)
+	h = honest(target)
+	if m.attack.target != target || len(m.withheld) != 2 || m.attack.released {
+		t.Fatalf("attack %+v, %d withheld", m.attack, len(m.withheld))
+	}
+	// Confirmed, and now ahead.
+	a3 := mine(m)
+	if !m.attack.released || len(m.withheld) != 0 || m.head != a3 {
+		t.Errorf("attack %+v, %d withheld, head %s", m.attack, len(m.withheld), m.head)
+	}
+
+	// Too far behind, the attacker gives up.
+	m, honest = strategyMiner(t, "doublespend:2:3")
+	h = m.sim.genesisBlock
+	for i := 0; i < doubleSpendStartHeight; i++ {
+		h = honest(h)
+	}
+	mine(m)
+	for i := 0; i < 5; i++ {
+		h = honest(h)
+	}
+	if !m.attack.gaveUp || len(m.withheld) != 0 || m.head != h {
+		t.Errorf("attack %+v, %d withheld, head %s", m.attack, len(m.withheld), m.head)
+	}
+}
+
+func TestRunDoubleSpend(t *testing.T) {
+	base := defaultScenario()
+	base.Seed = 1
+	base.Population.Count = 4
+	base.Globals.TickSamples = 10 * 60 * 60
+	grid := doubleSpendGrid{Consensus: []string{"TD", "TDTABS"}, HashrateShare: []float64{0.45}, BalanceShare: []float64{0.5}}
+
+	sc, err := grid.cells()[0].scenario(base, "doublespend:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	miners, err := sc.miners(NewSimulation(sc.config()), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashrate, balance := 0.0, int64(0)
+	for _, m := range miners {
+		hashrate += m.Hashrate
+		balance += m.Balance
+	}
+	attacker := miners[len(miners)-1]
+	if share := attacker.Hashrate / hashrate; math.Abs(share-0.45) > 1e-9 {
+		t.Errorf("hashrate share %v", share)
+	}
+	if share := float64(attacker.Balance) / float64(balance); math.Abs(share-0.5) > 1e-3 {
+		t.Errorf("balance share %v", share)
+	}
+
+	rows, err := runDoubleSpend(base, grid, "doublespend:1", 3, 2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 6 {
+		t.Fatalf("%d rows", len(rows))
+	}
+	released := 0
+	for _, row := range rows {
+		if row.Succeeded && !row.Released {
+			t.Errorf("%+v: succeeded unreleased", row)
+		}
+		if row.Released {
+			released++
+		}
+	}
+	if released == 0 {
+		t.Error("no attack released")
+	}
+}
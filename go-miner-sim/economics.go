@@ -0,0 +1,297 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// The economics model: a miner's income is the block and nephew rewards, uncle rewards and fees of the canonical blocks
+// it has a part in, less its CostPerBlock for each of its own; these follow its chain through reorgs.
+// Its electricity costs accrue every tick in proportion to its HashesPerTick, and at the end of every period
+// (and of the run) it settles: it pays them, and takes its income out of its balance, as its SellPolicy has it.
+// Since a miner's balance counts toward its blocks' TAB, what it keeps is what it can use for TABS.
+//
+// Amounts are in the units of the balances and BlockReward (about ether); fees are converted from gwei.
+// Without SimulationConfig.Economics, there are no electricity costs and no settlements.
+
+// EconomicsConfig parameterizes the economics model.
+type EconomicsConfig struct {
+	// ElectricityCostRatio is the miners' electricity cost, as a fraction of the block rewards
+	// they expect for their hashrate at the genesis difficulty.
+	ElectricityCostRatio float64
+
+	// PeriodSeconds is how often miners settle.
+	PeriodSeconds float64
+}
+
+// DefaultEconomicsConfig has miners spend 70% of their expected rewards on electricity, settling every hour.
+func DefaultEconomicsConfig() EconomicsConfig {
+	return EconomicsConfig{
+		ElectricityCostRatio: 0.7,
+		PeriodSeconds:        60 * 60,
+	}
+}
+
+// ledger is a miner's profit and loss.
+type ledger struct {
+	Rewards      float64 `json:"rewards"`      // block and nephew rewards
+	UncleRewards float64 `json:"uncleRewards"` // for the miner's blocks referenced as uncles
+	Fees         float64 `json:"fees"`
+	BlockCosts   float64 `json:"blockCosts"` // CostPerBlock, for each of the miner's blocks
+	Electricity  float64 `json:"electricity"`
+
+	// Sold is what the miner has taken out of its balance: sold by its SellPolicy, or above its BalanceCap.
+	Sold float64 `json:"sold"`
+}
+
+func (l ledger) Income() float64   { return l.Rewards + l.UncleRewards + l.Fees }
+func (l ledger) Expenses() float64 { return l.BlockCosts + l.Electricity }
+func (l ledger) Profit() float64   { return l.Income() - l.Expenses() }
+
+// blockLedger is what the address earns from the block being canonical, by source.
+func (s *Simulation) blockLedger(b *Block, address string) (l ledger) {
+	if b.miner == address {
+		l.Rewards = float64(s.BlockReward) * (1 + float64(len(b.uncles))/32)
+		for _, x := range b.body {
+			l.Fees += float64(x.gas) * x.gasPrice / params.GWei
+		}
+	}
+	for _, u := range b.uncles {
+		if u.miner == address {
+			l.UncleRewards += float64(8-(b.i-u.i)) * float64(s.BlockReward) / 8
+		}
+	}
+	return l
+}
+
+// bookBlock enters the block's income and costs in the miner's ledger and balance as it joins (in) or leaves its chain.
+func (m *Miner) bookBlock(b *Block, in bool) {
+	l := m.sim.blockLedger(b, m.Address)
+	if b.miner == m.Address {
+		l.BlockCosts = float64(m.CostPerBlock)
+	}
+	if l == (ledger{}) {
+		return
+	}
+	sign := 1.0
+	if !in {
+		sign = -1
+	}
+	m.ledger.Rewards += sign * l.Rewards
+	m.ledger.UncleRewards += sign * l.UncleRewards
+	m.ledger.Fees += sign * l.Fees
+	m.ledger.BlockCosts += sign * l.BlockCosts
+	m.credit(sign * (l.Income() - l.BlockCosts))
+}
+
+// credit adds the amount to the miner's balance, keeping the fraction of a unit the balance can't hold for later.
+func (m *Miner) credit(amount float64) {
+	m.balanceFrac += amount
+	whole := math.Floor(m.balanceFrac)
+	m.balanceFrac -= whole
+	m.balanceAdd(int64(whole))
+}
+
+// electricityPerTick is what the miner's hashing costs each tick.
+// A miner expects HashesPerTick / difficulty * networkLambda blocks a tick (see discoveryTicks).
+func (m *Miner) electricityPerTick() float64 {
+	s := m.sim
+	return s.Economics.ElectricityCostRatio * float64(m.HashesPerTick) / float64(s.genesisBlock.d) * s.networkLambda * float64(s.BlockReward)
+}
+
+// balanceSample is a miner's balance and profit at a settlement.
+type balanceSample struct {
+	tick    int64
+	balance int64
+	profit  float64
+}
+
+// settle charges the miner's electricity up to the tick, sells as its policy has it, and samples its balance.
+func (m *Miner) settle(tick int64) {
+	cost := float64(tick-m.settled) * m.electricityPerTick()
+	m.settled = tick
+	m.ledger.Electricity += cost
+	m.owed += cost
+
+	sell := m.sellPolicy().Sell(m, m.owed)
+	if max := float64(m.Balance) + m.balanceFrac; sell > max {
+		sell = max
+	}
+	if sell > 0 {
+		m.credit(-sell)
+		m.ledger.Sold += sell
+		m.owed = math.Max(0, m.owed-sell)
+	}
+	m.balances = append(m.balances, balanceSample{tick, m.Balance, m.ledger.Profit()})
+}
+
+// settle settles the miners at the end of every period up to the tick.
+// At the end of the run (the tick is TickSamples), it settles the rest of the last period too.
+func (s *Simulation) settle(miners []*Miner, tick int64) {
+	if s.Economics == nil {
+		return
+	}
+	period := int64(s.Economics.PeriodSeconds * float64(s.TicksPerSecond))
+	if period < 1 {
+		period = 1
+	}
+	for next := s.settled + period; next <= tick; next += period {
+		for _, m := range miners {
+			m.settle(next)
+		}
+		s.settled = next
+	}
+	if tick == s.TickSamples && s.settled < tick {
+		for _, m := range miners {
+			m.settle(tick)
+		}
+		s.settled = tick
+	}
+}
+
+// SellPolicy is what a miner takes out of its balance when it settles.
+// Policies are registered by name (see RegisterSellPolicy).
+type SellPolicy interface {
+	String() string
+
+	// Sell returns how much of the balance the miner sells, owing the electricity costs it hasn't yet paid.
+	// The sale is limited to the balance; what it pays of the costs is no longer owed.
+	Sell(m *Miner, owed float64) float64
+}
+
+var (
+	// Hold keeps everything: the costs are paid from outside.
+	Hold SellPolicy = &sellFraction{"hold", 0, false}
+
+	// Cover sells enough of the balance to pay the costs.
+	Cover SellPolicy = &sellFraction{"cover", 0, true}
+
+	// SellAll pays the costs and sells everything else; "sell:<fraction>" sells that fraction of what is left.
+	// "keep:<amount>" pays the costs and withdraws whatever is left above the amount.
+	SellAll SellPolicy = &sellFraction{"sell", 1, true}
+)
+
+func init() {
+	RegisterSellPolicy(Hold)
+	RegisterSellPolicy(Cover)
+	RegisterSellPolicy(SellAll)
+}
+
+var (
+	sellPolicies    = map[string]SellPolicy{}
+	sellPolicyNames []string // in registration order
+)
+
+// RegisterSellPolicy makes the policy available by its name.
+// It panics if the name is taken.
+func RegisterSellPolicy(p SellPolicy) {
+	name := p.String()
+	if _, ok := sellPolicies[name]; ok {
+		panic(fmt.Sprintf("sell policy %q registered twice", name))
+	}
+	sellPolicies[name] = p
+	sellPolicyNames = append(sellPolicyNames, name)
+}
+
+func parseSellPolicy(s string) (SellPolicy, error) {
+	if p, ok := sellPolicies[s]; ok {
+		return p, nil
+	}
+	if f := strings.TrimPrefix(s, "sell:"); f != s {
+		fraction, err := strconv.ParseFloat(f, 64)
+		if err != nil || fraction < 0 || fraction > 1 {
+			return nil, fmt.Errorf("sell policy %q: want a fraction in [0, 1]", s)
+		}
+		return &sellFraction{s, fraction, true}, nil
+	}
+	if a := strings.TrimPrefix(s, "keep:"); a != s {
+		amount, err := strconv.ParseFloat(a, 64)
+		if err != nil || amount < 0 {
+			return nil, fmt.Errorf("sell policy %q: want an amount of at least 0", s)
+		}
+		return &keepBalance{s, amount}, nil
+	}
+	return nil, fmt.Errorf("unknown sell policy: %q (want one of %s)", s, strings.Join(sellPolicyNames, ", "))
+}
+
+// sellFraction pays the costs owed (if cover) and sells the fraction of the balance left.
+type sellFraction struct {
+	name     string
+	fraction float64
+	cover    bool
+}
+
+func (p *sellFraction) String() string { return p.name }
+
+func (p *sellFraction) Sell(m *Miner, owed float64) (sell float64) {
+	left := float64(m.Balance) + m.balanceFrac
+	if p.cover {
+		sell = math.Min(owed, math.Max(left, 0))
+		left -= sell
+	}
+	if left > 0 {
+		sell += p.fraction * left
+	}
+	return sell
+}
+
+// keepBalance pays the costs owed and withdraws the balance above an amount.
+type keepBalance struct {
+	name   string
+	amount float64
+}
+
+func (p *keepBalance) String() string { return p.name }
+
+func (p *keepBalance) Sell(m *Miner, owed float64) float64 {
+	return math.Max(owed, float64(m.Balance)+m.balanceFrac-p.amount)
+}
+
+func (m *Miner) sellPolicy() SellPolicy {
+	if m.SellPolicy == nil {
+		return Hold
+	}
+	return m.SellPolicy
+}
+
+// sellPolicyName is the policy's name, or "" for none.
+func sellPolicyName(p SellPolicy) string {
+	if p == nil {
+		return ""
+	}
+	return p.String()
+}
+
+// writeLedgerCSV writes the miners' profit and loss: one row per miner.
+func writeLedgerCSV(filename string, miners []*Miner) error {
+	records := [][]string{{"miner", "address", "sell_policy", "rewards", "uncle_rewards", "fees", "income",
+		"block_costs", "electricity", "expenses", "profit", "sold", "balance"}}
+	for i, m := range miners {
+		l := m.ledger
+		records = append(records, []string{
+			strconv.Itoa(i), m.Address, m.sellPolicy().String(),
+			formatFloat(l.Rewards), formatFloat(l.UncleRewards), formatFloat(l.Fees), formatFloat(l.Income()),
+			formatFloat(l.BlockCosts), formatFloat(l.Electricity), formatFloat(l.Expenses()),
+			formatFloat(l.Profit()), formatFloat(l.Sold), strconv.FormatInt(m.Balance, 10),
+		})
+	}
+	return writeCSV(filename, records)
+}
+
+// writeBalancesCSV writes the miners' balance and cumulative profit at every settlement: one row per miner and settlement.
+func writeBalancesCSV(filename string, miners []*Miner, ticksPerSecond int64) error {
+	records := [][]string{{"miner", "address", "seconds", "balance", "profit"}}
+	for i, m := range miners {
+		for _, b := range m.balances {
+			records = append(records, []string{
+				strconv.Itoa(i), m.Address, strconv.FormatInt(b.tick/ticksPerSecond, 10),
+				strconv.FormatInt(b.balance, 10), formatFloat(b.profit),
+			})
+		}
+	}
+	return writeCSV(filename, records)
+}
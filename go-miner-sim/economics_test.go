@@ -0,0 +1,128 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSellPolicies(t *testing.T) {
+	m := &Miner{Balance: 100}
+	for _, c := range []struct {
+		policy string
+		want   float64
+	}{
+		{"hold", 0},
+		{"cover", 30},
+		{"sell", 100},
+		{"sell:0.5", 65}, // 30, and half of the 70 left
+		{"keep:50", 50},
+		{"keep:90", 30}, // the costs come first
+	} {
+		p, err := parseSellPolicy(c.policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.String() != c.policy {
+			t.Errorf("%s: named %s", c.policy, p)
+		}
+		if got := p.Sell(m, 30); got != c.want {
+			t.Errorf("%s: sold %v, want %v", c.policy, got, c.want)
+		}
+	}
+	for _, s := range []string{"sell:", "sell:2", "keep:-1", "cover:1", "dump"} {
+		if _, err := parseSellPolicy(s); err == nil {
+			t.Errorf("%q: want error", s)
+		}
+	}
+}
+
+func TestMiner_BookBlock(t *testing.T) {
+	sim := NewSimulation(DefaultSimulationConfig())
+	m := newMiner(sim, 0, "aaaaaa", 1, 100, nil)
+	m.CostPerBlock = 1
+
+	uncle := &Block{i: 9, miner: "bbbbbb"}
+	b := &Block{i: 10, miner: m.Address, uncles: Blocks{uncle}, body: []*tx{{gas: 1_000_000, gasPrice: 500}}}
+	m.bookBlock(b, true)
+	want := ledger{Rewards: 3 + 3.0/32, Fees: 0.5, BlockCosts: 1}
+	if m.ledger != want {
+		t.Errorf("ledger %+v, want %+v", m.ledger, want)
+	}
+	if m.Balance != 102 || m.balanceFrac != 0.59375 {
+		t.Errorf("balance %d + %v, want 102.59375", m.Balance, m.balanceFrac)
+	}
+
+	// The uncle's miner earns 7/8 of the reward.
+	if l := sim.blockLedger(b, uncle.miner); l.UncleRewards != 7*3.0/8 || l.Income() != 7*3.0/8 {
+		t.Errorf("uncle: %+v", l)
+	}
+
+	m.bookBlock(b, false)
+	if m.ledger != (ledger{}) || m.Balance != 100 || m.balanceFrac != 0 {
+		t.Errorf("after reorg: ledger %+v, balance %d + %v", m.ledger, m.Balance, m.balanceFrac)
+	}
+
+	m.BalanceCap = 101
+	m.bookBlock(b, true)
+	if m.Balance != 101 || m.ledger.Sold != 1 {
+		t.Errorf("capped: balance %d, sold %v", m.Balance, m.ledger.Sold)
+	}
+}
+
+// TestSimulation_Economics runs the economics model with each sell policy under both engines,
+// checking that the balances account for the ledgers and the electricity for the time.
+func TestSimulation_Economics(t *testing.T) {
+	for _, engine := range []string{EngineTick, EngineEvent} {
+		sc := defaultScenario()
+		sc.Seed = 3
+		sc.Population.Count = 3
+		sc.Population.CostPerBlock = 1
+		sc.Globals.Engine = engine
+		sc.Globals.TickSamples = 10 * 60 * 60 * 2
+		sc.Globals.Mempool = &ScenarioMempool{}
+		sc.Globals.Economics = &ScenarioEconomics{PeriodSeconds: 25 * 60}
+		sc.Miners = []ScenarioMiner{
+			{Address: "ff0000", Hashrate: 0.1, SellPolicy: "cover"},
+			{Address: "00ff00", Hashrate: 0.1, SellPolicy: "sell:0.5"},
+			{Address: "0000ff", Hashrate: 0.1, SellPolicy: "keep:10"},
+		}
+		if err := sc.validate(); err != nil {
+			t.Fatal(err)
+		}
+		starts := map[string]int64{}
+		sim := NewSimulation(sc.config())
+		miners, err := sc.miners(sim, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range miners {
+			starts[m.Address] = m.Balance
+		}
+		if err := sim.run(miners, nil); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, m := range miners {
+			r := m.results()
+			l := r.Ledger
+			if r.SellPolicy != m.sellPolicy() || l.Rewards == 0 || l.Fees == 0 || l.Electricity == 0 {
+				t.Fatalf("%s %s: %v %+v", engine, m.Address, r.SellPolicy, l)
+			}
+			got := float64(m.Balance) + m.balanceFrac
+			want := float64(starts[m.Address]) + l.Income() - l.BlockCosts - l.Sold
+			if math.Abs(got-want) > 1e-6 {
+				t.Errorf("%s %s: balance %v, want %v (%+v)", engine, m.Address, got, want, l)
+			}
+			if want := m.electricityPerTick() * float64(sim.TickSamples); math.Abs(l.Electricity-want) > 1e-6 {
+				t.Errorf("%s %s: electricity %v, want %v", engine, m.Address, l.Electricity, want)
+			}
+			// Four whole periods, and the rest of the run.
+			if len(m.balances) != 5 || m.balances[4].tick != sim.TickSamples {
+				t.Errorf("%s %s: %d samples", engine, m.Address, len(m.balances))
+			}
+			if m.Address == "0000ff" && m.Balance > 10 {
+				t.Errorf("%s: kept %d", engine, m.Balance)
+			}
+		}
+	}
+}
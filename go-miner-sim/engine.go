@@ -0,0 +1,169 @@
+package main
+
+import (
+	"container/heap"
+	"fmt"
+	"math"
+)
+
+// Simulation engines.
+// The tick engine polls every miner every tick; the event engine jumps from event to event.
+const (
+	EngineTick  = "tick"
+	EngineEvent = "event"
+)
+
+func validEngine(engine string) error {
+	switch engine {
+	case "", EngineTick, EngineEvent:
+		return nil
+	}
+	return fmt.Errorf("unknown engine: %q (want %s or %s)", engine, EngineTick, EngineEvent)
+}
+
+type eventKind int
+
+const (
+	// Deliveries sort before discoveries at the same tick, the way doTick
+	// processes received blocks before mining.
+	eventDelivery eventKind = iota
+	eventDiscovery
+)
+
+type event struct {
+	tick  int64
+	kind  eventKind
+	seq   int64 // insertion order, breaks ties
+	miner *Miner
+
+	delivery *delivery // delivery: the block delivered
+	parent   *Block    // discovery: the head the miner was mining on; stale if the head has moved
+}
+
+// eventQueue is a min-heap of events ordered by tick, kind, then insertion.
+type eventQueue struct {
+	events []*event
+	seq    int64
+}
+
+func (q *eventQueue) Len() int { return len(q.events) }
+func (q *eventQueue) Less(i, j int) bool {
+	a, b := q.events[i], q.events[j]
+	if a.tick != b.tick {
+		return a.tick < b.tick
+	}
+	if a.kind != b.kind {
+		return a.kind < b.kind
+	}
+	return a.seq < b.seq
+}
+func (q *eventQueue) Swap(i, j int)      { q.events[i], q.events[j] = q.events[j], q.events[i] }
+func (q *eventQueue) Push(x interface{}) { q.events = append(q.events, x.(*event)) }
+func (q *eventQueue) Pop() interface{} {
+	e := q.events[len(q.events)-1]
+	q.events = q.events[:len(q.events)-1]
+	return e
+}
+
+func (q *eventQueue) schedule(e *event) {
+	q.seq++
+	e.seq = q.seq
+	heap.Push(q, e)
+}
+
+// discoveryTicks samples the number of ticks until a miner with the given hashes per tick
+// solves a block of the given difficulty, counting the first trial tick as 1.
+// fakeHashimoto succeeds with probability p = hashes / difficulty * networkLambda each tick,
+// so the wait is geometric: the exponential distribution discretized to ticks.
+func (s *Simulation) discoveryTicks(hashratePerTick, parentDifficulty float64) int64 {
+	p := hashratePerTick / parentDifficulty * s.networkLambda
+	if p >= 1 {
+		return 1
+	}
+	if p <= 0 {
+		return math.MaxInt64 / 2
+	}
+	u := 1 - s.rng(rngDiscovery).Float64() // (0, 1]
+	return int64(math.Floor(math.Log(u)/math.Log1p(-p))) + 1
+}
+
+// deliver schedules a received block for import by the miner when it's due.
+// Deliveries due in this tick are imported next tick, the way doTick would pick them up at the miner's next tick.
+func (s *Simulation) deliver(m *Miner, d *delivery) {
+	tick := d.due()
+	if tick <= s.now {
+		tick = s.now + 1
+	}
+	s.events.schedule(&event{tick: tick, kind: eventDelivery, miner: m, delivery: d})
+}
+
+// runEvents is the event engine's run.
+// Instead of trying every miner every tick, each miner gets a discovery event sampled for its head;
+// when its head moves, a new one is sampled (the wait is memoryless) and the old one goes stale.
+// Block deliveries are events too. afterTick is called after every event.
+func (s *Simulation) runEvents(miners []*Miner, afterTick func(tick int64) error) error {
+	s.events = &eventQueue{}
+	defer func() { s.events = nil }()
+
+	// mining holds the head each miner's pending discovery event was sampled for.
+	mining := make(map[*Miner]*Block, len(miners))
+	reschedule := func(m *Miner, from int64) {
+		mining[m] = m.head
+		at := from - 1 + s.discoveryTicks(float64(m.HashesPerTick), float64(m.head.d))
+		if at <= s.TickSamples {
+			s.events.schedule(&event{tick: at, kind: eventDiscovery, miner: m, parent: m.head})
+		}
+	}
+	for _, m := range miners {
+		reschedule(m, 1)
+	}
+
+	for s.events.Len() > 0 {
+		// Network events change the links before the next event, so syncs scheduled at a heal come in order.
+		if next := s.nextNetworkChange(); next <= s.events.events[0].tick && next <= s.TickSamples {
+			s.changeNetwork(miners, next)
+			continue
+		}
+		e := heap.Pop(s.events).(*event)
+		if e.tick > s.TickSamples {
+			break
+		}
+		s.settle(miners, e.tick-1)
+		s.setNow(miners, e.tick)
+
+		switch e.kind {
+		case eventDelivery:
+			e.miner.processDelivery(e.delivery)
+		case eventDiscovery:
+			if e.parent != e.miner.head {
+				continue // stale
+			}
+			e.miner.mineBlock()
+		}
+		if s.err != nil {
+			return s.err
+		}
+
+		// Miners whose heads moved start over on the new head.
+		// Everyone but the discovering miner can still mine in this tick.
+		for _, m := range miners {
+			if m.head == mining[m] {
+				continue
+			}
+			from := s.now
+			if e.kind == eventDiscovery && m == e.miner {
+				from++
+			}
+			reschedule(m, from)
+		}
+		s.observeNetwork(miners)
+
+		if afterTick != nil {
+			if err := afterTick(s.now); err != nil {
+				return err
+			}
+		}
+	}
+	s.settle(miners, s.TickSamples)
+	return nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"container/heap"
+	"math"
+	"testing"
+)
+
+func TestEventQueue_Order(t *testing.T) {
+	q := &eventQueue{}
+	q.schedule(&event{tick: 5, kind: eventDiscovery})
+	q.schedule(&event{tick: 5, kind: eventDelivery})
+	q.schedule(&event{tick: 3, kind: eventDiscovery})
+	q.schedule(&event{tick: 5, kind: eventDelivery})
+
+	want := []struct {
+		tick int64
+		kind eventKind
+		seq  int64
+	}{{3, eventDiscovery, 3}, {5, eventDelivery, 2}, {5, eventDelivery, 4}, {5, eventDiscovery, 1}}
+	for i, w := range want {
+		e := heap.Pop(q).(*event)
+		if e.tick != w.tick || e.kind != w.kind || e.seq != w.seq {
+			t.Fatalf("event %d: got %+v, want %+v", i, e, w)
+		}
+	}
+}
+
+func TestSimulation_DiscoveryTicks(t *testing.T) {
+	sim := NewSimulation(DefaultSimulationConfig())
+	hashes, d := float64(genesisDifficulty)/3, float64(genesisDifficulty)
+	p := hashes / d * sim.networkLambda
+
+	n, sum := 100_000, 0.0
+	for i := 0; i < n; i++ {
+		sum += float64(sim.discoveryTicks(hashes, d))
+	}
+	if mean, want := sum/float64(n), 1/p; math.Abs(mean-want)/want > 0.02 {
+		t.Fatalf("mean wait %0.1f ticks, want %0.1f", mean, want)
+	}
+}
+
+// TestEventEngine_Equivalent checks that the event engine agrees with the tick engine
+// on the network's vital statistics, within the replicates' confidence intervals.
+func TestEventEngine_Equivalent(t *testing.T) {
+	sc := defaultScenario()
+	sc.Seed = 1
+	sc.ConsensusAlgorithm = TDTABS.String()
+	sc.Globals.TickSamples = 10 * 60 * 60 * 3
+
+	summaries := map[string][]minerSummary{}
+	for _, engine := range []string{EngineTick, EngineEvent} {
+		sc.Globals.Engine = engine
+		results, err := runBatch(sc, 12, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		summaries[engine] = summarizeBatch(results)
+	}
+
+	for _, metric := range []string{"intervalsMeanSeconds", "kMean", "difficultiesRelGenesisMean", "winRate"} {
+		a, b := summaries[EngineTick][0].Metrics[metric], summaries[EngineEvent][0].Metrics[metric]
+		if math.Abs(a.Mean-b.Mean) > (a.CI95High-a.Mean)+(b.CI95High-b.Mean) {
+			t.Errorf("%s: tick %v, event %v", metric, a, b)
+		}
+	}
+}
+
+// TestEventEngine_ZeroLatency checks that blocks relayed without delay are checked against the time they arrive,
+// not the receiver's last event: none is rejected as from the future, and the miners agree on the head.
+func TestEventEngine_ZeroLatency(t *testing.T) {
+	sc := defaultScenario()
+	sc.Seed = 7
+	sc.Globals.Engine = EngineEvent
+	sc.Globals.TickSamples = 10 * 60 * 60
+	latency := 0.0
+	sc.Globals.LatencySeconds = &latency
+	if err := sc.validate(); err != nil {
+		t.Fatal(err)
+	}
+	sim := NewSimulation(sc.config())
+	miners, err := sc.miners(sim, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sim.run(miners, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range miners {
+		if r := m.results(); r.Rejected != 0 {
+			t.Errorf("%s: rejected %d blocks", m.Address, r.Rejected)
+		}
+		if m.head != miners[0].head {
+			t.Errorf("%s: head %s, want %s", m.Address, m.head, miners[0].head)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ForkChoice is a consensus rule: how a miner weighs chains, decides between two blocks,
+// and computes the TABS of the blocks it builds.
+// Rules are registered by name (see RegisterForkChoice), and miners and scenarios refer to them by it.
+// arbitrateBlocks falls back to the miner's own preferences (lower height, self-interest, coin toss)
+// when the rule can't decide.
+type ForkChoice interface {
+	// String returns the rule's name, eg. "TD".
+	String() string
+
+	// Score is the weight of the chain ending at the block, as seen by a miner with the tree; higher is heavier.
+	Score(bt *BlockTree, b *Block) int64
+
+	// Compare is positive if a is preferred to b, negative if b is preferred to a,
+	// and zero if the rule leaves it to TieBreak.
+	Compare(bt *BlockTree, a, b *Block) int
+
+	// TieBreak decides between blocks which Compare left undecided, naming the decision condition.
+	// It returns nil if the rule has no opinion.
+	TieBreak(a, b *Block) (winner *Block, condition string)
+
+	// BlockTABS is the TABS of a block built on parent by a miner with the local TAB.
+	// tabsFallCount is how many blocks in sequence, this one included, have had falling TABS.
+	BlockTABS(s *Simulation, parent *Block, tabsFallCount, localTAB int64) int64
+}
+
+// TipChooser is a ForkChoice that walks the tree to the head, like GHOST,
+// instead of picking one of the two blocks arbitrateBlocks decides between.
+type TipChooser interface {
+	// Tip is the head of a miner whose head is a when it gets b.
+	Tip(bt *BlockTree, a, b *Block) *Block
+}
+
+var (
+	// TD is the heaviest chain by total difficulty.
+	TD ForkChoice = &scoreRule{name: "TD", score: totalDifficulty}
+
+	// TDTABS is the heaviest chain by total TD*TABS.
+	TDTABS ForkChoice = &scoreRule{name: "TDTABS", score: totalDifficultyTABS}
+
+	// TDTABS_step is TDTABS with a sequence-derived step algorithm for the TABS numerator.
+	TDTABS_step ForkChoice = &scoreRule{
+		name:  "TDTABS_step",
+		score: totalDifficultyTABS,
+		tabs: func(s *Simulation, parent *Block, tabsFallCount, localTAB int64) int64 {
+			return s.getTABS_step(parent.tabs, tabsFallCount, localTAB)
+		},
+	}
+
+	// TimeDesc (FreshnessPreferred) is TD, then the later timestamp.
+	TimeDesc ForkChoice = &scoreRule{name: "TimeDesc", score: totalDifficulty, tieBreak: preferTimestamp(true)}
+
+	// TimeAsc (EarlinessPreferred) is TD, then the earlier timestamp,
+	// like go-block-step's decideTime experiment.
+	TimeAsc ForkChoice = &scoreRule{name: "TimeAsc", score: totalDifficulty, tieBreak: preferTimestamp(false)}
+)
+
+func init() {
+	for _, fc := range []ForkChoice{TD, TDTABS, TDTABS_step, TimeDesc, TimeAsc} {
+		RegisterForkChoice(fc)
+	}
+}
+
+var (
+	forkChoices     = map[string]ForkChoice{}
+	forkChoiceNames []string // in registration order
+)
+
+// RegisterForkChoice makes the rule available by its name.
+// It panics if the name is taken.
+func RegisterForkChoice(fc ForkChoice) {
+	name := fc.String()
+	if _, ok := forkChoices[name]; ok {
+		panic(fmt.Sprintf("fork choice %q registered twice", name))
+	}
+	forkChoices[name] = fc
+	forkChoiceNames = append(forkChoiceNames, name)
+}
+
+func parseConsensusAlgorithm(s string) (ForkChoice, error) {
+	if fc, ok := forkChoices[s]; ok {
+		return fc, nil
+	}
+	return nil, fmt.Errorf("unknown consensus algorithm: %q (want one of %s)", s, strings.Join(forkChoiceNames, ", "))
+}
+
+// scoreRule is a ForkChoice preferring the block with the higher score.
+// The tie break and TABS computation are optional; the TABS defaults to Simulation.getTABS.
+type scoreRule struct {
+	name     string
+	score    func(b *Block) int64
+	tieBreak func(a, b *Block) (*Block, string)
+	tabs     func(s *Simulation, parent *Block, tabsFallCount, localTAB int64) int64
+}
+
+func (r *scoreRule) String() string { return r.name }
+
+func (r *scoreRule) Score(_ *BlockTree, b *Block) int64 { return r.score(b) }
+
+func (r *scoreRule) Compare(bt *BlockTree, a, b *Block) int {
+	return compareScores(r, bt, a, b)
+}
+
+func (r *scoreRule) TieBreak(a, b *Block) (*Block, string) {
+	if r.tieBreak == nil {
+		return nil, ""
+	}
+	return r.tieBreak(a, b)
+}
+
+func (r *scoreRule) BlockTABS(s *Simulation, parent *Block, tabsFallCount, localTAB int64) int64 {
+	if r.tabs == nil {
+		return s.getTABS(parent.tabs, localTAB)
+	}
+	return r.tabs(s, parent, tabsFallCount, localTAB)
+}
+
+// compareScores compares blocks by the rule's scores; the higher wins.
+func compareScores(fc ForkChoice, bt *BlockTree, a, b *Block) int {
+	sa, sb := fc.Score(bt, a), fc.Score(bt, b)
+	if sa > sb {
+		return 1
+	} else if sb > sa {
+		return -1
+	}
+	return 0
+}
+
+func totalDifficulty(b *Block) int64     { return b.td }
+func totalDifficultyTABS(b *Block) int64 { return b.ttdtabs }
+
+// preferTimestamp breaks ties by timestamp: the fresher block if fresh, else the earlier one.
+func preferTimestamp(fresh bool) func(a, b *Block) (*Block, string) {
+	return func(a, b *Block) (*Block, string) {
+		if a.s == b.s {
+			return nil, ""
+		}
+		if (b.s > a.s) == fresh {
+			return b, "timestamp"
+		}
+		return a, "timestamp"
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"testing"
+)
+
+// lowHash is a prototype rule, written the way a new rule would be in its own file:
+// TD, then the lower hash, a coin toss that all miners agree on.
+type lowHash struct{}
+
+func (lowHash) String() string                           { return "test_lowHash" }
+func (lowHash) Score(_ *BlockTree, b *Block) int64       { return b.td }
+func (r lowHash) Compare(bt *BlockTree, a, b *Block) int { return compareScores(r, bt, a, b) }
+func (lowHash) TieBreak(a, b *Block) (*Block, string) {
+	if a.h < b.h {
+		return a, "hash_low"
+	}
+	return b, "hash_low"
+}
+func (lowHash) BlockTABS(s *Simulation, parent *Block, _, localTAB int64) int64 {
+	return s.getTABS(parent.tabs, localTAB)
+}
+
+func init() {
+	RegisterForkChoice(lowHash{})
+}
+
+func TestParseConsensusAlgorithm(t *testing.T) {
+	for _, name := range forkChoiceNames {
+		fc, err := parseConsensusAlgorithm(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fc.String() != name {
+			t.Errorf("%s: got %s", name, fc)
+		}
+	}
+	if _, err := parseConsensusAlgorithm("GHOSTish"); err == nil {
+		t.Error("unknown rule parsed")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("duplicate registration did not panic")
+		}
+	}()
+	RegisterForkChoice(lowHash{})
+}
+
+// TestForkChoice_Registered runs miners on a rule the miner knows nothing about but its name.
+func TestForkChoice_Registered(t *testing.T) {
+	sc := defaultScenario()
+	sc.Seed = 3
+	sc.ConsensusAlgorithm = lowHash{}.String()
+	sc.Population.Count = 4
+	sc.Globals.TickSamples = 10 * 60 * 30
+	if err := sc.validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	miners, err := runReplicate(sc, sc.Seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range miners {
+		if m.ConsensusAlgorithm != (lowHash{}) {
+			t.Fatalf("%s: runs %s", m.Address, m.ConsensusAlgorithm)
+		}
+		if m.decisionConditionTallies["hash_low"] == 0 {
+			t.Errorf("%s: no tie broken by hash", m.Address)
+		}
+		if m.decisionConditionTallies["random"] != 0 {
+			t.Errorf("%s: tossed coins", m.Address)
+		}
+	}
+}
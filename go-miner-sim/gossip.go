@@ -0,0 +1,134 @@
+package main
+
+// delivery is one copy of a block on its way to one miner.
+// Each recipient gets its own record; the block itself is never touched in transit.
+type delivery struct {
+	block *Block
+	from  *Miner
+	hops  int   // links travelled, counting this one; 1 for a block straight from its miner
+	sent  int64 // tick the sender relayed the block
+	delay Delay
+}
+
+func (d *delivery) due() int64 {
+	return d.sent + d.delay.Total()
+}
+
+// arrival records how a miner first came by another miner's block.
+type arrival struct {
+	hops  int
+	ticks int64 // since the block was mined
+}
+
+// validationTicks is the time a miner takes to validate the block before importing (and relaying) it.
+func (s *Simulation) validationTicks(b *Block) int64 {
+	seconds := s.ValidationSeconds
+	if s.ValidationGasPerSecond > 0 {
+		seconds += float64(b.gas) / s.ValidationGasPerSecond
+	}
+	return int64(seconds * float64(s.TicksPerSecond))
+}
+
+// broadcastBlock sends the block to each neighbor, over the neighbor's link.
+// hops is how many links the miner's own copy travelled; zero for the miner's own blocks,
+// which are withheld until the miner's strategy publishes them (see publish), and then subject to SendDelay.
+func (m *Miner) broadcastBlock(b *Block, hops int) {
+	if hops == 0 {
+		m.withheld = append(m.withheld, b)
+		m.publish(m.strategy().Mined(m, b))
+		return
+	}
+	m.relay(b, hops, 0)
+}
+
+// relay sends the block to each neighbor, withheld for the ticks.
+func (m *Miner) relay(b *Block, hops int, withhold int64) {
+	for _, l := range m.neighbors {
+		if l.carries != nil && !l.carries(b) {
+			continue
+		}
+		l.to.receiveBlock(&delivery{
+			block: b,
+			from:  m,
+			hops:  hops + 1,
+			sent:  m.sim.now,
+			delay: Delay{
+				withhold: withhold,
+				material: l.latency() + m.sim.transferTicks(b),
+			},
+		})
+	}
+}
+
+// receiveBlock takes a delivery off the wire and schedules its import after validation
+// and any postponement. Deliveries without delay are imported right away.
+func (m *Miner) receiveBlock(d *delivery) {
+	d.delay.validate = m.sim.validationTicks(d.block)
+	if m.ReceiveDelay != nil {
+		d.delay.postpone = m.ReceiveDelay(d.block)
+	}
+	if d.block.miner != m.Address {
+		d.delay.postpone += m.strategy().Postpone(m, d.block)
+	}
+	if d.delay.Total() > 0 {
+		m.queueDelivery(d)
+		return
+	}
+	m.processDelivery(d)
+}
+
+// queueDelivery schedules the delivery for its due tick.
+func (m *Miner) queueDelivery(d *delivery) {
+	if m.sim.events != nil {
+		m.sim.deliver(m, d)
+		return
+	}
+	m.deliveries[d.due()] = append(m.deliveries[d.due()], d)
+}
+
+// processDelivery imports a delivered block, noting how it got here if it's the first copy.
+// Blocks with invalid timestamps are rejected, and so are their descendants, however late they come;
+// future blocks are held until the miner's clock reaches them, and orphans until their parent comes.
+func (m *Miner) processDelivery(d *delivery) {
+	b := d.block
+	if m.rejected[b.h] {
+		return
+	}
+	if m.rejected[b.ph] {
+		m.reject(b)
+		return
+	}
+	if m.Blocks.GetBlockByHash(b.ph) == nil {
+		m.orphans[b.ph] = append(m.orphans[b.ph], d)
+		return
+	}
+	if !m.validTimestamp(b) {
+		m.reject(b)
+		return
+	}
+	if m.futureBlock(b) {
+		d.delay.postpone += b.s - d.due()
+		m.queueDelivery(d)
+		return
+	}
+	if _, seen := m.arrivals[b.h]; !seen && b.miner != m.Address {
+		m.arrivals[b.h] = arrival{hops: d.hops, ticks: m.sim.now - b.t}
+	}
+	m.importBlock(b, d.hops)
+	m.adoptOrphans(b)
+}
+
+// reject marks the block invalid, along with the orphans waiting for it.
+func (m *Miner) reject(b *Block) {
+	m.rejected[b.h] = true
+	m.adoptOrphans(b)
+}
+
+// adoptOrphans processes the deliveries waiting for the block, now that it's imported or rejected.
+func (m *Miner) adoptOrphans(b *Block) {
+	waiting := m.orphans[b.h]
+	delete(m.orphans, b.h)
+	for _, d := range waiting {
+		m.processDelivery(d)
+	}
+}
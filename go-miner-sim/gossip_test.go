@@ -0,0 +1,53 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestGossip_Line relays a block down a line of miners and checks each one's hop count and arrival time.
+func TestGossip_Line(t *testing.T) {
+	config := DefaultSimulationConfig()
+	config.CountMiners = 4
+	config.LatencySeconds = 1
+	config.ValidationSeconds = 0.5
+	sim := NewSimulation(config)
+	miners := minersNormal(sim, nil, func(m *Miner) {
+		m.ConsensusAlgorithm = TD
+		m.HashesPerTick = 0 // only the block mined below
+	})
+
+	path := filepath.Join(t.TempDir(), "line.txt")
+	ioutil.WriteFile(path, []byte("0 1\n1 2\n2 3 2\n"), os.ModePerm)
+	sim.Topology = TopologyConfig{Kind: TopologyFile, EdgesFile: path}
+	if err := sim.connect(miners); err != nil {
+		t.Fatal(err)
+	}
+
+	sim.now, miners[0].tick = 1, 1
+	miners[0].mineBlock()
+	b := miners[0].head
+
+	for tick := int64(2); tick < 100; tick++ {
+		sim.now = tick
+		for _, m := range miners {
+			m.doTick(tick)
+		}
+	}
+
+	// Each hop takes latency (10 ticks, 20 on the last link) plus validation (5 ticks).
+	for i, want := range []arrival{{1, 15}, {2, 30}, {3, 55}} {
+		m := miners[i+1]
+		if got := m.arrivals[b.h]; got != want {
+			t.Errorf("miner %d: got arrival %+v, want %+v", i+1, got, want)
+		}
+		if m.head != b {
+			t.Errorf("miner %d: head is not the relayed block", i+1)
+		}
+	}
+	if _, ok := miners[0].arrivals[b.h]; ok {
+		t.Error("miner 0 recorded an arrival of its own block")
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Latency distributions, written as specs: "<kind>[:<param>...]", all values in seconds.
+//
+//	constant:<seconds>          (or just <seconds>)
+//	uniform:<min>:<max>
+//	lognormal:<median>:<sigma>  sigma is that of the underlying normal
+//	empirical:<file>            samples drawn uniformly from a file of seconds, one per line
+const (
+	LatencyConstant  = "constant"
+	LatencyUniform   = "uniform"
+	LatencyLognormal = "lognormal"
+	LatencyEmpirical = "empirical"
+)
+
+// Block size model, used for bandwidth-limited propagation.
+const (
+	blockHeaderBytes = 540   // roughly an RLP-encoded header
+	txBytes          = 110   // roughly a plain value transfer
+	txGas            = 21000 // intrinsic gas of a plain value transfer
+)
+
+// latencyDist samples a link's latency in seconds.
+type latencyDist interface {
+	sample(r *rand.Rand) float64
+}
+
+type constantLatency float64
+
+func (d constantLatency) sample(*rand.Rand) float64 { return float64(d) }
+
+type uniformLatency struct{ min, max float64 }
+
+func (d uniformLatency) sample(r *rand.Rand) float64 { return d.min + r.Float64()*(d.max-d.min) }
+
+type lognormalLatency struct{ mu, sigma float64 }
+
+func (d lognormalLatency) sample(r *rand.Rand) float64 {
+	return math.Exp(d.mu + d.sigma*r.NormFloat64())
+}
+
+type empiricalLatency []float64
+
+func (d empiricalLatency) sample(r *rand.Rand) float64 { return d[r.Intn(len(d))] }
+
+// parseLatencyDist parses a latency spec (see LatencyConstant and friends).
+func parseLatencyDist(spec string) (latencyDist, error) {
+	parts := strings.Split(strings.TrimSpace(spec), ":")
+	kind, params := parts[0], parts[1:]
+	if v, err := strconv.ParseFloat(kind, 64); err == nil && len(params) == 0 {
+		kind, params = LatencyConstant, []string{strconv.FormatFloat(v, 'g', -1, 64)}
+	}
+
+	if kind == LatencyEmpirical {
+		if len(params) == 0 {
+			return nil, fmt.Errorf("latency %q: want empirical:<file>", spec)
+		}
+		return loadEmpiricalLatency(strings.Join(params, ":"))
+	}
+
+	want := map[string]int{LatencyConstant: 1, LatencyUniform: 2, LatencyLognormal: 2}[kind]
+	if want == 0 {
+		return nil, fmt.Errorf("latency %q: unknown distribution %q", spec, kind)
+	}
+	if len(params) != want {
+		return nil, fmt.Errorf("latency %q: %s takes %d parameters", spec, kind, want)
+	}
+	vs := make([]float64, len(params))
+	for i, p := range params {
+		v, err := strconv.ParseFloat(p, 64)
+		if err != nil || v < 0 {
+			return nil, fmt.Errorf("latency %q: bad parameter %q", spec, p)
+		}
+		vs[i] = v
+	}
+
+	switch kind {
+	case LatencyConstant:
+		return constantLatency(vs[0]), nil
+	case LatencyUniform:
+		if vs[0] > vs[1] {
+			return nil, fmt.Errorf("latency %q: min exceeds max", spec)
+		}
+		return uniformLatency{vs[0], vs[1]}, nil
+	default: // LatencyLognormal
+		if vs[0] == 0 {
+			return nil, fmt.Errorf("latency %q: median must be positive", spec)
+		}
+		return lognormalLatency{math.Log(vs[0]), vs[1]}, nil
+	}
+}
+
+func loadEmpiricalLatency(path string) (latencyDist, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	samples := empiricalLatency{}
+	sc := bufio.NewScanner(f)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		v, err := strconv.ParseFloat(text, 64)
+		if err != nil || v < 0 {
+			return nil, fmt.Errorf("%s:%d: bad latency %q", path, line, text)
+		}
+		samples = append(samples, v)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("%s: no latency samples", path)
+	}
+	return samples, nil
+}
+
+// latencyFunc returns a sampler of the distribution in ticks.
+func (s *Simulation) latencyFunc(d latencyDist) func() int64 {
+	return func() int64 {
+		return int64(d.sample(s.rng(rngLatency)) * float64(s.TicksPerSecond))
+	}
+}
+
+// size estimates the block's encoded size in bytes.
+func (b *Block) size() int64 {
+	return blockHeaderBytes + b.txs*txBytes
+}
+
+// transferTicks is the time it takes to push the block through a link of the simulation's bandwidth.
+// It is zero if bandwidth is unlimited.
+func (s *Simulation) transferTicks(b *Block) int64 {
+	if s.BandwidthBytesPerSecond <= 0 {
+		return 0
+	}
+	return int64(float64(b.size()) / s.BandwidthBytesPerSecond * float64(s.TicksPerSecond))
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestParseLatencyDist(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	sample := func(d latencyDist) []float64 {
+		xs := make([]float64, 20_000)
+		for i := range xs {
+			xs[i] = d.sample(r)
+		}
+		sort.Float64s(xs)
+		return xs
+	}
+
+	for _, spec := range []string{"1.5", "constant:1.5"} {
+		d, err := parseLatencyDist(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := d.sample(r); v != 1.5 {
+			t.Errorf("%s: got %v", spec, v)
+		}
+	}
+
+	d, err := parseLatencyDist("uniform:0.5:2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if xs := sample(d); xs[0] < 0.5 || xs[len(xs)-1] > 2 || math.Abs(xs[len(xs)/2]-1.25) > 0.05 {
+		t.Errorf("uniform: min %v max %v median %v", xs[0], xs[len(xs)-1], xs[len(xs)/2])
+	}
+
+	d, err = parseLatencyDist("lognormal:1:0.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if xs := sample(d); math.Abs(xs[len(xs)/2]-1) > 0.05 {
+		t.Errorf("lognormal: median %v", xs[len(xs)/2])
+	}
+
+	path := filepath.Join(t.TempDir(), "latencies.txt")
+	ioutil.WriteFile(path, []byte("# seconds\n0.25\n\n0.75\n"), os.ModePerm)
+	d, err = parseLatencyDist("empirical:" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, x := range sample(d) {
+		if x != 0.25 && x != 0.75 {
+			t.Fatalf("empirical: sampled %v", x)
+		}
+	}
+
+	for _, spec := range []string{"", "gamma:1", "uniform:1", "uniform:2:1", "lognormal:0:1", "constant:-1", "empirical:/does/not/exist"} {
+		if _, err := parseLatencyDist(spec); err == nil {
+			t.Errorf("%q: expected error", spec)
+		}
+	}
+}
+
+func TestSimulation_TransferTicks(t *testing.T) {
+	config := DefaultSimulationConfig()
+	sim := NewSimulation(config)
+	b := &Block{txs: 100}
+	if got := sim.transferTicks(b); got != 0 {
+		t.Fatalf("unlimited bandwidth: got %d ticks", got)
+	}
+
+	config.BandwidthBytesPerSecond = float64(b.size()) / 2 // two seconds per block
+	sim = NewSimulation(config)
+	if got, want := sim.transferTicks(b), 2*config.TicksPerSecond; got != want {
+		t.Fatalf("got %d ticks, want %d", got, want)
+	}
+	if sim.transferTicks(&Block{txs: 200}) <= sim.transferTicks(b) {
+		t.Fatal("bigger blocks should take longer")
+	}
+}
@@ -3,45 +3,44 @@ package main
 import (
 	"fmt"
 	"image/color"
+	"log"
 	"math"
-	"math/rand"
+	"os"
 	"sort"
-	"time"
 
-	exprand "golang.org/x/exp/rand"
-	"gonum.org/v1/gonum/stat/distuv"
+	"github.com/montanaflynn/stats"
 )
 
-func init() {
-	rand.Seed(time.Now().UnixNano())
-}
-
 func main() {
-
-}
-
-// We'll use this for TAB score generation for each block.
-// A normal distribution may not be the best fit. TODO.
-var normalDist = distuv.Normal{
-	Mu:    float64(genesisBlockTABS),
-	Sigma: float64(genesisBlockTABS) / 4, // I just made this up. TODO.
-	Src:   exprand.NewSource(uint64(time.Now().UnixNano())),
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "run":
+		err = cmdRun(os.Args[2:])
+	case "batch":
+		err = cmdBatch(os.Args[2:])
+	case "sweep":
+		err = cmdSweep(os.Args[2:])
+	case "doublespend":
+		err = cmdDoubleSpend(os.Args[2:])
+	case "-h", "-help", "--help", "help":
+		usage()
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
 }
 
-// Globals
-var ticksPerSecond int64 = 10
-var tickSamples = ticksPerSecond * int64((time.Hour * 6).Seconds())
-var networkLambda = (float64(1) / float64(13)) / float64(ticksPerSecond)
-var countMiners = int64(12)
-var minerNeighborRate float64 = 0.5 // 0.7
-var blockReward int64 = 3
-
-var latencySecondsDefault float64 = 1                  // 1.23               // 2.5
-var delaySecondsDefault float64 = 0                    // miner hesitancy to broadcast solution
-var receivePostponeSecondsDefault float64 = 100 / 1000 // 80 milliseconds, ish
-
-var tabsAdjustmentDenominator = int64(128) // int64(4096) <-- 4096 is the 'equilibrium' value, lower values prefer richer miners more (devaluing hashrate)
-const genesisBlockTABS int64 = 10_000      // tabs starting value
+const genesisBlockTABS int64 = 10_000 // tabs starting value
 const genesisDifficulty = 10_000_000_000
 
 // presumeMinerShareBalancePerBlockDenominator being 300 means that we assume that a miner's balance accounts for 1/300
@@ -49,22 +48,6 @@ const genesisDifficulty = 10_000_000_000
 // This value is used to set the starting balance for miners.
 const presumeMinerShareBalancePerBlockDenominator = 100
 
-var txPoolBlockTABs = make(map[int64]int64)
-
-var genesisBlock = &Block{
-	i:         0,
-	s:         0,
-	d:         genesisDifficulty,
-	td:        genesisDifficulty,
-	tabs:      genesisBlockTABS,
-	ttdtabs:   genesisBlockTABS * genesisDifficulty,
-	miner:     "00F00F",
-	delay:     Delay{},
-	h:         fmt.Sprintf("%08x", rand.Int63()),
-	ph:        "00000000",
-	canonical: true,
-}
-
 type Miners []*Miner
 
 func (ms Miners) headMax() (max int64) {
@@ -83,16 +66,29 @@ type minerEvent struct {
 }
 
 type Miner struct {
+	sim *Simulation
+
 	Index   int64
 	Address string
-	Blocks  BlockTree
+	Blocks  *BlockTree // every block the miner has seen; shared with other miners, never modified
+	canon   Chain      // the miner's own canonical chain
 
 	Hashrate      float64
 	HashesPerTick int64 // per tick
 	Balance       int64 // Wei
+	capital       int64 // the starting balance
 	BalanceCap    int64 // Max Wei this miner will hold. Use 0 for no limit hold 'em.
 	CostPerBlock  int64 // cost to miner, expended after each block win (via tx on text block)
 
+	// SellPolicy is what the miner takes out of its balance when it settles; nil is Hold. Only the economics model uses it.
+	SellPolicy SellPolicy
+
+	ledger      ledger
+	balanceFrac float64 // the fraction of a unit credited beyond Balance
+	owed        float64 // electricity costs not yet paid
+	settled     int64   // the tick the miner last settled at
+	balances    []balanceSample
+
 	Latency func() int64
 
 	// SendDelay represents a miner withholding a discovered puzzle solution, ie. "selfish mining"
@@ -103,7 +99,23 @@ type Miner struct {
 	// This is experimental; is this scheme profitable?
 	ReceiveDelay func(block *Block) int64
 
-	ConsensusAlgorithm             ConsensusAlgorithm
+	// Strategy is the miner's play, eg. selfish mining; nil is Honest.
+	Strategy Strategy
+
+	withheld   Blocks // the miner's own blocks its strategy hasn't published, by number
+	publicHead *Block // the highest published block the miner knows of
+	race       int64  // the height of a tie race the miner's strategy is in; 0 if none
+	attack     *doubleSpendAttack
+	honestTAB  float64 // the TAB the miner's blocks would have had honestly, summed
+	playedTAB  float64 // and the TAB its strategy brought to them
+
+	// TxPolicy orders the mempool for the miner's blocks; nil is FeeGreedy. Only the mempool model uses it.
+	TxPolicy TxPolicy
+
+	// TimestampPolicy stamps the miner's blocks; nil is HonestTimestamp.
+	TimestampPolicy TimestampPolicy
+
+	ConsensusAlgorithm             ForkChoice
 	ConsensusArbitrations          int
 	ConsensusObjectiveArbitrations int
 
@@ -114,18 +126,23 @@ type Miner struct {
 	reorgs                   map[int64]reorg
 	decisionConditionTallies map[string]int
 
+	included map[int64]bool // the transactions on the miner's chain, by id
+
 	head *Block
 
-	neighbors      []*Miner
-	receivedBlocks map[int64]Blocks
+	neighbors  []*link
+	deliveries map[int64][]*delivery  // by due tick
+	arrivals   map[string]arrival     // by block hash, for blocks from other miners
+	rejected   map[string]bool        // blocks the miner rejected as invalid, and their descendants, by hash
+	orphans    map[string][]*delivery // blocks waiting for their parent, by the parent's hash
 
 	cord chan minerEvent
 
 	tick int64
 }
 
-func getBlockDifficulty(parent *Block, uncles bool, interval int64) int64 {
-	x := interval / (9 * ticksPerSecond) // 9 SECONDS
+func (s *Simulation) getBlockDifficulty(parent *Block, uncles bool, interval int64) int64 {
+	x := interval / (9 * s.TicksPerSecond) // 9 SECONDS
 	y := 1 - x
 	if uncles {
 		y = 2 - x
@@ -136,43 +153,26 @@ func getBlockDifficulty(parent *Block, uncles bool, interval int64) int64 {
 	return int64(float64(parent.d) + (float64(y) / 2048 * float64(parent.d)))
 }
 
-func getTABS(parentTabs, localTAB int64) (tabs int64) {
-	scalarNumerator := int64(0)
-	if localTAB > parentTabs {
-		scalarNumerator = 1
-	} else if localTAB < parentTabs {
-		scalarNumerator = -1
-	}
-
-	numerator := tabsAdjustmentDenominator + scalarNumerator // [127|128|129]/128, [4095|4096|4097]/4096
-
-	return int64(float64(parentTabs) * float64(numerator) / float64(tabsAdjustmentDenominator))
-}
-
-func getTABS_step(parentTabs, tabFallCount, localTAB int64) (tabs int64) {
-	scalarNumerator := int64(0)
-	if localTAB > parentTabs {
-		scalarNumerator = 1
-	} else if localTAB < parentTabs {
-		scalarNumerator = -1 - (tabFallCount / 9) // floor divide
-	}
-
-	numerator := tabsAdjustmentDenominator + scalarNumerator // [127|128|129]/128, [4095|4096|4097]/4096
-
-	return int64(float64(parentTabs) * float64(numerator) / float64(tabsAdjustmentDenominator))
-}
-
 func (m *Miner) doTick(s int64) {
 	m.tick = s
 
-	// Get tick-expired received blocks and process them.
-	for k, v := range m.receivedBlocks {
-		if m.tick >= k && /* future block inhibition -> */ m.tick+(15*ticksPerSecond) > k {
+	// Get due deliveries and process them.
+	// Time slots are visited in order (not map order) so that runs are reproducible.
+	slots := make([]int64, 0, len(m.deliveries))
+	for k := range m.deliveries {
+		slots = append(slots, k)
+	}
+	sort.Slice(slots, func(i, j int) bool { return slots[i] < slots[j] })
+
+	for _, k := range slots {
+		v := m.deliveries[k]
+		// Future blocks are held (or rejected) by their timestamps in processDelivery.
+		if m.tick >= k {
 			// process blocks in order they were received (per time slot)
-			for _, b := range v {
-				m.processBlock(b)
+			for _, d := range v {
+				m.processDelivery(d)
 			}
-			delete(m.receivedBlocks, k)
+			delete(m.deliveries, k)
 		}
 	}
 
@@ -180,48 +180,79 @@ func (m *Miner) doTick(s int64) {
 	m.mineTick()
 }
 
-func fakeHashimoto(hashratePerTick, parentDifficulty, networkLambda float64) bool {
-	tickR := hashratePerTick / parentDifficulty * networkLambda
+func (s *Simulation) fakeHashimoto(hashratePerTick, parentDifficulty float64) bool {
+	tickR := hashratePerTick / parentDifficulty * s.networkLambda
 	tickR = tickR / 2
 
 	// Do we solve it?
-	needle := rand.Float64()
-	trial := rand.Float64()
+	needle := s.rng(rngHashing).Float64()
+	trial := s.rng(rngHashing).Float64()
 
 	return math.Abs(trial-needle) <= tickR ||
 		math.Abs(trial-needle) >= 1-tickR
 }
 
 func (m *Miner) mineTick() {
-	parent := m.head
-
-	solved := fakeHashimoto(float64(m.HashesPerTick), float64(parent.d), networkLambda)
+	solved := m.sim.fakeHashimoto(float64(m.HashesPerTick), float64(m.head.d))
 	if !solved {
 		return
 	}
+	m.mineBlock()
+}
 
-	// Naively, the block tick (timestamp) is the miner's real tick.
-	s := m.tick
+// mineBlock creates a block on the miner's head, as solved at the miner's current tick, and broadcasts it.
+func (m *Miner) mineBlock() {
+	parent := m.head
+
+	// Naively, the block tick (timestamp) is the miner's real tick; its TimestampPolicy may forge it.
+	s := m.timestampPolicy().Timestamp(m, parent)
 
 	// But if the tickInterval allows multiple ticks / second,
 	// we need to enforce that the timestamp is a unit-second value.
-	s = s / ticksPerSecond // floor
-	s = s * ticksPerSecond // back to interval units
+	s = s / m.sim.TicksPerSecond // floor
+	s = s * m.sim.TicksPerSecond // back to interval units
 
 	// In order for the block to be valid, the tick must be greater
 	// than that of its parent.
-	if s == parent.s {
+	if s <= parent.s {
 		s = parent.s + 1
 	}
 
-	// Get a random value (from a normal distribution) as a representation of this block's TAB.
-	// This is a global value that, once set, all miners will use.
-	blockTxPoolTABs, ok := txPoolBlockTABs[parent.i+1]
-	if !ok {
-		blockTxPoolTABs = int64(normalDist.Rand())
-		txPoolBlockTABs[parent.i+1] = blockTxPoolTABs
+	var body []*tx
+	var txs, gas, blockTxPoolTABs int64
+	if mp := m.sim.mempool; mp != nil {
+		body = m.selectTxs()
+		for _, x := range body {
+			gas += x.gas
+		}
+		txs = int64(len(body))
+		blockTxPoolTABs = mp.senderTAB(body)
+	} else {
+		// Get a random value (from a normal distribution) as a representation of this block's TAB.
+		// This is a global value that, once set, all miners will use.
+		var ok bool
+		blockTxPoolTABs, ok = m.sim.txPoolBlockTABs[parent.i+1]
+		if !ok {
+			blockTxPoolTABs = int64(m.sim.normalDist.Rand())
+			m.sim.txPoolBlockTABs[parent.i+1] = blockTxPoolTABs
+		}
+		// The tx pool TAB also stands in for the number of transactions;
+		// presumeMinerShareBalancePerBlockDenominator implies the average sender's balance.
+		txs = blockTxPoolTABs * presumeMinerShareBalancePerBlockDenominator / genesisBlockTABS
+		if txs < 0 {
+			txs = 0
+		}
+		gas = txs * txGas
 	}
-	blockTAB := blockTxPoolTABs + m.Balance
+
+	// The miner's self-transfers, if it sends any, take their place in the block too.
+	selfTransfers := m.selfTransfers()
+	txs += selfTransfers
+	gas += selfTransfers * txGas
+
+	blockTAB := m.strategy().TAB(m, blockTxPoolTABs+m.Balance)
+	m.honestTAB += float64(blockTxPoolTABs + m.Balance)
+	m.playedTAB += float64(blockTAB)
 	tabChange := int64(0)
 	if blockTAB > parent.tabs {
 		tabChange = 1
@@ -236,75 +267,78 @@ func (m *Miner) mineTick() {
 		tabFalls = 0
 	}
 
-	// A naive model of uncle citations: block has uncles if any orphan blocks exist in our miner's record of the parent height
-	uncles := len(m.Blocks[parent.i-1]) > 1
-	blockDifficulty := getBlockDifficulty(parent /* interval: */, uncles, s-parent.s)
+	uncles := m.selectUncles(parent)
+	blockDifficulty := m.sim.calcDifficulty(parent, s)
 
-	tabs := getTABS(parent.tabs, blockTAB)
-	if m.ConsensusAlgorithm == TDTABS_step {
-		tabs = getTABS_step(parent.tabs, tabFalls, blockTAB)
+	tabs := m.sim.getTABS(parent.tabs, blockTAB)
+	if m.ConsensusAlgorithm != nil {
+		tabs = m.ConsensusAlgorithm.BlockTABS(m.sim, parent, tabFalls, blockTAB)
+	}
+	if m.sim.tabsAdjuster != nil {
+		tabs = adjustTABS(m.sim.tabsAdjuster, tabsHeader{m.Blocks, parent}, blockTAB)
 	}
 
-	tdtabs := tabs * blockDifficulty
+	td, ttdtabs, err := accumulateWeights(parent, blockDifficulty, tabs)
+	if err != nil {
+		m.sim.fail(fmt.Errorf("miner %s, block %d: %w", m.Address, parent.i+1, err))
+		return
+	}
 	b := &Block{
 		i:             parent.i + 1,
-		s:             s, // miners are always honest about their timestamps
+		s:             s,
 		si:            s - parent.s,
 		d:             blockDifficulty,
-		td:            parent.td + blockDifficulty,
+		td:            td,
 		tabsFallCount: tabFalls,
 		tabsCmp:       tabChange,
+		tab:           blockTAB,
 		tabs:          tabs,
-		ttdtabs:       parent.ttdtabs + tdtabs,
+		ttdtabs:       ttdtabs,
 		miner:         m.Address,
 		ph:            parent.h,
-		h:             fmt.Sprintf("%08x", rand.Int63()),
+		uncles:        uncles,
+		txs:           txs,
+		gas:           gas,
+		body:          body,
+		h:             fmt.Sprintf("%08x", m.sim.rng(rngBlockHashes).Int63()),
+		t:             m.tick,
 	}
-	m.processBlock(b)
-	m.broadcastBlock(b)
+	m.processBlock(b) // and broadcast it
 }
 
-func (m *Miner) broadcastBlock(b *Block) {
-	b.delay = Delay{
-		withhold: m.SendDelay(b),
-		material: m.Latency(),
-	}
-	for _, n := range m.neighbors {
-		n.receiveBlock(b)
-	}
-}
-
-func (m *Miner) receiveBlock(b *Block) {
-	if m.ReceiveDelay != nil {
-		b.delay.postpone = m.ReceiveDelay(b)
-	}
-	if d := b.delay.Total(); d > 0 {
-		if len(m.receivedBlocks[b.s+d]) > 0 {
-			m.receivedBlocks[b.s+d] = append(m.receivedBlocks[b.s+d], b)
-		} else {
-			m.receivedBlocks[b.s+d] = Blocks{b}
-		}
+// processBlock imports one of the miner's own blocks (or the genesis block),
+// unless its timestamp is invalid. The miner's own future blocks aren't held.
+func (m *Miner) processBlock(b *Block) {
+	if m.head != nil && !m.validTimestamp(b) {
+		m.reject(b)
 		return
 	}
-	m.processBlock(b)
+	m.importBlock(b, 0)
+	m.adoptOrphans(b)
 }
 
-func (m *Miner) processBlock(b *Block) {
+// importBlock adds the block to the miner's tree, arbitrates it against the head,
+// and, if the block is new to the miner, relays it. hops is how many links the block travelled to get here.
+func (m *Miner) importBlock(b *Block, hops int) {
 	dupe := m.Blocks.AppendBlockByNumber(b)
 	if !dupe {
-		defer m.broadcastBlock(b)
+		defer m.broadcastBlock(b, hops)
 	}
 
 	// Special case: init genesis block.
 	if m.head == nil {
 		m.head = b
-		m.head.canonical = true
+		m.canon[b.i] = b
 		return
 	}
 
 	canon := m.arbitrateBlocks(m.head, b)
-	canon.canonical = true
 	m.setHead(canon)
+
+	if !dupe && b.miner != m.Address {
+		m.notePublic(b)
+		m.publish(m.strategy().Received(m, b))
+	}
 }
 
 // arbitrateBlocks selects one canonical block from any two blocks.
@@ -324,19 +358,27 @@ func (m *Miner) arbitrateBlocks(a, b *Block) *Block {
 		m.decisionConditionTallies[decisionCondition]++
 	}()
 
-	if m.ConsensusAlgorithm == TD {
-		// TD arbitration
-		if a.td > b.td {
-			return a
-		} else if b.td > a.td {
-			return b
+	// Strategy arbitration, eg. a selfish miner sticking to its private branch.
+	if winner := m.strategy().Prefer(m, a, b); winner != nil {
+		m.ConsensusObjectiveArbitrations--
+		decisionCondition = "strategy"
+		return winner
+	}
+
+	// Fork choice arbitration: the rule's tip, or its score, then its tie break.
+	if fc := m.ConsensusAlgorithm; fc != nil {
+		if tc, ok := fc.(TipChooser); ok {
+			return tc.Tip(m.Blocks, a, b)
 		}
-	} else if m.ConsensusAlgorithm == TDTABS || m.ConsensusAlgorithm == TDTABS_step {
-		if (a.ttdtabs) > (b.ttdtabs) {
+		if c := fc.Compare(m.Blocks, a, b); c > 0 {
 			return a
-		} else if (b.ttdtabs) > (a.ttdtabs) {
+		} else if c < 0 {
 			return b
 		}
+		if winner, condition := fc.TieBreak(a, b); winner != nil {
+			decisionCondition = condition
+			return winner
+		}
 	}
 
 	// Number arbitration
@@ -365,7 +407,7 @@ func (m *Miner) arbitrateBlocks(a, b *Block) *Block {
 		return a
 	}
 	decisionCondition = "random"
-	if rand.Float64() < 0.5 {
+	if m.sim.rng(rngArbitration).Float64() < 0.5 {
 		return a
 	}
 	return b
@@ -374,65 +416,60 @@ func (m *Miner) arbitrateBlocks(a, b *Block) *Block {
 func (m *Miner) balanceAdd(i int64) {
 	m.Balance += i
 	if m.BalanceCap != 0 && m.Balance > m.BalanceCap {
+		m.ledger.Sold += float64(m.Balance - m.BalanceCap)
 		m.Balance = m.BalanceCap
 	}
 }
 
 func (m *Miner) setHead(head *Block) {
 
-	add, drop := 1, 0 // These will only be recorded if reorg is true. Otherwise noops.
+	add, drop := 0, 0 // These will only be recorded if reorg is true. Otherwise noops.
 
 	addCanon := func(b *Block) {
-		b.canonical = true
-		if b.miner == m.Address {
-			m.balanceAdd(blockReward)
+		if m.canon.Has(b) {
+			return
 		}
+		m.canon[b.i] = b
+		m.include(b, true)
+		m.bookBlock(b, true)
 		add++
 	}
 
-	dropCanon := func(b *Block) {
-		if !b.canonical {
+	dropCanon := func(i int64) {
+		b, ok := m.canon[i]
+		if !ok {
 			return
 		}
-		if b.miner == m.Address {
-			m.balanceAdd(-blockReward)
-		}
-		b.canonical = false
+		m.bookBlock(b, false)
+		m.include(b, false)
+		delete(m.canon, i)
 		drop++
 	}
 
-	doReorg := m.head.h != head.ph
-	if doReorg {
-
-		// No block above the new head will be canonical.
-		for i := head.i + 1; ; i++ {
-			if len(m.Blocks[i]) == 0 {
-				// When reaching a height with no (zero) blocks,
-				// assume there are no greater heights with blocks either.
-				break
-			}
-			for _, b := range m.Blocks[i] {
-				dropCanon(b)
-			}
-		}
-
-		// All blocks at head height which are not the new head are not canonical.
-		for _, b := range m.Blocks[head.i] {
-			if b.h != head.h {
-				dropCanon(b)
-			}
-		}
+	// The new chain runs from the head down to (excluding) the common ancestor with the old head,
+	// or as far down as the miner knows the head's ancestry.
+	ancestor := m.Blocks.CommonAncestor(m.head, head)
+	branch := Blocks{} // by descending number, from the head
+	for b := head; b != nil && b != ancestor; b = m.Blocks.GetParent(b) {
+		branch = append(branch, b)
+	}
 
-		// Iterate backwards from the parent of the head block
-		// breaking when we find a common ancestor.
-		for p := m.Blocks.GetParent(head); p != nil && !p.canonical; p = m.Blocks.GetParent(p) {
-			for _, v := range m.Blocks[p.i] {
-				dropCanon(v) // drop all from canon
+	// Everything of the old chain above the ancestor is dropped for the branch.
+	if len(branch) > 0 {
+		for i := m.head.i; i >= branch[len(branch)-1].i; i-- {
+			if i <= head.i && m.canon.Has(branch[head.i-i]) {
+				continue
 			}
-			addCanon(p) // add the one parent to canon
+			dropCanon(i)
 		}
+	}
+	for _, b := range branch {
+		addCanon(b)
+	}
 
-		m.reorgs[head.i] = reorg{add, drop}
+	doReorg := drop > 0
+	if doReorg {
+		m.reorgs[head.i] = reorg{add, drop, m.sim.now}
 
 		// fmt.Println("Reorg!", m.Address, head.i, "add", add, "drop", drop)
 	}
@@ -440,17 +477,20 @@ func (m *Miner) setHead(head *Block) {
 	m.head = head
 	headI := head.i
 
-	addCanon(m.head)
-
+	if m.cord == nil {
+		// Nobody's listening, eg. a headless run.
+		return
+	}
 	m.cord <- minerEvent{
 		minerI: int(m.Index),
 		i:      headI,
-		blocks: m.Blocks[headI],
+		blocks: m.Blocks.GetBlocksByNumber(headI),
 	}
 }
 
 type reorg struct {
 	add, drop int
+	tick      int64
 }
 
 func (r reorg) magnitude() float64 {
@@ -464,32 +504,6 @@ func (m *Miner) reorgMagnitudes() (magnitudes []float64) {
 	return
 }
 
-type ConsensusAlgorithm int
-
-const (
-	None ConsensusAlgorithm = iota
-	TD
-	TDTABS
-	TDTABS_step // sequence-derived step algorithm for tabs numerator
-	TimeDesc    // FreshnessPreferred
-)
-
-func (c ConsensusAlgorithm) String() string {
-	switch c {
-	case TD:
-		return "TD"
-	case TDTABS:
-		return "TDTABS"
-	case TDTABS_step:
-		return "TDTABS_step"
-	// case TimeAsc:
-	// 	return "TimeAsc"
-	case TimeDesc:
-		return "TimeDesc"
-	}
-	panic("impossible")
-}
-
 type Block struct {
 	i             int64  // H_i: number
 	s             int64  // H_s: timestamp
@@ -498,44 +512,71 @@ type Block struct {
 	td            int64  // H_td: total difficulty
 	tabsFallCount int64  // scalar value tracking how many blocks in sequence have had falling TABS scores
 	tabsCmp       int64  // +/- TABS vs parent. Shortcut used for helping malicious miners figure out if they can try to beat a received block by postponing.
+	tab           int64  // TAB: the tx pool's TAB and the miner's balance
 	tabs          int64  // H_k: TAB synthesis
 	ttdtabs       int64  // H_k: TTABSConsensusScore, aka Total TD*TABS
 	miner         string // H_c: coinbase/etherbase/author/beneficiary
 	h             string // H_h: hash
 	ph            string // H_p: parent hash
-	canonical     bool
+	uncles        Blocks // H_o: ommers, by the header
+	txs           int64  // transaction count
+	gas           int64  // H_g: gas used
+	body          []*tx  // transactions, under the mempool model
 
-	delay Delay
+	t int64 // tick the block was mined at; s is only whole seconds
 }
 
+// Delay is the time a block spends on one hop, from the sender's relay to the receiver's import.
 type Delay struct {
 	withhold int64 // selfishly withhold. This is controlled by the mining miner.
 	postpone int64 // postpone processing to give self more time to mine last block. Controlled by the receiving miner.
 	material int64 // ohms
+	validate int64 // block validation by the receiving miner
 }
 
 func (d Delay) Total() int64 {
-	return d.withhold + d.postpone + d.material
+	return d.withhold + d.postpone + d.material + d.validate
 }
 
 type Blocks []*Block
-type BlockTree map[int64]Blocks
 
 func (bs Blocks) Len() int {
 	return len(bs)
 }
 
-func NewBlockTree() BlockTree {
-	return BlockTree(make(map[int64]Blocks))
+// BlockTree is a miner's store of blocks, indexed by hash and by number,
+// with each block linked to its parent and children.
+// Blocks whose parents the miner hasn't seen are kept too; they're linked when the parent arrives.
+type BlockTree struct {
+	byHash   map[string]*Block
+	byNumber map[int64]Blocks
+	children map[string]Blocks // by parent hash
+	weights  map[string]int64  // subtree difficulties by hash, kept once asked for (see subtreeDifficulty)
 }
 
-func (bt BlockTree) String() string {
+func NewBlockTree() *BlockTree {
+	return &BlockTree{
+		byHash:   make(map[string]*Block),
+		byNumber: make(map[int64]Blocks),
+		children: make(map[string]Blocks),
+	}
+}
+
+func (bt *BlockTree) String() string {
+	return bt.format(nil)
+}
+
+// format prints the tree by number, marking the blocks of the canonical chain with a *.
+func (bt *BlockTree) format(canon Chain) string {
 	out := ""
-	for i := int64(0); i < int64(len(bt)); i++ {
+	for _, i := range bt.Numbers() {
 
 		out += fmt.Sprintf("n=%d ", i)
-		for _, b := range bt[i] {
+		for _, b := range bt.byNumber[i] {
 			out += b.String()
+			if canon.Has(b) {
+				out += "*"
+			}
 		}
 		out += "\n"
 	}
@@ -544,130 +585,336 @@ func (bt BlockTree) String() string {
 }
 
 func (b *Block) String() string {
-	return fmt.Sprintf("[i=%d s=%v(+%d) h=%s ph=%s d=%v td=%v c=%v]", b.i, b.s, b.si, b.h[:4], b.ph[:4], b.d, b.td, b.canonical)
+	return fmt.Sprintf("[i=%d s=%v(+%d) h=%s ph=%s d=%v td=%v]", b.i, b.s, b.si, b.h[:4], b.ph[:4], b.d, b.td)
 }
 
-func (bt BlockTree) AppendBlockByNumber(b *Block) (dupe bool) {
-	if _, ok := bt[b.i]; !ok {
-		// Is new block for number i
-		bt[b.i] = Blocks{b}
-		return false
-	} else {
-		// Is competitor block for number i
+func (bt *BlockTree) AppendBlockByNumber(b *Block) (dupe bool) {
+	if _, ok := bt.byHash[b.h]; ok {
+		return true
+	}
+	bt.byHash[b.h] = b
+	bt.byNumber[b.i] = append(bt.byNumber[b.i], b)
+	bt.children[b.ph] = append(bt.children[b.ph], b)
+	if bt.weights != nil {
+		bt.addWeight(b)
+	}
+	return false
+}
 
-		for _, bb := range bt[b.i] {
-			if b.h == bb.h {
-				dupe = true
-			}
-		}
-		if !dupe {
-			bt[b.i] = append(bt[b.i], b)
-		}
+// Len returns the number of blocks in the tree.
+func (bt *BlockTree) Len() int {
+	return len(bt.byHash)
+}
+
+// Numbers returns the numbers the tree has blocks at, in order.
+func (bt *BlockTree) Numbers() []int64 {
+	numbers := make([]int64, 0, len(bt.byNumber))
+	for i := range bt.byNumber {
+		numbers = append(numbers, i)
 	}
-	return dupe
+	sort.Slice(numbers, func(a, b int) bool { return numbers[a] < numbers[b] })
+	return numbers
 }
 
 // Ks returns a slice of K tallies (number of available blocks) for each block number.
 // It weirdly returns a float64 because it will be used with stats packages
 // that like []float64.
-func (bt BlockTree) Ks() (ks []float64) {
-	for _, v := range bt {
-		if len(v) == 0 {
-			panic("how?")
-		}
+func (bt *BlockTree) Ks() (ks []float64) {
+	for _, v := range bt.byNumber {
 		ks = append(ks, float64(len(v)))
 	}
 	return ks
 }
 
-// Intervals returns ALL block intervals for a tree (whether canonical or not).
-// Again, []float64 is used because its convenient in context.
-func (bt BlockTree) CanonicalIntervals() (intervals []float64) {
-	for _, v := range bt {
-		for _, b := range v {
-			if b.canonical {
-				intervals = append(intervals, float64(b.si))
-			}
+// ForkRate returns the fraction of heights (above genesis) with more than one block.
+func (bt *BlockTree) ForkRate() float64 {
+	heights, forked := 0, 0
+	for i, v := range bt.byNumber {
+		if i == 0 {
+			continue
+		}
+		heights++
+		if len(v) > 1 {
+			forked++
 		}
 	}
-	return intervals
+	if heights == 0 {
+		return 0
+	}
+	return float64(forked) / float64(heights)
 }
 
-func (bt BlockTree) CanonicalDifficulties() (difficulties []float64) {
-	for _, v := range bt {
-		for _, b := range v {
-			if !b.canonical {
-				continue
-			}
-			difficulties = append(difficulties, float64(b.d))
+func (bt *BlockTree) GetBlocksByNumber(i int64) Blocks {
+	return bt.byNumber[i]
+}
+
+// GetSideBlocksByNumber returns the blocks at number i which are not in the canonical chain.
+func (bt *BlockTree) GetSideBlocksByNumber(i int64, canon Chain) (sideBlocks Blocks) {
+	for _, bl := range bt.byNumber[i] {
+		if !canon.Has(bl) {
+			sideBlocks = append(sideBlocks, bl)
 		}
 	}
-	return difficulties
+	return sideBlocks
 }
 
-func (bt BlockTree) GetBlockByNumber(i int64) *Block {
-	for _, bl := range bt[i] {
-		if bl.canonical {
-			return bl
+func (bt *BlockTree) GetBlockByHash(h string) *Block {
+	return bt.byHash[h]
+}
+
+func (bt *BlockTree) Where(condition func(*Block) bool) (blocks Blocks) {
+	for _, bl := range bt.byHash {
+		if !condition(bl) {
+			continue
 		}
+		blocks = append(blocks, bl)
 	}
-	return nil
+	return blocks
 }
 
-func (bt BlockTree) GetSideBlocksByNumber(i int64) (sideBlocks Blocks) {
-	for _, bl := range bt[i] {
-		if !bl.canonical {
-			sideBlocks = append(sideBlocks, bl)
+func (bt *BlockTree) GetParent(b *Block) (parent *Block) {
+	return bt.byHash[b.ph]
+}
+
+// GetChildren returns the blocks built on b, in the order the miner got them.
+func (bt *BlockTree) GetChildren(b *Block) Blocks {
+	return bt.children[b.h]
+}
+
+// CommonAncestor returns the highest block that both a and b descend from (or are).
+// It returns nil if either's ancestry is broken by a block the miner hasn't seen.
+func (bt *BlockTree) CommonAncestor(a, b *Block) *Block {
+	for a != nil && b != nil && a.h != b.h {
+		if a.i >= b.i {
+			a = bt.GetParent(a)
+		} else {
+			b = bt.GetParent(b)
 		}
 	}
-	return sideBlocks
+	if a == nil || b == nil {
+		return nil
+	}
+	return a
 }
 
-func (bt BlockTree) GetBlockByHash(h string) *Block {
-	for i := int64(len(bt) - 1); i >= 0; i-- {
-		for _, b := range bt[i] {
-			if b.h == h {
-				return b
-			}
-		}
+// CanonicalChain returns the chain ending at head, from the first block of head's known ancestry (genesis) up.
+func (bt *BlockTree) CanonicalChain(head *Block) (chain Blocks) {
+	for b := head; b != nil; b = bt.GetParent(b) {
+		chain = append(chain, b)
+	}
+	for l, r := 0, len(chain)-1; l < r; l, r = l+1, r-1 {
+		chain[l], chain[r] = chain[r], chain[l]
 	}
-	return nil
+	return chain
 }
 
-func (bt BlockTree) Where(condition func(*Block) bool) (blocks Blocks) {
-	for _, v := range bt {
-		for _, bl := range v {
-			if !condition(bl) {
+// Fork is a branch off the canonical chain.
+type Fork struct {
+	Ancestor *Block // the canonical block the branch forks from
+	Tip      *Block // the branch's highest block; the first seen, on a tie
+	Length   int64  // blocks on the branch, to its tip
+}
+
+// Forks enumerates the branches off the chain ending at head, lowest first.
+// Each child of a canonical block that is not canonical itself starts a fork.
+func (bt *BlockTree) Forks(head *Block) (forks []Fork) {
+	chain := bt.CanonicalChain(head)
+	canonical := make(map[string]bool, len(chain))
+	for _, b := range chain {
+		canonical[b.h] = true
+	}
+	for _, ancestor := range chain {
+		for _, child := range bt.GetChildren(ancestor) {
+			if canonical[child.h] {
 				continue
 			}
-			blocks = append(blocks, bl)
+			tip := bt.tip(child)
+			forks = append(forks, Fork{Ancestor: ancestor, Tip: tip, Length: tip.i - ancestor.i})
 		}
 	}
-	return blocks
+	return forks
+}
+
+// tip returns the highest descendant of b (or b).
+func (bt *BlockTree) tip(b *Block) *Block {
+	tip := b
+	for _, child := range bt.GetChildren(b) {
+		if t := bt.tip(child); t.i > tip.i {
+			tip = t
+		}
+	}
+	return tip
+}
+
+// Chain is one miner's canonical chain, by number.
+// Blocks are shared by every miner who has them, so which of them are canonical
+// is kept by each miner here, and not on the blocks.
+type Chain map[int64]*Block
+
+func NewChain() Chain {
+	return Chain(make(map[int64]*Block))
+}
+
+// Has tells whether the block is canonical.
+func (c Chain) Has(b *Block) bool {
+	cb, ok := c[b.i]
+	return ok && cb.h == b.h
+}
+
+func (c Chain) GetBlockByNumber(i int64) *Block {
+	return c[i]
+}
+
+// Intervals returns the block intervals of the chain.
+// Again, []float64 is used because its convenient in context.
+func (c Chain) Intervals() (intervals []float64) {
+	for _, b := range c {
+		intervals = append(intervals, float64(b.si))
+	}
+	return intervals
 }
 
-func (bt BlockTree) GetParent(b *Block) (parent *Block) {
-	for _, v := range bt[b.i-1] {
-		if v.h == b.ph {
-			return v
+func (c Chain) Difficulties() (difficulties []float64) {
+	for _, b := range c {
+		difficulties = append(difficulties, float64(b.d))
+	}
+	return difficulties
+}
+
+func (c Chain) Where(condition func(*Block) bool) (blocks Blocks) {
+	for _, b := range c {
+		if condition(b) {
+			blocks = append(blocks, b)
 		}
 	}
-	return nil
+	return blocks
 }
 
 type minerResults struct {
-	ConsensusAlgorithm ConsensusAlgorithm
+	Address            string
+	ConsensusAlgorithm ForkChoice
 	HashrateRel        float64
 	HeadI              int64
 	HeadTABS           int64
 
+	Wins    int
+	WinRate float64
+
+	// Strategy is the miner's; RelativeRevenue its share of the blocks of its chain, leaving out those it withholds,
+	// to hold against its share of the hashrate.
+	Strategy        Strategy
+	RelativeRevenue float64
+	HashrateShare   float64
+
+	// TABRel is the TAB the miner's strategy brought to the blocks it mined, relative to the TAB they'd have had honestly.
+	// Twin is the miner's honest twin, with the same fork choice, hashrate and starting capital (see resultsOf),
+	// and TwinAdvantage the miner's RelativeRevenue less the twin's; zero without one.
+	TABRel        float64
+	Twin          string
+	TwinAdvantage float64
+
 	KMean                      float64
 	IntervalsMeanSeconds       float64
 	DifficultiesRelGenesisMean float64
 
-	Balance                 int64
-	DecisiveArbitrationRate float64
-	ReorgMagnitudesMean     float64
+	// TxPolicy is the miner's transaction selection (nil without the mempool model),
+	// and Fees (ether) what the transactions of its canonical blocks paid it.
+	TxPolicy TxPolicy
+	Fees     float64
+
+	// SellPolicy is the miner's (nil without the economics model); Ledger its profit and loss.
+	SellPolicy SellPolicy
+	Ledger     ledger
+
+	// TimestampPolicy is the miner's; Rejected counts the blocks it rejected for invalid timestamps, and their descendants.
+	TimestampPolicy TimestampPolicy
+	Rejected        int
+
+	Balance                        int64
+	ConsensusObjectiveArbitrations int
+	DecisiveArbitrationRate        float64
+	ReorgMagnitudesMean            float64
+	UncleRate                      float64 // uncles referenced per canonical block
+
+	// How other miners' blocks got to this miner, on average: links travelled and time since mining.
+	HopsMean               float64
+	PropagationSecondsMean float64
+}
+
+// results summarizes the miner's view of the finished simulation.
+func (m *Miner) results() minerResults {
+	r := minerResults{
+		Address:            m.Address,
+		ConsensusAlgorithm: m.ConsensusAlgorithm,
+		HashrateRel:        m.Hashrate,
+		HeadI:              m.head.i,
+		HeadTABS:           m.head.tabs,
+		Balance:            m.Balance,
+		Ledger:             m.ledger,
+
+		ConsensusObjectiveArbitrations: m.ConsensusObjectiveArbitrations,
+	}
+
+	r.Wins = m.canon.Where(func(b *Block) bool {
+		return b.miner == m.Address
+	}).Len()
+	if m.sim.mempool != nil {
+		r.TxPolicy = m.txPolicy()
+	}
+	r.Fees = m.ledger.Fees
+	if m.sim.Economics != nil {
+		r.SellPolicy = m.sellPolicy()
+	}
+	if m.head.i > 0 {
+		r.WinRate = float64(r.Wins) / float64(m.head.i)
+	}
+	r.Strategy = m.strategy()
+	r.TABRel = 1
+	if m.honestTAB > 0 {
+		r.TABRel = m.playedTAB / m.honestTAB
+	}
+	r.TimestampPolicy = m.timestampPolicy()
+	r.Rejected = len(m.rejected)
+	published, publishedWins := m.head.i, r.Wins
+	for _, b := range m.withheld {
+		if m.canon.Has(b) {
+			published--
+			publishedWins--
+		}
+	}
+	if published > 0 {
+		r.RelativeRevenue = float64(publishedWins) / float64(published)
+	}
+	if m.sim.hashrate > 0 {
+		r.HashrateShare = m.Hashrate / m.sim.hashrate
+	}
+
+	r.KMean, _ = stats.Mean(m.Blocks.Ks())
+	intervalsMean, _ := stats.Mean(m.canon.Intervals())
+	r.IntervalsMeanSeconds = intervalsMean / float64(m.sim.TicksPerSecond)
+	difficultiesMean, _ := stats.Mean(m.canon.Difficulties())
+	r.DifficultiesRelGenesisMean = difficultiesMean / float64(m.sim.genesisBlock.d)
+
+	if m.ConsensusArbitrations > 0 {
+		r.DecisiveArbitrationRate = float64(m.ConsensusObjectiveArbitrations) / float64(m.ConsensusArbitrations)
+	}
+	r.ReorgMagnitudesMean, _ = stats.Mean(m.reorgMagnitudes())
+	if m.head.i > 0 {
+		uncles := 0
+		for _, b := range m.canon {
+			uncles += len(b.uncles)
+		}
+		r.UncleRate = float64(uncles) / float64(m.head.i)
+	}
+
+	hops, propagation := []float64{}, []float64{}
+	for _, a := range m.arrivals {
+		hops = append(hops, float64(a.hops))
+		propagation = append(propagation, float64(a.ticks)/float64(m.sim.TicksPerSecond))
+	}
+	r.HopsMean, _ = stats.Mean(hops)
+	r.PropagationSecondsMean, _ = stats.Mean(propagation)
+	return r
 }
 
 func ParseHexColor(s string) (c color.RGBA, err error) {
@@ -706,6 +953,15 @@ func (t HashrateDistType) String() string {
 	}
 }
 
+func parseHashrateDistType(s string) (HashrateDistType, error) {
+	for _, t := range []HashrateDistType{HashrateDistEqual, HashrateDistLongtail} {
+		if t.String() == s {
+			return t, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown hashrate distribution: %q", s)
+}
+
 func generateMinerHashrates(ty HashrateDistType, n int) []float64 {
 	if n < 1 {
 		panic("must have at least one miner")
@@ -7,14 +7,96 @@ import (
 // TestBlockTree_AppendBlock is a unit test.
 func TestBlockTree_AppendBlock(t *testing.T) {
 	bt := NewBlockTree()
-	bt.AppendBlockByNumber(genesisBlock)
-	if len(bt[0]) == 0 {
+	bt.AppendBlockByNumber(NewSimulation(DefaultSimulationConfig()).genesisBlock)
+	if len(bt.GetBlocksByNumber(0)) == 0 {
 		t.Fatal("missing genesis at index=0")
 	}
 	bt.AppendBlockByNumber(&Block{
 		i: 1,
 	})
-	if len(bt[1]) == 0 {
+	if len(bt.GetBlocksByNumber(1)) == 0 {
 		t.Fatal("missing block i=1 at index=1")
 	}
 }
+
+// TestBlockTree_Forks builds a tree with sparse heights and two forks:
+//
+//	g - a1 - a2 - a3 - a4
+//	     \     \
+//	      b2    c3 - c4 - c5
+//
+// and checks lookups, ancestors, and the forks off either chain.
+func TestBlockTree_Forks(t *testing.T) {
+	bt := NewBlockTree()
+	blocks := map[string]*Block{}
+	add := func(name string, i int64, parent string) {
+		b := &Block{i: i, h: name + "000", ph: "none"}
+		if parent != "" {
+			b.ph = blocks[parent].h
+		}
+		blocks[name] = b
+		if bt.AppendBlockByNumber(b) {
+			t.Fatalf("%s: dupe", name)
+		}
+	}
+	add("g", 0, "")
+	add("a1", 1, "g")
+	add("a2", 2, "a1")
+	add("b2", 2, "a1")
+	add("c3", 3, "a2")
+	add("a3", 3, "a2")
+	add("a4", 4, "a3")
+	add("c4", 4, "c3")
+	add("c5", 5, "c4")
+	add("x9", 9, "") // an orphan, far above
+
+	if !bt.AppendBlockByNumber(blocks["a3"]) {
+		t.Error("a3 appended twice")
+	}
+	if got := bt.GetBlockByHash(blocks["x9"].h); got != blocks["x9"] {
+		t.Errorf("GetBlockByHash: got %v", got)
+	}
+	if got := bt.GetParent(blocks["c3"]); got != blocks["a2"] {
+		t.Errorf("GetParent: got %v", got)
+	}
+	if got := len(bt.GetChildren(blocks["a2"])); got != 2 {
+		t.Errorf("GetChildren: got %d children, want 2", got)
+	}
+	if got := bt.Numbers(); len(got) != 7 || got[6] != 9 {
+		t.Errorf("Numbers: got %v", got)
+	}
+
+	for _, c := range []struct{ a, b, want string }{
+		{"a4", "c5", "a2"},
+		{"c5", "b2", "a1"},
+		{"a3", "a4", "a3"},
+		{"g", "g", "g"},
+	} {
+		if got := bt.CommonAncestor(blocks[c.a], blocks[c.b]); got != blocks[c.want] {
+			t.Errorf("CommonAncestor(%s, %s): got %v, want %s", c.a, c.b, got, c.want)
+		}
+	}
+	if got := bt.CommonAncestor(blocks["a4"], blocks["x9"]); got != nil {
+		t.Errorf("CommonAncestor with an orphan: got %v, want nil", got)
+	}
+
+	chain := bt.CanonicalChain(blocks["a4"])
+	if len(chain) != 5 || chain[0] != blocks["g"] || chain[4] != blocks["a4"] {
+		t.Errorf("CanonicalChain: got %v", chain)
+	}
+
+	for head, want := range map[string][]Fork{
+		"a4": {{blocks["a1"], blocks["b2"], 1}, {blocks["a2"], blocks["c5"], 3}},
+		"c5": {{blocks["a1"], blocks["b2"], 1}, {blocks["a2"], blocks["a4"], 2}},
+	} {
+		got := bt.Forks(blocks[head])
+		if len(got) != len(want) {
+			t.Fatalf("Forks(%s): got %v, want %v", head, got, want)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("Forks(%s)[%d]: got %v, want %v", head, i, got[i], want[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,392 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/big"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/montanaflynn/stats"
+	"github.com/whilei/go-tabs-scraper/lib"
+)
+
+// The mempool model: synthetic accounts send transactions, which arrive by a Poisson process
+// into one pool that all miners see (transactions gossip much faster than blocks are found).
+// Each miner fills its blocks from the transactions pending on its own chain, in the order of its TxPolicy,
+// while they fit the block's gas and size limits.
+// A block's TAB is then the balances of its distinct senders plus the miner's own.
+//
+// Without it (SimulationConfig.Mempool nil), a block's pool TAB is a single normal draw per height, shared by all miners.
+
+// MempoolConfig parameterizes the mempool model.
+// Log-normal values are given by the mean and standard deviation of their logarithms.
+type MempoolConfig struct {
+	// Accounts is the number of sending accounts, each sending equally often.
+	// Their balances, in the simulation's units, are log-normal with the median BalanceMedian and log deviation BalanceSigma.
+	Accounts      int64
+	BalanceMedian float64
+	BalanceSigma  float64
+
+	// TxsPerSecond is the rate at which transactions arrive.
+	TxsPerSecond float64
+
+	// Gas prices (gwei) and gas (per transaction, at least txGas) are log-normal.
+	GasPriceMu, GasPriceSigma float64
+	GasMu, GasSigma           float64
+
+	// BlockGasLimit and BlockBytesLimit limit what a block can take. A zero size limit is no limit.
+	BlockGasLimit   int64
+	BlockBytesLimit int64
+
+	// TTLSeconds is how long a transaction waits for inclusion before it is dropped from the pool. Zero is forever.
+	TTLSeconds float64
+}
+
+// DefaultMempoolConfig is the model fitted (by fitMempool) to go-tabs-scraper's etc-data,
+// 270 Ethereum Classic blocks from around 15,140,000, with balances scaled so that the median sender holds
+// presumeMinerShareBalancePerBlockDenominator, and geth's three hour pool lifetime.
+func DefaultMempoolConfig() MempoolConfig {
+	return MempoolConfig{
+		Accounts:      10_000,
+		BalanceMedian: presumeMinerShareBalancePerBlockDenominator,
+		BalanceSigma:  3.780,
+		TxsPerSecond:  1.173, // 15.25 per 13 second block
+		GasPriceMu:    0.089,
+		GasPriceSigma: 2.221,
+		GasMu:         10.655,
+		GasSigma:      0.512,
+		BlockGasLimit: 8_000_000,
+		TTLSeconds:    (3 * 60 * 60),
+	}
+}
+
+// fitMempool fits the model to a directory of go-tabs-scraper block files (block_*):
+// the shape of the senders' balances, the gas prices and gas of their transactions,
+// the transaction rate (from transactions per block, at 13 seconds a block) and the median block gas limit.
+// The balances' median stays the default's, since the simulation's balances aren't in ether.
+func fitMempool(dir string) (MempoolConfig, error) {
+	mempoolFitsMu.Lock()
+	defer mempoolFitsMu.Unlock()
+	if c, ok := mempoolFits[dir]; ok {
+		return c, nil
+	}
+
+	matches, err := filepath.Glob(filepath.Join(dir, "block_*"))
+	if err != nil {
+		return MempoolConfig{}, err
+	}
+	if len(matches) == 0 {
+		return MempoolConfig{}, fmt.Errorf("%s: no block files", dir)
+	}
+
+	var balances, gasPrices, gas, txsPerBlock, gasLimits []float64
+	for _, m := range matches {
+		data, err := ioutil.ReadFile(m)
+		if err != nil {
+			return MempoolConfig{}, err
+		}
+		ap := &lib.AppBlock{}
+		if err := json.Unmarshal(data, ap); err != nil {
+			return MempoolConfig{}, fmt.Errorf("%s: %w", m, err)
+		}
+		txsPerBlock = append(txsPerBlock, float64(len(ap.AppTxes)))
+		gasLimits = append(gasLimits, float64(ap.Header.GasLimit))
+		for _, tx := range ap.AppTxes {
+			if tx.BalanceAtParent == nil || tx.CTransaction == nil {
+				continue
+			}
+			if bal, _ := lib.PrettyBalance(tx.BalanceAtParent).Float64(); bal > 0 {
+				balances = append(balances, math.Log(bal))
+			}
+			if p := tx.CTransaction.GasFeeCap(); p.Sign() > 0 {
+				gwei, _ := new(big.Float).Quo(new(big.Float).SetInt(p), big.NewFloat(params.GWei)).Float64()
+				gasPrices = append(gasPrices, math.Log(gwei))
+			}
+			gas = append(gas, math.Log(float64(tx.CTransaction.Gas())))
+		}
+	}
+	if len(balances) == 0 {
+		return MempoolConfig{}, fmt.Errorf("%s: no transactions", dir)
+	}
+
+	c := DefaultMempoolConfig()
+	c.BalanceSigma, _ = stats.StandardDeviationPopulation(balances)
+	c.GasPriceMu, _ = stats.Mean(gasPrices)
+	c.GasPriceSigma, _ = stats.StandardDeviationPopulation(gasPrices)
+	c.GasMu, _ = stats.Mean(gas)
+	c.GasSigma, _ = stats.StandardDeviationPopulation(gas)
+	txs, _ := stats.Mean(txsPerBlock)
+	c.TxsPerSecond = txs / 13
+	limit, _ := stats.Median(gasLimits)
+	c.BlockGasLimit = int64(limit)
+
+	mempoolFits[dir] = c
+	return c, nil
+}
+
+// Fits are cached by directory; batches and sweeps configure many simulations from one scenario.
+var (
+	mempoolFits   = map[string]MempoolConfig{}
+	mempoolFitsMu sync.Mutex
+)
+
+// tx is a transaction.
+type tx struct {
+	id       int64 // in order of arrival
+	from     int64 // sending account
+	gas      int64
+	gasPrice float64 // gwei
+	arrival  int64   // tick
+}
+
+// mempool holds the transactions which have arrived and haven't expired, whether or not they have been included.
+// Which are pending depends on the chain; see pending.
+type mempool struct {
+	MempoolConfig
+	sim *Simulation
+
+	balances []int64 // by account
+	txs      []*tx   // by arrival
+	nextID   int64
+	next     float64 // the next arrival, in seconds
+}
+
+func newMempool(s *Simulation, config MempoolConfig) *mempool {
+	mp := &mempool{MempoolConfig: config, sim: s, balances: make([]int64, config.Accounts)}
+	r := s.rng(rngMempool)
+	for i := range mp.balances {
+		mp.balances[i] = int64(config.BalanceMedian * math.Exp(config.BalanceSigma*r.NormFloat64()))
+	}
+	mp.next = mp.interarrival()
+	return mp
+}
+
+// interarrival is the wait for the next transaction, in seconds.
+func (mp *mempool) interarrival() float64 {
+	if mp.TxsPerSecond <= 0 || mp.Accounts <= 0 {
+		return math.Inf(1)
+	}
+	return mp.sim.rng(rngMempool).ExpFloat64() / mp.TxsPerSecond
+}
+
+// advance brings the pool up to the tick: transactions arrive, and those which have waited too long are dropped.
+func (mp *mempool) advance(tick int64) {
+	r := mp.sim.rng(rngMempool)
+	for !math.IsInf(mp.next, 1) && int64(mp.next*float64(mp.sim.TicksPerSecond)) <= tick {
+		gas := int64(math.Exp(mp.GasMu + mp.GasSigma*r.NormFloat64()))
+		if gas < txGas {
+			gas = txGas
+		}
+		if mp.BlockGasLimit > 0 && gas > mp.BlockGasLimit {
+			gas = mp.BlockGasLimit
+		}
+		mp.txs = append(mp.txs, &tx{
+			id:       mp.nextID,
+			from:     r.Int63n(mp.Accounts),
+			gas:      gas,
+			gasPrice: math.Exp(mp.GasPriceMu + mp.GasPriceSigma*r.NormFloat64()),
+			arrival:  int64(mp.next * float64(mp.sim.TicksPerSecond)),
+		})
+		mp.nextID++
+		mp.next += mp.interarrival()
+	}
+
+	if mp.TTLSeconds > 0 {
+		oldest := tick - int64(mp.TTLSeconds*float64(mp.sim.TicksPerSecond))
+		i := sort.Search(len(mp.txs), func(i int) bool { return mp.txs[i].arrival >= oldest })
+		mp.txs = mp.txs[i:]
+	}
+}
+
+// pending returns the transactions which have arrived by the tick and aren't included (in a chain).
+func (mp *mempool) pending(tick int64, included map[int64]bool) (pending []*tx) {
+	mp.advance(tick)
+	for _, x := range mp.txs {
+		if x.arrival > tick {
+			break
+		}
+		if !included[x.id] {
+			pending = append(pending, x)
+		}
+	}
+	return pending
+}
+
+// senderTAB is the TAB of the transactions' distinct senders.
+func (mp *mempool) senderTAB(txs []*tx) (tab int64) {
+	seen := map[int64]bool{}
+	for _, x := range txs {
+		if !seen[x.from] {
+			seen[x.from] = true
+			tab += mp.balances[x.from]
+		}
+	}
+	return tab
+}
+
+// TxPolicy is how a miner fills its blocks: the order in which it takes pending transactions, while they fit.
+// Policies are registered by name (see RegisterTxPolicy).
+type TxPolicy interface {
+	String() string
+
+	// Order sorts the pending transactions, which are in order of arrival, into the order of inclusion.
+	Order(mp *mempool, pending []*tx)
+}
+
+var (
+	// FeeGreedy takes the highest gas prices first, as geth's miner does.
+	FeeGreedy TxPolicy = &txOrder{"fee", func(_ *mempool, a, b *tx) bool { return a.gasPrice > b.gasPrice }}
+
+	// FIFO takes transactions in order of arrival.
+	FIFO TxPolicy = &txOrder{"fifo", func(_ *mempool, a, b *tx) bool { return false }}
+
+	// TABMax takes the richest senders first, raising its blocks' TAB (and so their TABS) at the cost of fees.
+	TABMax TxPolicy = &senderOrder{"tab", 1}
+
+	// Mix weighs the sender's balance and the gas price equally; "mix:<weight>" weighs the balance by weight in [0, 1].
+	Mix TxPolicy = &senderOrder{"mix", 0.5}
+)
+
+func init() {
+	RegisterTxPolicy(FeeGreedy)
+	RegisterTxPolicy(FIFO)
+	RegisterTxPolicy(TABMax)
+	RegisterTxPolicy(Mix)
+}
+
+var (
+	txPolicies    = map[string]TxPolicy{}
+	txPolicyNames []string // in registration order
+)
+
+// RegisterTxPolicy makes the policy available by its name.
+// It panics if the name is taken.
+func RegisterTxPolicy(p TxPolicy) {
+	name := p.String()
+	if _, ok := txPolicies[name]; ok {
+		panic(fmt.Sprintf("tx policy %q registered twice", name))
+	}
+	txPolicies[name] = p
+	txPolicyNames = append(txPolicyNames, name)
+}
+
+func parseTxPolicy(s string) (TxPolicy, error) {
+	if p, ok := txPolicies[s]; ok {
+		return p, nil
+	}
+	if w := strings.TrimPrefix(s, "mix:"); w != s {
+		weight, err := strconv.ParseFloat(w, 64)
+		if err != nil || weight < 0 || weight > 1 {
+			return nil, fmt.Errorf("tx policy %q: want a balance weight in [0, 1]", s)
+		}
+		return &senderOrder{s, weight}, nil
+	}
+	return nil, fmt.Errorf("unknown tx policy: %q (want one of %s)", s, strings.Join(txPolicyNames, ", "))
+}
+
+// txOrder is a TxPolicy sorting by a less function; ties stay in order of arrival.
+type txOrder struct {
+	name string
+	less func(mp *mempool, a, b *tx) bool
+}
+
+func (o *txOrder) String() string { return o.name }
+
+func (o *txOrder) Order(mp *mempool, pending []*tx) {
+	sort.SliceStable(pending, func(i, j int) bool { return o.less(mp, pending[i], pending[j]) })
+}
+
+// senderOrder is a TxPolicy for TABS: only a sender's first transaction in a block adds to its TAB,
+// so each sender's best paying transaction is ranked by the weighted sum of the logarithms of
+// the sender's balance (by weight) and the gas price (by 1 - weight), ahead of the others, which go by gas price.
+type senderOrder struct {
+	name   string
+	weight float64
+}
+
+func (o *senderOrder) String() string { return o.name }
+
+func (o *senderOrder) Order(mp *mempool, pending []*tx) {
+	FeeGreedy.Order(mp, pending)
+
+	seen := map[int64]bool{}
+	score := map[int64]float64{} // by tx id, for the senders' first transactions
+	for _, x := range pending {
+		if seen[x.from] {
+			continue
+		}
+		seen[x.from] = true
+		score[x.id] = o.weight*math.Log1p(float64(mp.balances[x.from])) + (1-o.weight)*math.Log(x.gasPrice)
+	}
+	sort.SliceStable(pending, func(i, j int) bool {
+		si, firsti := score[pending[i].id]
+		sj, firstj := score[pending[j].id]
+		if firsti != firstj {
+			return firsti
+		}
+		return firsti && si > sj
+	})
+}
+
+// selectTxs fills a block from the transactions pending on the miner's chain at its tick.
+func (m *Miner) selectTxs() (body []*tx) {
+	mp := m.sim.mempool
+	pending := mp.pending(m.tick, m.included)
+
+	m.txPolicy().Order(mp, pending)
+
+	// The miner's self-transfers come first.
+	n := m.selfTransfers()
+	gas, size := n*txGas, int64(blockHeaderBytes)+n*txBytes
+	for _, x := range pending {
+		if gas+x.gas > mp.BlockGasLimit {
+			continue
+		}
+		if mp.BlockBytesLimit > 0 && size+txBytes > mp.BlockBytesLimit {
+			break
+		}
+		body = append(body, x)
+		gas += x.gas
+		size += txBytes
+	}
+	return body
+}
+
+func (m *Miner) txPolicy() TxPolicy {
+	if m.TxPolicy == nil {
+		return FeeGreedy
+	}
+	return m.TxPolicy
+}
+
+// txPolicyName is the policy's name, or "" for none.
+func txPolicyName(p TxPolicy) string {
+	if p == nil {
+		return ""
+	}
+	return p.String()
+}
+
+// include marks the block's transactions as included in (or, if not in, excluded from) the miner's chain.
+func (m *Miner) include(b *Block, in bool) {
+	if len(b.body) == 0 {
+		return
+	}
+	if m.included == nil {
+		m.included = map[int64]bool{}
+	}
+	for _, x := range b.body {
+		if in {
+			m.included[x.id] = true
+		} else {
+			delete(m.included, x.id)
+		}
+	}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"math"
+	"os"
+	"testing"
+)
+
+// TestFitMempool checks that the defaults are the fit to the scraped Ethereum Classic blocks.
+func TestFitMempool(t *testing.T) {
+	dir := "../go-tabs-scraper/etc-data"
+	if _, err := os.Stat(dir); err != nil {
+		t.Skip("no scraped data:", err)
+	}
+	got, err := fitMempool(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := DefaultMempoolConfig()
+	for _, c := range []struct {
+		name      string
+		got, want float64
+	}{
+		{"balanceSigma", got.BalanceSigma, want.BalanceSigma},
+		{"txsPerSecond", got.TxsPerSecond, want.TxsPerSecond},
+		{"gasPriceMu", got.GasPriceMu, want.GasPriceMu},
+		{"gasPriceSigma", got.GasPriceSigma, want.GasPriceSigma},
+		{"gasMu", got.GasMu, want.GasMu},
+		{"gasSigma", got.GasSigma, want.GasSigma},
+	} {
+		if math.Abs(c.got-c.want) > 0.001 {
+			t.Errorf("%s: fit %v, default %v", c.name, c.got, c.want)
+		}
+	}
+	if got.BlockGasLimit != want.BlockGasLimit {
+		t.Errorf("blockGasLimit: fit %d, default %d", got.BlockGasLimit, want.BlockGasLimit)
+	}
+
+	if _, err := fitMempool(t.TempDir()); err == nil {
+		t.Error("fit an empty directory")
+	}
+}
+
+func TestMempool_Arrivals(t *testing.T) {
+	config := DefaultSimulationConfig()
+	config.Seed = 1
+	mc := DefaultMempoolConfig()
+	mc.TTLSeconds = 600
+	config.Mempool = &mc
+	sim := NewSimulation(config)
+	mp := sim.mempool
+
+	hour := 3600 * sim.TicksPerSecond
+	mp.advance(hour)
+	if got, want := float64(mp.nextID), mc.TxsPerSecond*3600; math.Abs(got-want) > 4*math.Sqrt(want) {
+		t.Errorf("arrivals: got %v in an hour, want about %v", got, want)
+	}
+	for i, x := range mp.txs {
+		if x.arrival < hour-600*sim.TicksPerSecond || x.arrival > hour {
+			t.Fatalf("tx %d arrived at %d, outside the TTL", x.id, x.arrival)
+		}
+		if i > 0 && x.id != mp.txs[i-1].id+1 {
+			t.Fatalf("tx %d follows %d", x.id, mp.txs[i-1].id)
+		}
+		if x.gas < txGas || x.gas > mc.BlockGasLimit {
+			t.Errorf("tx %d: gas %d", x.id, x.gas)
+		}
+	}
+}
+
+func TestMiner_SelectTxs(t *testing.T) {
+	config := DefaultSimulationConfig()
+	mc := DefaultMempoolConfig()
+	mc.TxsPerSecond = 0 // the test adds them
+	mc.Accounts = 3
+	mc.BlockGasLimit = 100_000
+	config.Mempool = &mc
+	sim := NewSimulation(config)
+	mp := sim.mempool
+	mp.balances = []int64{1, 10, 100}
+
+	for i, x := range []tx{
+		{from: 0, gas: 21_000, gasPrice: 1},
+		{from: 1, gas: 21_000, gasPrice: 3},
+		{from: 1, gas: 50_000, gasPrice: 2},
+		{from: 2, gas: 40_000, gasPrice: 5},
+		{from: 0, gas: 21_000, gasPrice: 9, arrival: 20}, // not yet
+	} {
+		x := x
+		x.id = int64(i)
+		mp.txs = append(mp.txs, &x)
+	}
+
+	m := newMiner(sim, 0, "aaaaaa", 1, 0, nil)
+	m.tick = 10
+	ids := func(txs []*tx) (ids []int64) {
+		for _, x := range txs {
+			ids = append(ids, x.id)
+		}
+		return ids
+	}
+	for _, c := range []struct {
+		policy TxPolicy
+		want   []int64
+		tab    int64
+	}{
+		{FeeGreedy, []int64{3, 1, 0}, 111}, // 2 doesn't fit after 3 and 1
+		{FIFO, []int64{0, 1, 2}, 11},       // 3 doesn't fit after 2
+	} {
+		m.TxPolicy = c.policy
+		got := m.selectTxs()
+		if len(got) != len(c.want) {
+			t.Fatalf("%s: got %v, want %v", c.policy, ids(got), c.want)
+		}
+		for i := range got {
+			if got[i].id != c.want[i] {
+				t.Fatalf("%s: got %v, want %v", c.policy, ids(got), c.want)
+			}
+		}
+		if tab := mp.senderTAB(got); tab != c.tab {
+			t.Errorf("%s: TAB %d, want %d", c.policy, tab, c.tab)
+		}
+	}
+
+	// Transactions on the miner's chain are no longer pending.
+	m.include(&Block{body: mp.txs[:2]}, true)
+	m.TxPolicy = FIFO
+	if got := ids(m.selectTxs()); len(got) != 2 || got[0] != 2 || got[1] != 3 {
+		t.Errorf("included: got %v, want [2 3]", got)
+	}
+	m.include(&Block{body: mp.txs[:1]}, false)
+	if got := ids(m.selectTxs()); len(got) != 2 || got[0] != 0 || got[1] != 2 {
+		t.Errorf("excluded: got %v, want [0 2]", got)
+	}
+
+	mp.BlockBytesLimit = blockHeaderBytes + txBytes
+	if got := m.selectTxs(); len(got) != 1 {
+		t.Errorf("size limit: got %v, want one", ids(got))
+	}
+}
+
+// TestTxPolicies checks the TABS policies' trade of fees for TAB:
+// a poor sender's high paying transaction or a rich sender's cheap one, which won't both fit.
+func TestTxPolicies(t *testing.T) {
+	config := DefaultSimulationConfig()
+	mc := DefaultMempoolConfig()
+	mc.TxsPerSecond = 0
+	mc.Accounts = 2
+	mc.BlockGasLimit = 100_000
+	config.Mempool = &mc
+	sim := NewSimulation(config)
+	mp := sim.mempool
+	mp.balances = []int64{1, 1000}
+	mp.txs = []*tx{
+		{id: 0, from: 0, gas: 60_000, gasPrice: 10},
+		{id: 1, from: 1, gas: 50_000, gasPrice: 1},
+	}
+
+	m := newMiner(sim, 0, "aaaaaa", 1, 0, nil)
+	for _, c := range []struct {
+		policy string
+		want   int64
+	}{
+		{"fee", 0},
+		{"tab", 1},
+		{"mix", 1},     // log(1001)/2 > (log(2) + log(10))/2
+		{"mix:0.1", 0}, // 0.1 log(1001) < 0.1 log(2) + 0.9 log(10)
+		{"mix:0", 0},
+	} {
+		p, err := parseTxPolicy(c.policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.String() != c.policy {
+			t.Errorf("%s: named %s", c.policy, p)
+		}
+		m.TxPolicy = p
+		if got := m.selectTxs(); len(got) != 1 || got[0].id != c.want {
+			t.Errorf("%s: got %d txs, want [%d]", c.policy, len(got), c.want)
+		}
+	}
+
+	// A sender's second transaction adds no TAB; it waits behind the other senders' first.
+	mp.txs = append(mp.txs, &tx{id: 2, from: 1, gas: 21_000, gasPrice: 100})
+	pending := mp.pending(0, nil)
+	TABMax.Order(mp, pending)
+	if pending[0].id != 2 || pending[1].id != 0 || pending[2].id != 1 {
+		t.Errorf("got [%d %d %d], want [2 0 1]", pending[0].id, pending[1].id, pending[2].id)
+	}
+
+	for _, s := range []string{"mix:", "mix:1.5", "mix:-1", "tab:1"} {
+		if _, err := parseTxPolicy(s); err == nil {
+			t.Errorf("%q: want error", s)
+		}
+	}
+}
+
+// TestMempool_Run checks that the miners' chains hold no transaction twice, and none before it arrived.
+func TestMempool_Run(t *testing.T) {
+	sc := defaultScenario()
+	sc.Seed = 2
+	sc.Population.Count = 4
+	sc.Globals.TickSamples = 10 * 60 * 60
+	sc.Globals.Mempool = &ScenarioMempool{}
+	sc.Miners = []ScenarioMiner{{Address: "ff0000", Hashrate: 0.3, TxPolicy: FIFO.String()}}
+	if err := sc.validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	miners, err := runReplicate(sc, sc.Seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if miners[4].TxPolicy != FIFO || miners[0].TxPolicy != nil {
+		t.Fatal("tx policies not set")
+	}
+	for _, m := range miners {
+		seen := map[int64]bool{}
+		txs := 0
+		for _, b := range m.Blocks.CanonicalChain(m.head) {
+			if b.txs != int64(len(b.body)) || b.gas > m.sim.mempool.BlockGasLimit {
+				t.Fatalf("%s: block %s: %d txs, body %d, gas %d", m.Address, b, b.txs, len(b.body), b.gas)
+			}
+			for _, x := range b.body {
+				if seen[x.id] {
+					t.Fatalf("%s: tx %d included twice", m.Address, x.id)
+				}
+				if x.arrival > b.t {
+					t.Fatalf("%s: tx %d included before it arrived", m.Address, x.id)
+				}
+				seen[x.id] = true
+			}
+			txs += len(b.body)
+			if len(b.body) > 0 && !m.included[b.body[0].id] {
+				t.Fatalf("%s: tx %d not marked included", m.Address, b.body[0].id)
+			}
+		}
+		if txs == 0 {
+			t.Errorf("%s: no transactions on the chain", m.Address)
+		}
+		if r := m.results(); r.TxPolicy == nil || (r.Wins > 0) != (r.Fees > 0) {
+			t.Errorf("%s: tx policy %v, %d wins, fees %v", m.Address, r.TxPolicy, r.Wins, r.Fees)
+		}
+	}
+}
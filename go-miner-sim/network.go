@@ -0,0 +1,412 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Network events change the miner graph during a run.
+// A partition splits the miners into groups whose links to each other are cut.
+// An eclipse surrounds a miner with attackers: its links to everyone else are cut, so that it sees the chain
+// only as the attackers pass it on, and they pass on none of its blocks. Blocks already on the wire when an event starts arrive regardless.
+// When the event heals, the miners get their links back and sync: each sends its neighbors its canonical blocks
+// mined since the event started. The run then measures how deep the miners reorg, how long they take to converge
+// on one head, and which group's chain they converge on.
+const (
+	NetworkPartition = "partition"
+	NetworkEclipse   = "eclipse"
+)
+
+// NetworkEvent describes a change to the miner graph.
+type NetworkEvent struct {
+	// Kind is NetworkPartition or NetworkEclipse.
+	Kind string
+
+	AtSeconds float64
+
+	// DurationSeconds is how long until the event heals; zero never heals.
+	DurationSeconds float64
+
+	// Groups are the partition's groups by miner address; miners left out make up one more group.
+	// Without groups, the miners are split in two, alternating by index (ie. by hashrate for a population).
+	Groups [][]string
+
+	// Miner is the eclipsed miner's address, and Attackers those of the miners it's left with.
+	Miner     string
+	Attackers []string
+}
+
+func (e NetworkEvent) validate() error {
+	switch e.Kind {
+	case NetworkPartition:
+		if e.Miner != "" || len(e.Attackers) > 0 {
+			return fmt.Errorf("partition: has no miner or attackers")
+		}
+	case NetworkEclipse:
+		if e.Miner == "" || len(e.Attackers) == 0 {
+			return fmt.Errorf("eclipse: needs a miner and attackers")
+		}
+		if len(e.Groups) > 0 {
+			return fmt.Errorf("eclipse: has no groups")
+		}
+	default:
+		return fmt.Errorf("unknown network event: %q (want %s or %s)", e.Kind, NetworkPartition, NetworkEclipse)
+	}
+	if e.AtSeconds < 0 || e.DurationSeconds < 0 {
+		return fmt.Errorf("%s: times must not be negative", e.Kind)
+	}
+	return nil
+}
+
+// validNetworkEvents checks the events, which must not overlap.
+func validNetworkEvents(events []NetworkEvent) error {
+	for i, e := range events {
+		if err := e.validate(); err != nil {
+			return err
+		}
+		for _, f := range events[:i] {
+			fEnd, eEnd := math.Inf(1), math.Inf(1)
+			if f.DurationSeconds > 0 {
+				fEnd = f.AtSeconds + f.DurationSeconds
+			}
+			if e.DurationSeconds > 0 {
+				eEnd = e.AtSeconds + e.DurationSeconds
+			}
+			if e.AtSeconds < fEnd && f.AtSeconds < eEnd {
+				return fmt.Errorf("network events at %gs and %gs overlap", f.AtSeconds, e.AtSeconds)
+			}
+		}
+	}
+	return nil
+}
+
+// networkEvent is a NetworkEvent in a run.
+type networkEvent struct {
+	NetworkEvent
+	at, heal int64 // ticks; heal is 0 if it never heals
+
+	// groups are the partition's groups, or, for an eclipse, the eclipsed miner and everyone else.
+	groups    [][]*Miner
+	attackers map[*Miner]bool
+
+	saved          map[*Miner][]*link // the links the event replaced
+	started        bool
+	healHeads      map[*Miner]*Block // each miner's head at the heal
+	converged      bool
+	networkOutcome // once healed
+}
+
+// networkOutcome is what became of a healed network event.
+type networkOutcome struct {
+	// ReorgDepth is the most blocks a miner dropped in a reorg after the heal (up to convergence).
+	ReorgDepth int
+
+	// ConvergenceSeconds is the time from the heal until every miner had the same head; NaN if they never did.
+	ConvergenceSeconds float64
+
+	// Winner is the group whose chain the miners converged on (the one with the highest head of those that did),
+	// or -1 if they never converged.
+	Winner int
+}
+
+// setupNetwork resolves the configured network events for the miners.
+func (s *Simulation) setupNetwork(miners []*Miner) error {
+	byAddress := map[string]*Miner{}
+	for _, m := range miners {
+		byAddress[m.Address] = m
+	}
+	find := func(address string) (*Miner, error) {
+		if m, ok := byAddress[address]; ok {
+			return m, nil
+		}
+		return nil, fmt.Errorf("network event: no miner %s", address)
+	}
+
+	s.network = nil
+	for _, c := range s.NetworkEvents {
+		e := &networkEvent{
+			NetworkEvent:   c,
+			at:             int64(c.AtSeconds * float64(s.TicksPerSecond)),
+			attackers:      map[*Miner]bool{},
+			networkOutcome: networkOutcome{ConvergenceSeconds: math.NaN(), Winner: -1},
+		}
+		if c.DurationSeconds > 0 {
+			e.heal = e.at + int64(c.DurationSeconds*float64(s.TicksPerSecond))
+		}
+
+		grouped := map[*Miner]bool{}
+		switch c.Kind {
+		case NetworkPartition:
+			for _, addresses := range c.Groups {
+				group := []*Miner{}
+				for _, a := range addresses {
+					m, err := find(a)
+					if err != nil {
+						return err
+					}
+					if grouped[m] {
+						return fmt.Errorf("network event: miner %s in two groups", a)
+					}
+					grouped[m] = true
+					group = append(group, m)
+				}
+				e.groups = append(e.groups, group)
+			}
+			if len(c.Groups) == 0 {
+				e.groups = make([][]*Miner, 2)
+				for i, m := range miners {
+					e.groups[i%2] = append(e.groups[i%2], m)
+					grouped[m] = true
+				}
+			}
+		case NetworkEclipse:
+			victim, err := find(c.Miner)
+			if err != nil {
+				return err
+			}
+			for _, a := range c.Attackers {
+				m, err := find(a)
+				if err != nil {
+					return err
+				}
+				if m == victim {
+					return fmt.Errorf("network event: miner %s eclipses itself", a)
+				}
+				e.attackers[m] = true
+			}
+			e.groups = [][]*Miner{{victim}}
+			grouped[victim] = true
+		}
+		rest := []*Miner{}
+		for _, m := range miners {
+			if !grouped[m] {
+				rest = append(rest, m)
+			}
+		}
+		if len(rest) > 0 {
+			e.groups = append(e.groups, rest)
+		}
+		s.network = append(s.network, e)
+	}
+	return nil
+}
+
+// nextNetworkChange is the tick of the next network event start or heal, or math.MaxInt64.
+func (s *Simulation) nextNetworkChange() int64 {
+	next := int64(math.MaxInt64)
+	for _, e := range s.network {
+		switch {
+		case !e.started && e.at < next:
+			next = e.at
+		case e.started && e.healHeads == nil && e.heal > 0 && e.heal < next:
+			next = e.heal
+		}
+	}
+	return next
+}
+
+// changeNetwork starts and heals the network events due by the tick, in order, each at its own tick.
+func (s *Simulation) changeNetwork(miners []*Miner, tick int64) {
+	for next := s.nextNetworkChange(); next <= tick; next = s.nextNetworkChange() {
+		s.setNow(miners, next)
+		for _, e := range s.network {
+			switch {
+			case !e.started && e.at == next:
+				e.start(miners)
+			case e.started && e.healHeads == nil && e.heal == next:
+				e.restore(miners)
+			}
+		}
+	}
+}
+
+// start replaces the links the event cuts, saving them for the heal.
+func (e *networkEvent) start(miners []*Miner) {
+	e.started = true
+	e.saved = map[*Miner][]*link{}
+	group := map[*Miner]int{}
+	for i, g := range e.groups {
+		for _, m := range g {
+			group[m] = i
+		}
+	}
+
+	for _, m := range miners {
+		e.saved[m] = m.neighbors
+		neighbors := []*link{}
+		switch e.Kind {
+		case NetworkPartition:
+			for _, l := range m.neighbors {
+				if group[l.to] == group[m] {
+					neighbors = append(neighbors, l)
+				}
+			}
+		case NetworkEclipse:
+			victim := e.groups[0][0]
+			switch {
+			case m == victim:
+				linked := map[*Miner]bool{}
+				for _, l := range m.neighbors {
+					if e.attackers[l.to] {
+						neighbors = append(neighbors, l)
+						linked[l.to] = true
+					}
+				}
+				for _, a := range miners {
+					if e.attackers[a] && !linked[a] {
+						neighbors = append(neighbors, &link{to: a, latency: m.Latency})
+					}
+				}
+			case e.attackers[m]:
+				// The attackers keep the victim's blocks to themselves.
+				toVictim := &link{to: victim, latency: m.Latency}
+				for _, l := range m.neighbors {
+					if l.to == victim {
+						toVictim = l
+						continue
+					}
+					neighbors = append(neighbors, l.only(func(b *Block) bool { return b.miner != victim.Address }))
+				}
+				neighbors = append(neighbors, toVictim)
+			default:
+				for _, l := range m.neighbors {
+					if l.to != victim {
+						neighbors = append(neighbors, l)
+					}
+				}
+			}
+		}
+		m.neighbors = neighbors
+	}
+}
+
+// restore gives the miners their links back, and has them sync their canonical blocks since the event started.
+func (e *networkEvent) restore(miners []*Miner) {
+	e.healHeads = map[*Miner]*Block{}
+	for _, m := range miners {
+		m.neighbors = e.saved[m]
+		e.healHeads[m] = m.head
+	}
+	e.saved = nil
+
+	for _, m := range miners {
+		withheld := map[*Block]bool{}
+		for _, b := range m.withheld {
+			withheld[b] = true
+		}
+		blocks := m.canon.Where(func(b *Block) bool {
+			return b.t >= e.at && !withheld[b]
+		})
+		sort.Slice(blocks, func(i, j int) bool { return blocks[i].i < blocks[j].i })
+		for _, l := range m.neighbors {
+			l.send(m, blocks)
+		}
+	}
+}
+
+// observe checks whether the miners have converged on one head since the heal, and if so,
+// settles the event's outcome.
+func (e *networkEvent) observe(s *Simulation, miners []*Miner) {
+	if e.healHeads == nil || e.converged {
+		return
+	}
+	head := miners[0].head
+	for _, m := range miners[1:] {
+		if m.head != head {
+			return
+		}
+	}
+	e.converged = true
+	e.ConvergenceSeconds = float64(s.now-e.heal) / float64(s.TicksPerSecond)
+	e.settle(miners)
+
+	// The group with the highest head at the heal of those on the chain won.
+	var best *Block
+	for i, g := range e.groups {
+		for _, m := range g {
+			h := e.healHeads[m]
+			if miners[0].Blocks.CommonAncestor(head, h) == h && (best == nil || h.i > best.i) {
+				best, e.Winner = h, i
+			}
+		}
+	}
+}
+
+// settle measures the reorgs since the heal.
+func (e *networkEvent) settle(miners []*Miner) {
+	e.ReorgDepth = 0
+	for _, m := range miners {
+		for _, r := range m.reorgs {
+			if r.tick >= e.heal && r.drop > e.ReorgDepth {
+				e.ReorgDepth = r.drop
+			}
+		}
+	}
+}
+
+// observeNetwork checks the healed network events for convergence.
+func (s *Simulation) observeNetwork(miners []*Miner) {
+	for _, e := range s.network {
+		e.observe(s, miners)
+	}
+}
+
+// finishNetwork settles the outcome of the healed events that haven't converged by the end of the run.
+func (s *Simulation) finishNetwork(miners []*Miner) {
+	for _, e := range s.network {
+		if e.healHeads != nil && !e.converged {
+			e.settle(miners)
+		}
+	}
+}
+
+// only returns a copy of the link that carries only the blocks the link carries and for which carries is true.
+func (l *link) only(carries func(b *Block) bool) *link {
+	c := *l
+	if l.carries != nil {
+		c.carries = func(b *Block) bool { return l.carries(b) && carries(b) }
+	} else {
+		c.carries = carries
+	}
+	return &c
+}
+
+// send delivers the blocks over the link, in order and all at once, the way a peer syncs a chain.
+func (l *link) send(from *Miner, blocks Blocks) {
+	latency := l.latency()
+	for _, b := range blocks {
+		if l.carries != nil && !l.carries(b) {
+			continue
+		}
+		l.to.receiveBlock(&delivery{
+			block: b,
+			from:  from,
+			hops:  1,
+			sent:  from.sim.now,
+			delay: Delay{material: latency + from.sim.transferTicks(b)},
+		})
+	}
+}
+
+// writeNetworkEventsCSV writes the outcome of the network events: one row per event.
+func writeNetworkEventsCSV(filename string, s *Simulation) error {
+	records := [][]string{{"kind", "at_seconds", "duration_seconds", "groups", "reorg_depth", "convergence_seconds", "winner"}}
+	for _, e := range s.network {
+		groups := []string{}
+		for _, g := range e.groups {
+			addresses := []string{}
+			for _, m := range g {
+				addresses = append(addresses, m.Address)
+			}
+			groups = append(groups, strings.Join(addresses, " "))
+		}
+		records = append(records, []string{
+			e.Kind, formatFloat(e.AtSeconds), formatFloat(e.DurationSeconds), strings.Join(groups, "|"),
+			strconv.Itoa(e.ReorgDepth), formatFloat(e.ConvergenceSeconds), strconv.Itoa(e.Winner),
+		})
+	}
+	return writeCSV(filename, records)
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestValidNetworkEvents(t *testing.T) {
+	partition := NetworkEvent{Kind: NetworkPartition, AtSeconds: 60, DurationSeconds: 60}
+	for _, c := range []struct {
+		events []NetworkEvent
+		ok     bool
+	}{
+		{[]NetworkEvent{partition, {Kind: NetworkPartition, AtSeconds: 120}}, true},
+		{[]NetworkEvent{partition, {Kind: NetworkPartition, AtSeconds: 100}}, false},
+		{[]NetworkEvent{{Kind: NetworkPartition}, {Kind: NetworkPartition, AtSeconds: 1000}}, false}, // never heals
+		{[]NetworkEvent{{Kind: NetworkEclipse, Miner: "aa0000", Attackers: []string{"bb0000"}}}, true},
+		{[]NetworkEvent{{Kind: NetworkEclipse, Miner: "aa0000"}}, false},
+		{[]NetworkEvent{{Kind: NetworkPartition, Miner: "aa0000"}}, false},
+		{[]NetworkEvent{{Kind: "flood"}}, false},
+		{[]NetworkEvent{{Kind: NetworkPartition, AtSeconds: -1}}, false},
+	} {
+		if err := validNetworkEvents(c.events); (err == nil) != c.ok {
+			t.Errorf("%+v: %v", c.events, err)
+		}
+	}
+}
+
+// runNetworkScenario runs the scenario under the engine, calling during in the last minute before the first event heals.
+func runNetworkScenario(t *testing.T, sc *Scenario, engine string, during func(sim *Simulation, miners []*Miner)) (*Simulation, []*Miner) {
+	t.Helper()
+	sc.Globals.Engine = engine
+	if err := sc.validate(); err != nil {
+		t.Fatal(err)
+	}
+	sim := NewSimulation(sc.config())
+	miners, err := sc.miners(sim, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checked := false
+	err = sim.run(miners, func(tick int64) error {
+		if e := sim.network[0]; !checked && e.started && e.healHeads == nil && tick >= e.heal-60*sim.TicksPerSecond {
+			during(sim, miners)
+			checked = true
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !checked {
+		t.Fatal("never checked during the event")
+	}
+	return sim, miners
+}
+
+func TestPartition(t *testing.T) {
+	for _, engine := range []string{EngineTick, EngineEvent} {
+		sc := defaultScenario()
+		sc.Seed = 2
+		sc.Population.Count = 4
+		sc.Population.HashrateDist = HashrateDistEqual.String()
+		sc.Globals.Topology = &ScenarioTopology{Kind: TopologyComplete}
+		sc.Globals.TickSamples = 10 * 60 * 60
+		sc.Globals.NetworkEvents = []ScenarioNetworkEvent{{Kind: NetworkPartition, AtSeconds: 600, DurationSeconds: 1800}}
+
+		sim, miners := runNetworkScenario(t, sc, engine, func(sim *Simulation, miners []*Miner) {
+			// Neither half has seen a block the other mined since the partition (and its blocks on the wire).
+			e := sim.network[0]
+			for i, m := range miners {
+				other := miners[(i+1)%2]
+				if n := len(m.Blocks.Where(func(b *Block) bool {
+					return b.miner == other.Address && b.t > e.at+10*sim.TicksPerSecond
+				})); n > 0 {
+					t.Errorf("%s: miner %d has %d blocks of the other half", engine, i, n)
+				}
+			}
+		})
+
+		e := sim.network[0]
+		if len(e.groups) != 2 || len(e.groups[0]) != 2 || e.groups[1][0] != miners[1] {
+			t.Fatalf("%s: groups %v", engine, e.groups)
+		}
+		if math.IsNaN(e.ConvergenceSeconds) || e.Winner < 0 || e.ReorgDepth < 10 {
+			t.Errorf("%s: outcome %+v", engine, e.networkOutcome)
+		}
+		for _, m := range miners {
+			if len(m.neighbors) != 3 {
+				t.Errorf("%s: %s has %d neighbors after the heal", engine, m.Address, len(m.neighbors))
+			}
+		}
+	}
+}
+
+func TestEclipse(t *testing.T) {
+	for _, engine := range []string{EngineTick, EngineEvent} {
+		sc := defaultScenario()
+		sc.Seed = 4
+		sc.Population.Count = 4
+		sc.Globals.TickSamples = 10 * 60 * 60
+		sc.Miners = []ScenarioMiner{{Address: "ee0000", Hashrate: 0.2}, {Address: "aa0000", Hashrate: 0.2}}
+		sc.Globals.NetworkEvents = []ScenarioNetworkEvent{{Kind: NetworkEclipse, AtSeconds: 600, DurationSeconds: 1800, Miner: "ee0000", Attackers: []string{"aa0000"}}}
+
+		sim, miners := runNetworkScenario(t, sc, engine, func(sim *Simulation, miners []*Miner) {
+			e := sim.network[0]
+			since := func(b *Block) bool { return b.t > e.at+10*sim.TicksPerSecond }
+			for _, m := range miners {
+				switch m.Address {
+				case "ee0000":
+					// The victim is linked only to the attacker, and sees the chain through it.
+					for _, l := range m.neighbors {
+						if l.to.Address != "aa0000" {
+							t.Errorf("%s: the victim is linked to %s", engine, l.to.Address)
+						}
+					}
+					if n := len(m.Blocks.Where(func(b *Block) bool { return since(b) && b.miner == "aa0000" })); n == 0 {
+						t.Errorf("%s: the victim has no attacker blocks", engine)
+					}
+				case "aa0000":
+				default:
+					if n := len(m.Blocks.Where(func(b *Block) bool { return since(b) && b.miner == "ee0000" })); n > 0 {
+						t.Errorf("%s: %s has %d of the victim's blocks", engine, m.Address, n)
+					}
+				}
+			}
+		})
+
+		e := sim.network[0]
+		if len(e.groups) != 2 || e.groups[0][0].Address != "ee0000" || len(e.groups[1]) != 5 {
+			t.Fatalf("%s: groups %v", engine, e.groups)
+		}
+		if e.Winner < 0 {
+			t.Errorf("%s: outcome %+v", engine, e.networkOutcome)
+		}
+		// After the heal, the victim is back on the network's chain.
+		if victim := miners[4]; victim.canon[victim.head.i-5] != miners[0].canon[victim.head.i-5] {
+			t.Errorf("%s: the victim isn't on the network's chain", engine)
+		}
+	}
+}
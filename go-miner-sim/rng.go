@@ -0,0 +1,47 @@
+package main
+
+import (
+	"hash/fnv"
+	"math/rand"
+)
+
+// Named random streams.
+// Each is derived independently from the simulation seed, so drawing more (or less)
+// from one stream, eg. because of a change in mining, doesn't shift the others.
+const (
+	rngHashing     = "hashing"     // fakeHashimoto trials
+	rngDiscovery   = "discovery"   // event engine block discovery waits
+	rngBlockHashes = "blockhashes" // block hash values
+	rngTxPool      = "txpool"      // tx pool TAB draws
+	rngMempool     = "mempool"     // mempool accounts and transactions
+	rngArbitration = "arbitration" // coin toss arbitration
+	rngNeighbors   = "neighbors"   // neighbor graph construction
+	rngLatency     = "latency"     // link latency draws
+	rngTicks       = "ticks"       // miner tick ordering
+)
+
+// splitmix64 is a fast, well-distributed 64-bit mixing function.
+// http://xoshiro.di.unimi.it/splitmix64.c
+func splitmix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+// streamSeed derives the seed of a named stream from the simulation seed.
+func streamSeed(seed int64, name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return int64(splitmix64(uint64(seed) ^ splitmix64(h.Sum64())))
+}
+
+// rng returns the simulation's named random stream, creating it on first use.
+func (s *Simulation) rng(name string) *rand.Rand {
+	r, ok := s.rngs[name]
+	if !ok {
+		r = rand.New(rand.NewSource(streamSeed(s.Seed, name)))
+		s.rngs[name] = r
+	}
+	return r
+}
@@ -0,0 +1,766 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+
+	"github.com/fogleman/gg"
+	colorful "github.com/lucasb-eyer/go-colorful"
+	"github.com/mazznoer/colorgrad"
+	"github.com/montanaflynn/stats"
+	"golang.org/x/image/colornames"
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+)
+
+// deriveMinerRelativeDifficultyHashes gives a miner with relative hashrate r its hashes per tick
+// against the genesis difficulty.
+func deriveMinerRelativeDifficultyHashes(genesisD int64, r float64) int64 {
+	return int64(float64(genesisD) * r)
+}
+
+// deriveMinerStartingBalance gives a miner its starting balance from a supply shared by n miners.
+// We use relative hashrate as a proxy for balance;
+// more mining capital :: more currency capital.
+func deriveMinerStartingBalance(genesisTABS int64, n int64, minerHashrate float64) int64 {
+	// supply := genesisTABS * n
+	supply := genesisTABS / presumeMinerShareBalancePerBlockDenominator * n
+	return int64((float64(supply) * minerHashrate))
+}
+
+// minerNames derives unique miner names from a descending list of hashrates.
+// The names are hex colors (sans '#') from the Viridis gradient, which the plots use to color the miners.
+func minerNames(hashrates []float64) (names []string) {
+	lastColor := colorful.Color{}
+	grad := colorgrad.Viridis()
+
+	for i := range hashrates {
+		clr := grad.At(1 - (hashrates[i] * (1 / hashrates[0])))
+		if clr == lastColor {
+			// Make sure colors (names) are unique.
+			clr.R++
+		}
+		lastColor = clr
+		names = append(names, clr.Hex()[1:])
+
+		// format := "#%02x%02x%02x"
+		// minerName := fmt.Sprintf("%02x%02x%02x", clr.R, clr.G, clr.B)
+	}
+	return names
+}
+
+// newMiner sets up a miner with its own starting view of the chain (genesis only)
+// and the default send delay and latency.
+// The caller should process the genesis block once the miner is configured.
+func newMiner(sim *Simulation, index int64, address string, hashrate float64, balance int64, minerEvents chan minerEvent) *Miner {
+
+	// set up their starting view of the chain
+	bt := NewBlockTree()
+	bt.AppendBlockByNumber(sim.genesisBlock)
+
+	m := &Miner{
+		sim: sim,
+		// ConsensusAlgorithm: TDTABS,
+		// ConsensusAlgorithm: TD,
+		Index:         index,
+		Address:       address, // avoid collisions
+		Hashrate:      hashrate,
+		HashesPerTick: deriveMinerRelativeDifficultyHashes(sim.genesisBlock.d, hashrate),
+		Balance:       balance,
+		capital:       balance,
+		// BalanceCap:               minerStartingBalance,
+		Blocks:                   bt,
+		canon:                    NewChain(),
+		head:                     nil,
+		deliveries:               map[int64][]*delivery{},
+		arrivals:                 map[string]arrival{},
+		rejected:                 map[string]bool{},
+		orphans:                  map[string][]*delivery{},
+		neighbors:                []*link{},
+		reorgs:                   make(map[int64]reorg),
+		decisionConditionTallies: make(map[string]int),
+		cord:                     minerEvents,
+		SendDelay: func(block *Block) int64 {
+			return int64(sim.DelaySeconds * float64(sim.TicksPerSecond))
+			// return int64(hr * 3 * rand.Float64() * float64(sim.TicksPerSecond))
+		},
+		Latency: func() int64 {
+			return int64(sim.LatencySeconds * float64(sim.TicksPerSecond))
+			// return int64(4 * float64(sim.TicksPerSecond))
+			// return int64((4 * rand.Float64()) * float64(sim.TicksPerSecond))
+		},
+	}
+	if sim.ReceivePostponeSeconds > 0 {
+		m.ReceiveDelay = func(block *Block) int64 {
+			return int64(sim.ReceivePostponeSeconds * float64(sim.TicksPerSecond))
+		}
+	}
+	return m
+}
+
+func minersNormal(sim *Simulation, minerEvents chan minerEvent, mut func(m *Miner)) (miners []*Miner) {
+
+	n := sim.CountMiners
+	hashrates := generateMinerHashrates(sim.HashrateDist, int(n))
+	names := minerNames(hashrates)
+
+	for i := int64(0); i < n; i++ {
+
+		// minerStartingBalance := deriveMinerStartingBalance(sim.genesisBlock.tabs, n, hashrates[i])
+		minerStartingBalance := deriveMinerStartingBalance(sim.genesisBlock.tabs, n, hashrates[n-1-i]) // backwards
+
+		m := newMiner(sim, i, names[i], hashrates[i], minerStartingBalance, minerEvents)
+
+		mut(m)
+
+		m.processBlock(sim.genesisBlock) // sets head to genesis
+		miners = append(miners, m)
+	}
+
+	return miners
+}
+
+func minersTwo(sim *Simulation, minerEvents chan minerEvent, mut func(m *Miner)) (miners []*Miner) {
+
+	// hashrates := generateMinerHashrates(HashrateDistLongtail, int(sim.CountMiners))
+	hashrates := []float64{0.45, 0.35, 0.2}
+	names := minerNames(hashrates)
+	n := int64(len(hashrates))
+
+	for i := int64(0); i < n; i++ {
+
+		// minerStartingBalance := deriveMinerStartingBalance(sim.genesisBlock.tabs, n, hashrates[i])
+		minerStartingBalance := deriveMinerStartingBalance(sim.genesisBlock.tabs, n, hashrates[n-1-i]) // backwards
+
+		m := newMiner(sim, i, names[i], hashrates[i], minerStartingBalance, minerEvents)
+
+		mut(m)
+
+		m.processBlock(sim.genesisBlock) // sets head to genesis
+		miners = append(miners, m)
+	}
+
+	return miners
+}
+
+// minersBuilder constructs the miner population for a run, wiring each miner to the minerEvents channel.
+type minersBuilder func(sim *Simulation, minerEvents chan minerEvent) ([]*Miner, error)
+
+// testPlottingMiners builds the TestPlotting population: the minersNormal miners,
+// optionally followed by the attack miner. The mutation is applied to all of them.
+func testPlottingMiners(mut func(m *Miner), withAttacker bool) minersBuilder {
+	return func(sim *Simulation, minerEvents chan minerEvent) ([]*Miner, error) {
+		miners := minersNormal(sim, minerEvents, mut)
+		// miners = minersTwo(sim, minerEvents, mut)
+
+		if withAttacker {
+			attackMiner := newAttackMiner(sim, int64(len(miners)), minerEvents)
+			mut(attackMiner)
+			attackMiner.processBlock(sim.genesisBlock)
+			miners = append(miners, attackMiner)
+		}
+		return miners, nil
+	}
+}
+
+// newAttackMiner creates an attack miner (see attackMinerSpec).
+// This miner will NOT publish their blocks.
+// They will be rich.
+// attack: 1606651707293287461
+// defend:  203433894893418879
+func newAttackMiner(sim *Simulation, index int64, minerEvents chan minerEvent) *Miner {
+	return attackMinerSpec.newMiner(sim, index, sim.CountMiners, minerEvents)
+}
+
+// plotSet names the plots a run should produce. An empty set means all of them.
+type plotSet map[string]bool
+
+// allPlots lists the plot names, in the order they're made.
+var allPlots = []string{"anim", "intervals", "difficulties", "tabs", "tds", "ttdtabs_ts", "ttdtabs_blockn", "reorgs", "balances"}
+
+func (ps plotSet) has(name string) bool {
+	return len(ps) == 0 || ps[name]
+}
+
+// runPlotting runs a single scenario through the tick loop and writes the per-miner logs,
+// block trees, plots and (if ffmpeg is available) the chain growth animation into outDir.
+func runPlotting(sim *Simulation, name, outDir string, newMiners minersBuilder, plots plotSet, logf func(args ...interface{})) error {
+
+	logf("Running", name, "seed", sim.Seed)
+
+	os.MkdirAll(outDir, os.ModePerm)
+	if plots.has("anim") {
+		os.RemoveAll(filepath.Join(outDir, "anim"))
+		os.MkdirAll(filepath.Join(outDir, "anim"), os.ModePerm)
+	}
+
+	minerEvents := make(chan minerEvent)
+	blockRowsN := 150
+
+	miners, err := newMiners(sim, minerEvents)
+	if err != nil {
+		return err
+	}
+
+	c := gg.NewContext(800, 1200)
+	marginX, marginY := c.Width()/100, c.Width()/100
+
+	c.Push()
+	c.SetColor(colornames.White)
+	c.DrawRectangle(0, 0, float64(c.Width()), float64(c.Height()))
+	c.Fill()
+	c.Stroke()
+	c.Pop()
+
+	if plots.has("anim") {
+		c.SavePNG(filepath.Join(outDir, "anim", "out.png"))
+	}
+
+	videoBlackRedForks := false
+	// videoBlackRedForks := true
+
+	go func() {
+		c.Push() // unresolved state push
+
+		for event := range minerEvents {
+			// logf("minerEvent", event)
+
+			// logf("here", 1)
+			// t.Logf("event: %v", event)
+
+			// ctx.DrawCircle(rand.Float64()*float64(c.Width), rand.Float64()*float64(c.Height), 10)
+
+			xW := (c.Width() - (2 * marginX)) / len(miners) // scenario and attack miners come after the population
+			x := event.minerI*xW + marginX
+
+			yH := (c.Height() - (2 * marginY)) / blockRowsN
+			var y int64
+			// if event.i > blockRowsN{
+			// 	y = 0
+			// } else {
+			y = int64(c.Height()) - (event.i%int64(blockRowsN))*int64(yH) + int64(marginY)
+			// }
+
+			// Clear the row above on bottom-up overlap/overdraw.
+			c.Push()
+			c.SetColor(colornames.White)
+			c.DrawRectangle(0, float64(y-int64(yH*5)), float64(c.Width()), float64(yH*5))
+			c.Fill()
+			c.Stroke()
+			c.Pop()
+
+			// if event.i > 200 {
+			// 	// c.Push()
+			// 	// c.Translate(0, float64(yH))
+			// 	// c.Stroke()
+			// 	// c.Pop()
+			// }
+
+			nblocks := len(event.blocks)
+
+			// // Outline competitive blocks for visibility..
+			// if nblocks > 1 {
+			// 	c.Push()
+			// 	c.SetColor(colornames.Black)
+			// 	c.SetLineWidth(1)
+			// 	c.DrawRectangle(float64(x-2), float64(y-2), float64(xW+4), float64(yH+4))
+			// 	// c.Fill()
+			// 	c.Stroke()
+			// 	c.Pop()
+			// }
+
+			// Or, more better, when you're interested in seeing forks,
+			// just don't print the uncontested blocks.
+			// if nblocks <= 1 {
+			// 	continue
+			// }
+
+			for ib, b := range event.blocks {
+				// t.Logf("push")
+				c.Push()
+				if videoBlackRedForks {
+					// Black blocks = uncontested
+					// Red   blocks = network forks
+					clr := colornames.Black
+					if nblocks > 1 {
+						clr = colornames.Red
+					}
+					c.SetColor(clr)
+				} else {
+					// Get the block color from the block's authoring miner.
+					clr, err := ParseHexColor("#" + b.miner)
+					if err != nil {
+						logf("bad color", err.Error(), b.miner)
+						panic("test")
+					}
+					c.SetColor(clr)
+				}
+
+				realX := float64(x)
+				realX += float64(ib) * float64(xW/nblocks)
+
+				rectMargin := float64(0)
+
+				rectX, rectY := realX+rectMargin, float64(y)+rectMargin
+				rectW, rectH := float64(xW/nblocks)-(2*rectMargin), float64(yH)-(2*rectMargin)
+
+				// logf("here.ib", ib, b == nil, b.miner, clr)
+				// t.Logf("x=%d y=%d width=%v height=%v b=%d/%d", x, y, xW, yH, ib, nblocks)
+				c.DrawRectangle(rectX, rectY, rectW, rectH)
+				c.Fill()
+				c.Stroke()
+				c.Pop()
+				// t.Logf("pop")
+			}
+
+			// logf("here", 2)
+
+		}
+	}()
+
+	lastHighBlock := int64(0)
+	err = sim.run(miners, func(s int64) error {
+		if s%sim.TicksPerSecond == 0 {
+			// time.Sleep(time.Millisecond * 100)
+		}
+		nextHighBlock := Miners(miners).headMax()
+		if nextHighBlock > lastHighBlock {
+			// if s%sim.TicksPerSecond == 0 {
+
+			if plots.has("anim") {
+				if err := c.SavePNG(filepath.Join(outDir, "anim", fmt.Sprintf("%04d_f.png", nextHighBlock))); err != nil {
+					return fmt.Errorf("save png errored: %w", err)
+				}
+			}
+
+			lastHighBlock = nextHighBlock
+			// 	// Human-readable intervals.
+			//
+			// 	line := ""
+			//
+			// 	for i, m := range miners {
+			// 		fmt.Sprintf(`%s`,
+			// 			strings.Repeat("\", i))
+			// 	}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	logf("RESULTS", name)
+
+	results := resultsOf(miners)
+	for i, m := range miners {
+		r := results[i]
+		kMed, _ := stats.Median(m.Blocks.Ks())
+		kMode, _ := stats.Mode(m.Blocks.Ks())
+
+		minerLog := fmt.Sprintf(`a=%s c=%s hr=%0.2f winr=%0.3f wins=%d head.i=%d head.tabs=%d head.td=%d head.tdtabs=%d k_mean=%0.3f k_med=%0.3f k_mode=%v intervals_mean=%0.3fs d_mean.rel=%0.3f balance=%d objective_decs=%0.3f arbs=%d reorgs.mag_mean=%0.3f
+`,
+			m.Address, m.ConsensusAlgorithm, m.Hashrate, r.WinRate, r.Wins, /* m.HashesPerTick, */
+			m.head.i, m.head.tabs, m.head.td, m.head.ttdtabs,
+			r.KMean, kMed, kMode,
+			r.IntervalsMeanSeconds, r.DifficultiesRelGenesisMean,
+			m.Balance,
+			r.DecisiveArbitrationRate,
+			m.ConsensusArbitrations,
+			r.ReorgMagnitudesMean)
+		if r.Strategy != Honest {
+			minerLog += fmt.Sprintf("strategy=%s revenue.rel=%0.3f hashrate.share=%0.3f withheld=%d tab.rel=%0.3f\n", r.Strategy, r.RelativeRevenue, r.HashrateShare, len(m.withheld), r.TABRel)
+			if r.Twin != "" {
+				minerLog += fmt.Sprintf("twin=%s twin.advantage=%+0.3f\n", r.Twin, r.TwinAdvantage)
+			}
+		}
+		if r.TimestampPolicy != HonestTimestamp || r.Rejected > 0 {
+			minerLog += fmt.Sprintf("timestamp_policy=%s revenue.rel=%0.3f hashrate.share=%0.3f intervals_mean=%0.3fs rejected=%d\n",
+				r.TimestampPolicy, r.RelativeRevenue, r.HashrateShare, r.IntervalsMeanSeconds, r.Rejected)
+		}
+		if r.TxPolicy != nil {
+			minerLog += fmt.Sprintf("tx_policy=%s fees=%0.6f objective_arbs=%d\n", r.TxPolicy, r.Fees, r.ConsensusObjectiveArbitrations)
+		}
+		if r.SellPolicy != nil {
+			minerLog += fmt.Sprintf("sell_policy=%s income=%0.3f expenses=%0.3f profit=%0.3f sold=%0.3f\n",
+				r.SellPolicy, r.Ledger.Income(), r.Ledger.Expenses(), r.Ledger.Profit(), r.Ledger.Sold)
+		}
+
+		// m.ConsensusArbitrations/m.head.i should be the kMean
+		// This is: how many block decisions were arbitrated (ie how many total blocks were seen)
+		// versus   how many blocks were canonical (how high the tree was).
+
+		arbitrationConditionTallyLine := ""
+		// I iterate these copypasta strings because I want order.
+		for _, name := range []string{"strategy", "consensus_score_high", "timestamp", "height_low", "miner_selfish", "random"} {
+			v, ok := m.decisionConditionTallies[name]
+			if !ok {
+				continue
+			}
+			fv := float64(v) / float64(m.ConsensusArbitrations)
+			arbitrationConditionTallyLine += fmt.Sprintf(`%s=%0.2f `, name, fv)
+		}
+
+		minerLog += arbitrationConditionTallyLine + "\n"
+
+		logf(minerLog)
+
+		// Log the stats of the miner
+		ioutil.WriteFile(filepath.Join(outDir, fmt.Sprintf("miner_%d", i)), []byte(minerLog), os.ModePerm)
+		// Log the block tree belonging to this miner
+		ioutil.WriteFile(filepath.Join(outDir, fmt.Sprintf("miner_%d_bt", i)), []byte(m.Blocks.format(m.canon)), os.ModePerm)
+	}
+
+	if sim.Economics != nil {
+		if err := writeLedgerCSV(filepath.Join(outDir, "ledger.csv"), miners); err != nil {
+			return err
+		}
+		if err := writeBalancesCSV(filepath.Join(outDir, "balances.csv"), miners, sim.TicksPerSecond); err != nil {
+			return err
+		}
+	}
+
+	for _, e := range sim.network {
+		logf(fmt.Sprintf("network_event=%s at=%gs duration=%gs groups=%d reorg.depth=%d converge.seconds=%0.1f winner=%d",
+			e.Kind, e.AtSeconds, e.DurationSeconds, len(e.groups), e.ReorgDepth, e.ConvergenceSeconds, e.Winner))
+	}
+	if len(sim.network) > 0 {
+		if err := writeNetworkEventsCSV(filepath.Join(outDir, "network_events.csv"), sim); err != nil {
+			return err
+		}
+	}
+
+	logf("Making plots...")
+
+	plotIntervals := func() {
+		filename := filepath.Join(outDir, "sample_intervals.png")
+		p := plot.New()
+
+		buckets := map[int]int{}
+		for _, b := range miners[0].Blocks.Where(func(*Block) bool { return true }) {
+			buckets[int(b.si/sim.TicksPerSecond)]++
+		}
+		data := plotter.XYs{}
+		for k, v := range buckets {
+			data = append(data, plotter.XY{X: float64(k), Y: float64(v)})
+		}
+		hist, err := plotter.NewHistogram(data, len(buckets))
+		if err != nil {
+			panic(err)
+		}
+		p.Add(hist)
+		p.Save(800, 300, filename)
+	}
+	if plots.has("intervals") {
+		plotIntervals()
+	}
+
+	plotDifficulty := func() {
+		filename := filepath.Join(outDir, "block_difficulties.png")
+		p := plot.New()
+
+		data := plotter.XYs{}
+		for _, k := range miners[0].Blocks.Numbers() {
+			data = append(data, plotter.XY{X: float64(k), Y: float64(miners[0].Blocks.GetBlocksByNumber(k)[0].d)})
+		}
+		scatter, err := plotter.NewScatter(data)
+		if err != nil {
+			panic(err)
+		}
+		scatter.Radius = 1
+		scatter.Shape = draw.CircleGlyph{}
+		p.Add(scatter)
+		p.Y.Min = float64(sim.genesisBlock.d) / 2 // low enough for sense of scale of variance
+		p.Save(800, 300, filename)
+	}
+	if plots.has("difficulties") {
+		plotDifficulty()
+	}
+
+	plotTABS := func() {
+		filename := filepath.Join(outDir, "block_tabs.png")
+		p := plot.New()
+
+		data := plotter.XYs{}
+		for _, k := range miners[0].Blocks.Numbers() {
+			data = append(data, plotter.XY{X: float64(k), Y: float64(miners[0].Blocks.GetBlocksByNumber(k)[0].tabs)})
+		}
+		scatter, err := plotter.NewScatter(data)
+		if err != nil {
+			panic(err)
+		}
+		scatter.Radius = 1
+		scatter.Shape = draw.CircleGlyph{}
+		p.Add(scatter)
+		p.Y.Min = float64(sim.genesisBlock.tabs) / 2 // low enough for sense of scale of variance
+		p.Save(800, 300, filename)
+	}
+	if plots.has("tabs") {
+		plotTABS()
+	}
+
+	plotMinerTDs := func() {
+		filename := filepath.Join(outDir, "miner_tds.png")
+		p := plot.New()
+
+		for _, m := range miners {
+			data := plotter.XYs{}
+			for k, b := range m.canon {
+				data = append(data, plotter.XY{X: float64(k), Y: float64(b.td)})
+			}
+
+			scatter, err := plotter.NewScatter(data)
+			if err != nil {
+				panic(err)
+			}
+			scatter.Radius = 1
+			scatter.Shape = draw.CircleGlyph{}
+			scatter.Color, _ = ParseHexColor("#" + m.Address)
+			p.Add(scatter)
+			p.Legend.Add(m.Address, scatter)
+		}
+
+		// p.Y.Min = float64(sim.genesisBlock.td)
+		p.Save(800, 300, filename)
+	}
+	if plots.has("tds") {
+		plotMinerTDs()
+	}
+
+	plotMinerTDTABS := func() {
+		filename := filepath.Join(outDir, "miner_ttdtabs_ts.png")
+		p := plot.New()
+		p.Title.Text = "Miner TD*TABS Values Over Timestamp"
+
+		for _, m := range miners {
+			data := plotter.XYs{}
+			for _, b := range m.canon {
+				// data = append(data, plotter.XY{X: float64(k), Y: float64(b.ttdtabs)})
+				data = append(data, plotter.XY{X: float64(b.s), Y: float64(b.ttdtabs)})
+			}
+
+			scatter, err := plotter.NewScatter(data)
+			if err != nil {
+				panic(err)
+			}
+			scatter.Radius = 1
+			scatter.Shape = draw.CircleGlyph{}
+			scatter.Color, _ = ParseHexColor("#" + m.Address)
+			p.Add(scatter)
+			p.Legend.Add(m.Address, scatter)
+		}
+
+		// p.Y.Min = float64(sim.genesisBlock.td)
+		p.Save(800, 300, filename)
+	}
+	if plots.has("ttdtabs_ts") {
+		plotMinerTDTABS()
+	}
+
+	plotMinerTDTABSBlockN := func() {
+		filename := filepath.Join(outDir, "miner_ttdtabs_blockn.png")
+		p := plot.New()
+		p.Title.Text = "Miner TD*TABS Values Over Block Height"
+
+		for _, m := range miners {
+			data := plotter.XYs{}
+			for blockHeight, b := range m.canon {
+				data = append(data, plotter.XY{X: float64(blockHeight), Y: float64(b.ttdtabs)})
+				// data = append(data, plotter.XY{X: float64(b.s), Y: float64(b.ttdtabs)})
+			}
+
+			scatter, err := plotter.NewScatter(data)
+			if err != nil {
+				panic(err)
+			}
+			scatter.Radius = 1
+			scatter.Shape = draw.CircleGlyph{}
+			scatter.Color, _ = ParseHexColor("#" + m.Address)
+			p.Add(scatter)
+			p.Legend.Add(m.Address, scatter)
+		}
+
+		// p.Y.Min = float64(sim.genesisBlock.td)
+		p.Save(800, 300, filename)
+	}
+	if plots.has("ttdtabs_blockn") {
+		plotMinerTDTABSBlockN()
+	}
+
+	plotMinerReorgs := func() {
+
+		filename := filepath.Join(outDir, "miner_reorgs.png")
+		p := plot.New()
+
+		adds := plotter.XYs{}
+		drops := plotter.XYs{}
+		for i, m := range miners {
+			i += 1
+			centerMinerInterval := float64(i)
+			for k, v := range m.reorgs {
+				adds = append(adds, plotter.XY{X: float64(k), Y: float64(centerMinerInterval + float64(v.add)/20)})
+				drops = append(drops, plotter.XY{X: float64(k), Y: float64(centerMinerInterval - float64(v.drop)/20)})
+			}
+
+			addScatter, err := plotter.NewScatter(adds)
+			if err != nil {
+				panic(err)
+			}
+			addScatter.Radius = 1
+			addScatter.Shape = draw.CircleGlyph{}
+			addScatter.Color = color.RGBA{R: 1, G: 255, B: 1, A: 255}
+			p.Add(addScatter)
+
+			dropScatter, err := plotter.NewScatter(drops)
+			if err != nil {
+				panic(err)
+			}
+			dropScatter.Radius = 1
+			dropScatter.Shape = draw.CircleGlyph{}
+			dropScatter.Color = color.RGBA{R: 255, G: 1, B: 1, A: 255}
+			p.Add(dropScatter)
+		}
+
+		p.Y.Max = float64(len(miners) + 1)
+
+		// p.Y.Min = float64(sim.genesisBlock.td)
+		p.Save(800, vg.Length(float64(len(miners)+1)*20), filename)
+	}
+	if plots.has("reorgs") {
+		plotMinerReorgs()
+	}
+
+	plotMinerBalances := func() {
+		filename := filepath.Join(outDir, "miner_balances.png")
+		p := plot.New()
+		p.Title.Text = "Miner Balances Over Time"
+		p.X.Label.Text = "hours"
+
+		for _, m := range miners {
+			data := plotter.XYs{}
+			for _, b := range m.balances {
+				data = append(data, plotter.XY{X: float64(b.tick) / float64(sim.TicksPerSecond) / 3600, Y: float64(b.balance)})
+			}
+
+			line, err := plotter.NewLine(data)
+			if err != nil {
+				panic(err)
+			}
+			line.Color, _ = ParseHexColor("#" + m.Address)
+			p.Add(line)
+			p.Legend.Add(m.Address, line)
+		}
+
+		p.Save(800, 300, filename)
+	}
+	if plots.has("balances") && sim.Economics != nil {
+		plotMinerBalances()
+	}
+
+	// plotMinerReorgMagnitudes := func() {
+	// 	filename := filepath.Join("out", "miner_tds.png")
+	// 	p := plot.New()
+	//
+	// 	data := plotter.XYs{}
+	// 	for _, m := range miners {
+	// 		for k, v := range m.re {
+	// 			for _, b := range v {
+	// 				// Plot ALL blocks together.
+	// 				// Some numbers will be duplicated.
+	// 				data = append(data, plotter.XY{X: float64(k), Y: float64(b.d)})
+	// 			}
+	// 		}
+	//
+	// 		scatter, err := plotter.NewScatter(data)
+	// 		if err != nil {
+	// 			panic(err)
+	// 		}
+	// 		scatter.Radius = 1
+	// 		scatter.Shape = draw.CircleGlyph{}
+	// 		scatter.Color, _ = ParseHexColor("#" + m.Address)
+	// 		p.Add(scatter)
+	// 		p.Legend.Add(m.Address, scatter)
+	// 	}
+	//
+	// 	// p.Y.Min = float64(sim.genesisBlock.td)
+	// 	p.Save(800, 300, filename)
+	// }
+	// plotMinerReorgMagnitudes()
+
+	/*
+		https://superuser.com/questions/249101/how-can-i-combine-30-000-images-into-a-timelapse-movie
+
+		ffmpeg -f image2 -r 1/5 -i img%03d.png -c:v libx264 -pix_fmt yuv420p out.mp4
+		ffmpeg -f image2 -pattern_type glob -i 'time-lapse-files/*.JPG' …
+
+	*/
+	if !plots.has("anim") {
+		return nil
+	}
+	ffmpeg, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		// Leave the animation frames in place so a movie can be made from them later.
+		logf("Skipping movie: ffmpeg not found")
+		return nil
+	}
+
+	logf("Making movie...")
+	movieCmd := exec.Command(ffmpeg,
+		"-f", "image2",
+		"-r", "20/1", // 10 images / 1 second (Hz)
+		// "-vframes", fmt.Sprintf("%d", lastHighBlock),
+		"-pattern_type", "glob",
+		"-i", filepath.Join(outDir, "anim", "*.png"),
+		"-c:v", "libx264",
+		"-pix_fmt", "yuv420p",
+		filepath.Join(outDir, "anim", "out.mp4"),
+	)
+	if err := movieCmd.Run(); err != nil {
+		return err
+	}
+
+	/*
+		https://askubuntu.com/questions/648603/how-to-create-an-animated-gif-from-mp4-video-via-command-line
+
+		ffmpeg \
+		  -i opengl-rotating-triangle.mp4 \
+		  -r 15 \
+		  -vf scale=512:-1 \
+		  -ss 00:00:03 -to 00:00:06 \
+		  opengl-rotating-triangle.gif
+	*/
+	logf("Making gif...")
+	gifCmd := exec.Command(ffmpeg,
+		"-i", filepath.Join(outDir, "anim", "out.mp4"),
+		// "-r", "10", // Hz value
+		"-r", "20", // Hz value
+		"-vf", "scale=512:-1",
+		filepath.Join(outDir, "anim", "out.gif"),
+	)
+	if err := gifCmd.Run(); err != nil {
+		return err
+	}
+
+	animSlides, err := filepath.Glob(filepath.Join(outDir, "anim", "*.png"))
+	if err != nil {
+		return err
+	}
+	for _, f := range animSlides {
+		// 			imgBaseName := fmt.Sprintf("%04d_f.png", nextHighBlock)
+		base := filepath.Base(f)
+		numStr := base[:4]
+		if i, err := strconv.Atoi(numStr); err != nil && i%blockRowsN == 0 {
+			continue
+		} else if err != nil {
+			logf(err)
+		}
+		os.Remove(f)
+	}
+	return nil
+}
@@ -0,0 +1,612 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tabsadjust "github.com/whilei/go-tabs-adjust"
+	"gopkg.in/yaml.v3"
+)
+
+// Scenario is a declarative description of an experiment.
+// It can be written as YAML or JSON; see the scenarios/ directory for examples.
+type Scenario struct {
+	Name string `json:"name" yaml:"name"`
+
+	// Seed makes the run reproducible. Zero (or omitted) seeds from the clock.
+	Seed int64 `json:"seed,omitempty" yaml:"seed,omitempty"`
+
+	// ConsensusAlgorithm is the default for all miners, by fork choice name: TD, TDTABS, TDTABS_step, TimeDesc, TimeAsc, ...
+	ConsensusAlgorithm string `json:"consensusAlgorithm" yaml:"consensusAlgorithm"`
+
+	Globals ScenarioGlobals `json:"globals" yaml:"globals"`
+
+	// Population, if set, generates miners the way minersNormal does.
+	Population *ScenarioPopulation `json:"population,omitempty" yaml:"population,omitempty"`
+
+	// Miners are installed after the population, eg. an attacker.
+	Miners []ScenarioMiner `json:"miners,omitempty" yaml:"miners,omitempty"`
+
+	// Plots names the plots to produce (see allPlots). Empty means all of them.
+	Plots []string `json:"plots,omitempty" yaml:"plots,omitempty"`
+}
+
+// ScenarioGlobals are the simulation-wide values.
+// Zero values leave the program defaults in place, except for the pointers, which do so only when omitted.
+type ScenarioGlobals struct {
+	// Engine is the simulation engine: tick (default) or event.
+	Engine string `json:"engine,omitempty" yaml:"engine,omitempty"`
+
+	TicksPerSecond            int64    `json:"ticksPerSecond,omitempty" yaml:"ticksPerSecond,omitempty"`
+	TickSamples               int64    `json:"tickSamples,omitempty" yaml:"tickSamples,omitempty"`
+	MinerNeighborRate         *float64 `json:"minerNeighborRate,omitempty" yaml:"minerNeighborRate,omitempty"`
+	BlockReward               int64    `json:"blockReward,omitempty" yaml:"blockReward,omitempty"`
+	TabsAdjustmentDenominator int64    `json:"tabsAdjustmentDenominator,omitempty" yaml:"tabsAdjustmentDenominator,omitempty"`
+	LatencySeconds            *float64 `json:"latencySeconds,omitempty" yaml:"latencySeconds,omitempty"`
+
+	// LatencyDist is the latency distribution of every link, eg. "uniform:0.5:2" (see parseLatencyDist).
+	// It replaces latencySeconds. A relative empirical file is relative to the scenario file.
+	LatencyDist string `json:"latencyDist,omitempty" yaml:"latencyDist,omitempty"`
+
+	// BandwidthBytesPerSecond, if set, delays blocks by their size over the bandwidth at each hop.
+	BandwidthBytesPerSecond float64 `json:"bandwidthBytesPerSecond,omitempty" yaml:"bandwidthBytesPerSecond,omitempty"`
+
+	// Each hop, blocks are validated for validationSeconds plus their gas over validationGasPerSecond (if set).
+	ValidationSeconds      float64 `json:"validationSeconds,omitempty" yaml:"validationSeconds,omitempty"`
+	ValidationGasPerSecond float64 `json:"validationGasPerSecond,omitempty" yaml:"validationGasPerSecond,omitempty"`
+
+	// ReceivePostponeSeconds is how long every miner postpones processing a received block.
+	ReceivePostponeSeconds *float64 `json:"receivePostponeSeconds,omitempty" yaml:"receivePostponeSeconds,omitempty"`
+
+	// Topology is the neighbor graph. If omitted, pairs of miners are connected by coin flip.
+	Topology *ScenarioTopology `json:"topology,omitempty" yaml:"topology,omitempty"`
+
+	// Difficulty is the difficulty calculator: simple (default), byzantium, constantinople, london, etc, constant.
+	// difficultyStartNumber is the chain's block number at genesis, for the difficulty bombs.
+	Difficulty            string `json:"difficulty,omitempty" yaml:"difficulty,omitempty"`
+	DifficultyStartNumber int64  `json:"difficultyStartNumber,omitempty" yaml:"difficultyStartNumber,omitempty"`
+
+	// TabsAdjustment is the TABS adjustment algorithm of all miners, eg. "ema:64" (see tabsadjust.Parse),
+	// replacing the consensus algorithms'.
+	TabsAdjustment string `json:"tabsAdjustment,omitempty" yaml:"tabsAdjustment,omitempty"`
+
+	// Mempool, if set, models the transaction pool, in place of one normal draw of its TAB per height.
+	Mempool *ScenarioMempool `json:"mempool,omitempty" yaml:"mempool,omitempty"`
+
+	// Economics, if set, charges the miners' electricity and settles their balances by their sell policies.
+	Economics *ScenarioEconomics `json:"economics,omitempty" yaml:"economics,omitempty"`
+
+	// NetworkEvents partition the miners or eclipse one of them during the run.
+	NetworkEvents []ScenarioNetworkEvent `json:"networkEvents,omitempty" yaml:"networkEvents,omitempty"`
+}
+
+// ScenarioNetworkEvent describes a partition or an eclipse; see NetworkEvent.
+type ScenarioNetworkEvent struct {
+	Kind            string  `json:"kind" yaml:"kind"`
+	AtSeconds       float64 `json:"atSeconds" yaml:"atSeconds"`
+	DurationSeconds float64 `json:"durationSeconds,omitempty" yaml:"durationSeconds,omitempty"` // zero never heals
+
+	// Groups are a partition's groups of miner addresses; the miners left out make up one more.
+	// Without groups, the miners are split in two, alternating by index.
+	Groups [][]string `json:"groups,omitempty" yaml:"groups,omitempty"`
+
+	// Miner is the eclipsed miner's address, and Attackers the addresses of the miners it's left with.
+	Miner     string   `json:"miner,omitempty" yaml:"miner,omitempty"`
+	Attackers []string `json:"attackers,omitempty" yaml:"attackers,omitempty"`
+}
+
+func (se ScenarioNetworkEvent) config() NetworkEvent {
+	return NetworkEvent{
+		Kind:            se.Kind,
+		AtSeconds:       se.AtSeconds,
+		DurationSeconds: se.DurationSeconds,
+		Groups:          se.Groups,
+		Miner:           se.Miner,
+		Attackers:       se.Attackers,
+	}
+}
+
+// ScenarioEconomics describes the economics model; see EconomicsConfig. Zero values take the defaults.
+type ScenarioEconomics struct {
+	ElectricityCostRatio float64 `json:"electricityCostRatio,omitempty" yaml:"electricityCostRatio,omitempty"`
+	PeriodSeconds        float64 `json:"periodSeconds,omitempty" yaml:"periodSeconds,omitempty"`
+}
+
+// config returns the economics configuration, the defaults overridden by the non-zero values.
+func (se *ScenarioEconomics) config() EconomicsConfig {
+	c := DefaultEconomicsConfig()
+	if se.ElectricityCostRatio > 0 {
+		c.ElectricityCostRatio = se.ElectricityCostRatio
+	}
+	if se.PeriodSeconds > 0 {
+		c.PeriodSeconds = se.PeriodSeconds
+	}
+	return c
+}
+
+// ScenarioMempool describes the mempool model; see MempoolConfig.
+// Zero values take the defaults, which are fitted to Ethereum Classic, or the fit to the Fit directory.
+type ScenarioMempool struct {
+	// Fit is a directory of go-tabs-scraper block files to fit the model to.
+	// A relative path is relative to the scenario file.
+	Fit string `json:"fit,omitempty" yaml:"fit,omitempty"`
+
+	Accounts        int64   `json:"accounts,omitempty" yaml:"accounts,omitempty"`
+	BalanceMedian   float64 `json:"balanceMedian,omitempty" yaml:"balanceMedian,omitempty"`
+	BalanceSigma    float64 `json:"balanceSigma,omitempty" yaml:"balanceSigma,omitempty"`
+	TxsPerSecond    float64 `json:"txsPerSecond,omitempty" yaml:"txsPerSecond,omitempty"`
+	BlockGasLimit   int64   `json:"blockGasLimit,omitempty" yaml:"blockGasLimit,omitempty"`
+	BlockBytesLimit int64   `json:"blockBytesLimit,omitempty" yaml:"blockBytesLimit,omitempty"`
+	TTLSeconds      float64 `json:"ttlSeconds,omitempty" yaml:"ttlSeconds,omitempty"`
+}
+
+// config returns the mempool configuration, the defaults (or the fit) overridden by the non-zero values.
+func (sm *ScenarioMempool) config() (MempoolConfig, error) {
+	c := DefaultMempoolConfig()
+	if sm.Fit != "" {
+		var err error
+		if c, err = fitMempool(sm.Fit); err != nil {
+			return c, err
+		}
+	}
+	if sm.Accounts > 0 {
+		c.Accounts = sm.Accounts
+	}
+	if sm.BalanceMedian > 0 {
+		c.BalanceMedian = sm.BalanceMedian
+	}
+	if sm.BalanceSigma > 0 {
+		c.BalanceSigma = sm.BalanceSigma
+	}
+	if sm.TxsPerSecond > 0 {
+		c.TxsPerSecond = sm.TxsPerSecond
+	}
+	if sm.BlockGasLimit > 0 {
+		c.BlockGasLimit = sm.BlockGasLimit
+	}
+	if sm.BlockBytesLimit > 0 {
+		c.BlockBytesLimit = sm.BlockBytesLimit
+	}
+	if sm.TTLSeconds > 0 {
+		c.TTLSeconds = sm.TTLSeconds
+	}
+	return c, nil
+}
+
+// ScenarioTopology describes the neighbor graph; see TopologyConfig.
+type ScenarioTopology struct {
+	Kind   string  `json:"kind" yaml:"kind"`
+	Degree int     `json:"degree,omitempty" yaml:"degree,omitempty"`
+	Rewire float64 `json:"rewire,omitempty" yaml:"rewire,omitempty"`
+
+	// EdgesFile is the file topology's edge list. A relative path is relative to the scenario file.
+	EdgesFile string `json:"edgesFile,omitempty" yaml:"edgesFile,omitempty"`
+}
+
+// ScenarioPopulation describes a generated set of miners.
+// Hashrates follow the distribution and starting balances are derived (backwards) from them.
+type ScenarioPopulation struct {
+	Count              int64  `json:"count" yaml:"count"`
+	HashrateDist       string `json:"hashrateDist" yaml:"hashrateDist"`
+	BalanceCap         int64  `json:"balanceCap,omitempty" yaml:"balanceCap,omitempty"`
+	CostPerBlock       int64  `json:"costPerBlock,omitempty" yaml:"costPerBlock,omitempty"`
+	StrategySkipRandom bool   `json:"strategySkipRandom,omitempty" yaml:"strategySkipRandom,omitempty"`
+	TxPolicy           string `json:"txPolicy,omitempty" yaml:"txPolicy,omitempty"`               // fee (default), fifo, tab, mix, mix:<balance weight>
+	SellPolicy         string `json:"sellPolicy,omitempty" yaml:"sellPolicy,omitempty"`           // hold (default), cover, sell, sell:<fraction>, keep:<amount>
+	Strategy           string `json:"strategy,omitempty" yaml:"strategy,omitempty"`               // honest (default), sm1, stubborn, postpone, private, doublespend, ...
+	TimestampPolicy    string `json:"timestampPolicy,omitempty" yaml:"timestampPolicy,omitempty"` // honest (default), earliest, earliest:<seconds>, offset:<seconds>
+}
+
+// ScenarioMiner describes a single, explicitly configured miner.
+type ScenarioMiner struct {
+	// Address is a hex color (without '#'), which the plots use for the miner.
+	Address  string  `json:"address" yaml:"address"`
+	Hashrate float64 `json:"hashrate" yaml:"hashrate"`
+
+	// Balance is the starting balance. If omitted, it is derived from the hashrate.
+	Balance *int64 `json:"balance,omitempty" yaml:"balance,omitempty"`
+
+	BalanceCap         int64  `json:"balanceCap,omitempty" yaml:"balanceCap,omitempty"`
+	CostPerBlock       int64  `json:"costPerBlock,omitempty" yaml:"costPerBlock,omitempty"`
+	StrategySkipRandom bool   `json:"strategySkipRandom,omitempty" yaml:"strategySkipRandom,omitempty"`
+	ConsensusAlgorithm string `json:"consensusAlgorithm,omitempty" yaml:"consensusAlgorithm,omitempty"`
+	TxPolicy           string `json:"txPolicy,omitempty" yaml:"txPolicy,omitempty"`
+	SellPolicy         string `json:"sellPolicy,omitempty" yaml:"sellPolicy,omitempty"`
+	Strategy           string `json:"strategy,omitempty" yaml:"strategy,omitempty"`
+	TimestampPolicy    string `json:"timestampPolicy,omitempty" yaml:"timestampPolicy,omitempty"`
+
+	SendDelaySeconds    float64 `json:"sendDelaySeconds,omitempty" yaml:"sendDelaySeconds,omitempty"`
+	ReceiveDelaySeconds float64 `json:"receiveDelaySeconds,omitempty" yaml:"receiveDelaySeconds,omitempty"`
+}
+
+// attackMinerSpec is the rich, withholding attack miner of TestPlotting.
+var attackMinerSpec = ScenarioMiner{
+	Address:             "ff0000",
+	Hashrate:            0.9,
+	Balance:             func(i int64) *int64 { return &i }(genesisBlockTABS * 11 / 10), // rich enough to always win TABS
+	SendDelaySeconds:    (time.Hour * 8).Seconds(),
+	ReceiveDelaySeconds: (time.Hour * 8).Seconds(),
+}
+
+// defaultScenario is the scenario run when no file is given: the TestPlotting "td" case.
+func defaultScenario() *Scenario {
+	return &Scenario{
+		Name:               "td",
+		ConsensusAlgorithm: TD.String(),
+		Population: &ScenarioPopulation{
+			Count:        DefaultSimulationConfig().CountMiners,
+			HashrateDist: DefaultSimulationConfig().HashrateDist.String(),
+		},
+	}
+}
+
+// loadScenario reads a scenario from a .yaml, .yml or .json file.
+// Unknown fields are an error.
+func loadScenario(path string) (*Scenario, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	sc := &Scenario{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(sc)
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(sc)
+	default:
+		return nil, fmt.Errorf("unknown scenario file type: %s", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if sc.Name == "" {
+		sc.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if t := sc.Globals.Topology; t != nil && t.EdgesFile != "" && !filepath.IsAbs(t.EdgesFile) {
+		t.EdgesFile = filepath.Join(filepath.Dir(path), t.EdgesFile)
+	}
+	if sm := sc.Globals.Mempool; sm != nil && sm.Fit != "" && !filepath.IsAbs(sm.Fit) {
+		sm.Fit = filepath.Join(filepath.Dir(path), sm.Fit)
+	}
+	if f := strings.TrimPrefix(sc.Globals.LatencyDist, LatencyEmpirical+":"); f != sc.Globals.LatencyDist && !filepath.IsAbs(f) {
+		sc.Globals.LatencyDist = LatencyEmpirical + ":" + filepath.Join(filepath.Dir(path), f)
+	}
+	if err := sc.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return sc, nil
+}
+
+func (sc *Scenario) validate() error {
+	if _, err := parseConsensusAlgorithm(sc.ConsensusAlgorithm); err != nil {
+		return err
+	}
+	if sc.Population == nil && len(sc.Miners) == 0 {
+		return fmt.Errorf("scenario has no miners")
+	}
+	if sc.Population != nil {
+		if sc.Population.Count < 1 {
+			return fmt.Errorf("population must have at least one miner")
+		}
+		if _, err := parseHashrateDistType(sc.Population.HashrateDist); err != nil {
+			return err
+		}
+		if p := sc.Population.TxPolicy; p != "" {
+			if _, err := parseTxPolicy(p); err != nil {
+				return err
+			}
+		}
+		if p := sc.Population.SellPolicy; p != "" {
+			if _, err := parseSellPolicy(p); err != nil {
+				return err
+			}
+		}
+		if s := sc.Population.Strategy; s != "" {
+			if _, err := parseStrategy(s); err != nil {
+				return err
+			}
+		}
+		if p := sc.Population.TimestampPolicy; p != "" {
+			if _, err := parseTimestampPolicy(p); err != nil {
+				return err
+			}
+		}
+	}
+	addresses := map[string]bool{}
+	for i, m := range sc.Miners {
+		if _, err := ParseHexColor("#" + m.Address); err != nil || len(m.Address) != 6 {
+			return fmt.Errorf("miner %d: address must be a 6 digit hex color, got %q", i, m.Address)
+		}
+		if addresses[m.Address] {
+			return fmt.Errorf("miner %d: duplicate address %s", i, m.Address)
+		}
+		addresses[m.Address] = true
+		if m.Hashrate <= 0 {
+			return fmt.Errorf("miner %s: hashrate must be positive", m.Address)
+		}
+		if m.ConsensusAlgorithm != "" {
+			if _, err := parseConsensusAlgorithm(m.ConsensusAlgorithm); err != nil {
+				return fmt.Errorf("miner %s: %w", m.Address, err)
+			}
+		}
+		if m.TxPolicy != "" {
+			if _, err := parseTxPolicy(m.TxPolicy); err != nil {
+				return fmt.Errorf("miner %s: %w", m.Address, err)
+			}
+		}
+		if m.SellPolicy != "" {
+			if _, err := parseSellPolicy(m.SellPolicy); err != nil {
+				return fmt.Errorf("miner %s: %w", m.Address, err)
+			}
+		}
+		if m.Strategy != "" {
+			if _, err := parseStrategy(m.Strategy); err != nil {
+				return fmt.Errorf("miner %s: %w", m.Address, err)
+			}
+		}
+		if m.TimestampPolicy != "" {
+			if _, err := parseTimestampPolicy(m.TimestampPolicy); err != nil {
+				return fmt.Errorf("miner %s: %w", m.Address, err)
+			}
+		}
+	}
+	if err := validEngine(sc.Globals.Engine); err != nil {
+		return err
+	}
+	if sc.Globals.LatencyDist != "" {
+		if _, err := parseLatencyDist(sc.Globals.LatencyDist); err != nil {
+			return err
+		}
+	}
+	if sc.Globals.BandwidthBytesPerSecond < 0 {
+		return fmt.Errorf("bandwidth must not be negative")
+	}
+	if sc.Globals.ValidationSeconds < 0 || sc.Globals.ValidationGasPerSecond < 0 {
+		return fmt.Errorf("validation time must not be negative")
+	}
+	if sc.Globals.Topology != nil {
+		count := int64(len(sc.Miners))
+		if sc.Population != nil {
+			count += sc.Population.Count
+		}
+		if err := sc.config().Topology.validate(count); err != nil {
+			return err
+		}
+	}
+	if err := validDifficulty(sc.Globals.Difficulty); err != nil {
+		return err
+	}
+	if sc.Globals.DifficultyStartNumber < 0 {
+		return fmt.Errorf("difficulty start number must not be negative")
+	}
+	if sc.Globals.TabsAdjustmentDenominator < 0 {
+		return fmt.Errorf("tabs denominator must be positive")
+	}
+	if sm := sc.Globals.Mempool; sm != nil {
+		if sm.Accounts < 0 || sm.BalanceMedian < 0 || sm.BalanceSigma < 0 || sm.TxsPerSecond < 0 ||
+			sm.BlockGasLimit < 0 || sm.BlockBytesLimit < 0 || sm.TTLSeconds < 0 {
+			return fmt.Errorf("mempool values must not be negative")
+		}
+		if _, err := sm.config(); err != nil {
+			return fmt.Errorf("mempool: %w", err)
+		}
+	}
+	if se := sc.Globals.Economics; se != nil && (se.ElectricityCostRatio < 0 || se.PeriodSeconds < 0) {
+		return fmt.Errorf("economics values must not be negative")
+	}
+	if sc.Globals.TabsAdjustment != "" {
+		if _, err := tabsadjust.Parse(sc.Globals.TabsAdjustment); err != nil {
+			return err
+		}
+	}
+	if err := validNetworkEvents(sc.config().NetworkEvents); err != nil {
+		return err
+	}
+	for _, p := range sc.Plots {
+		known := false
+		for _, pp := range allPlots {
+			known = known || p == pp
+		}
+		if !known {
+			return fmt.Errorf("unknown plot: %q", p)
+		}
+	}
+	return nil
+}
+
+// config returns the simulation configuration for the scenario,
+// leaving defaults in place for zero-valued globals.
+func (sc *Scenario) config() SimulationConfig {
+	config := DefaultSimulationConfig()
+	config.Seed = sc.Seed
+
+	g := sc.Globals
+	if g.Engine != "" {
+		config.Engine = g.Engine
+	}
+	if g.TicksPerSecond > 0 {
+		config.TicksPerSecond = g.TicksPerSecond
+		config.TickSamples = g.TicksPerSecond * int64((time.Hour * 6).Seconds())
+	}
+	if g.TickSamples > 0 {
+		config.TickSamples = g.TickSamples
+	}
+	if g.MinerNeighborRate != nil {
+		config.MinerNeighborRate = *g.MinerNeighborRate
+	}
+	if g.BlockReward > 0 {
+		config.BlockReward = g.BlockReward
+	}
+	if g.TabsAdjustmentDenominator > 0 {
+		config.TabsAdjustmentDenominator = g.TabsAdjustmentDenominator
+	}
+	if g.LatencySeconds != nil {
+		config.LatencySeconds = *g.LatencySeconds
+	}
+	if g.ReceivePostponeSeconds != nil {
+		config.ReceivePostponeSeconds = *g.ReceivePostponeSeconds
+	}
+	if g.LatencyDist != "" {
+		config.LatencyDist = g.LatencyDist
+	}
+	if g.BandwidthBytesPerSecond > 0 {
+		config.BandwidthBytesPerSecond = g.BandwidthBytesPerSecond
+	}
+	if g.ValidationSeconds > 0 {
+		config.ValidationSeconds = g.ValidationSeconds
+	}
+	if g.ValidationGasPerSecond > 0 {
+		config.ValidationGasPerSecond = g.ValidationGasPerSecond
+	}
+	if g.Difficulty != "" {
+		config.Difficulty = g.Difficulty
+	}
+	if g.DifficultyStartNumber > 0 {
+		config.DifficultyStartNumber = g.DifficultyStartNumber
+	}
+	if g.TabsAdjustment != "" {
+		config.TabsAdjustment = g.TabsAdjustment
+	}
+	if g.Mempool != nil {
+		mc, _ := g.Mempool.config() // validated
+		config.Mempool = &mc
+	}
+	if g.Economics != nil {
+		ec := g.Economics.config()
+		config.Economics = &ec
+	}
+	for _, se := range g.NetworkEvents {
+		config.NetworkEvents = append(config.NetworkEvents, se.config())
+	}
+	if t := g.Topology; t != nil {
+		config.Topology = TopologyConfig{Kind: t.Kind, Degree: t.Degree, Rewire: t.Rewire, EdgesFile: t.EdgesFile}
+	}
+	if sc.Population != nil {
+		config.CountMiners = sc.Population.Count
+		config.HashrateDist, _ = parseHashrateDistType(sc.Population.HashrateDist)
+	} else {
+		config.CountMiners = int64(len(sc.Miners))
+	}
+	return config
+}
+
+// miners builds the scenario's miners: the population first, the way minersNormal does,
+// then the explicit miners.
+func (sc *Scenario) miners(sim *Simulation, minerEvents chan minerEvent) ([]*Miner, error) {
+	algo, err := parseConsensusAlgorithm(sc.ConsensusAlgorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	miners := []*Miner{}
+	supplyMiners := int64(len(sc.Miners))
+
+	if pop := sc.Population; pop != nil {
+		dist, err := parseHashrateDistType(pop.HashrateDist)
+		if err != nil {
+			return nil, err
+		}
+		supplyMiners = pop.Count
+
+		hashrates := generateMinerHashrates(dist, int(pop.Count))
+		names := minerNames(hashrates)
+		for i := int64(0); i < pop.Count; i++ {
+			balance := deriveMinerStartingBalance(sim.genesisBlock.tabs, pop.Count, hashrates[pop.Count-1-i]) // backwards
+			m := newMiner(sim, i, names[i], hashrates[i], balance, minerEvents)
+			m.ConsensusAlgorithm = algo
+			m.BalanceCap = pop.BalanceCap
+			m.CostPerBlock = pop.CostPerBlock
+			m.StrategySkipRandom = pop.StrategySkipRandom
+			if pop.TxPolicy != "" {
+				m.TxPolicy, _ = parseTxPolicy(pop.TxPolicy)
+			}
+			if pop.SellPolicy != "" {
+				m.SellPolicy, _ = parseSellPolicy(pop.SellPolicy)
+			}
+			if pop.Strategy != "" {
+				m.Strategy, _ = parseStrategy(pop.Strategy)
+			}
+			if pop.TimestampPolicy != "" {
+				m.TimestampPolicy, _ = parseTimestampPolicy(pop.TimestampPolicy)
+			}
+			m.processBlock(sim.genesisBlock) // sets head to genesis
+			miners = append(miners, m)
+		}
+	}
+
+	for _, spec := range sc.Miners {
+		for _, m := range miners {
+			if m.Address == spec.Address {
+				return nil, fmt.Errorf("miner address %s collides with a population miner", spec.Address)
+			}
+		}
+		m := spec.newMiner(sim, int64(len(miners)), supplyMiners, minerEvents)
+		m.ConsensusAlgorithm = algo
+		if spec.ConsensusAlgorithm != "" {
+			m.ConsensusAlgorithm, _ = parseConsensusAlgorithm(spec.ConsensusAlgorithm)
+		}
+		m.processBlock(sim.genesisBlock)
+		miners = append(miners, m)
+	}
+	return miners, nil
+}
+
+// newMiner builds the miner described by the spec.
+// If the spec has no balance, it is derived from a supply shared by supplyMiners miners.
+func (spec ScenarioMiner) newMiner(sim *Simulation, index, supplyMiners int64, minerEvents chan minerEvent) *Miner {
+	balance := deriveMinerStartingBalance(sim.genesisBlock.tabs, supplyMiners, spec.Hashrate)
+	if spec.Balance != nil {
+		balance = *spec.Balance
+	}
+
+	m := newMiner(sim, index, spec.Address, spec.Hashrate, balance, minerEvents)
+	m.BalanceCap = spec.BalanceCap
+	m.CostPerBlock = spec.CostPerBlock
+	m.StrategySkipRandom = spec.StrategySkipRandom
+	if spec.TxPolicy != "" {
+		m.TxPolicy, _ = parseTxPolicy(spec.TxPolicy)
+	}
+	if spec.SellPolicy != "" {
+		m.SellPolicy, _ = parseSellPolicy(spec.SellPolicy)
+	}
+	if spec.Strategy != "" {
+		m.Strategy, _ = parseStrategy(spec.Strategy)
+	}
+	if spec.TimestampPolicy != "" {
+		m.TimestampPolicy, _ = parseTimestampPolicy(spec.TimestampPolicy)
+	}
+
+	if spec.SendDelaySeconds > 0 {
+		m.SendDelay = func(block *Block) int64 {
+			return int64(spec.SendDelaySeconds * float64(sim.TicksPerSecond))
+		}
+	}
+	if spec.ReceiveDelaySeconds > 0 {
+		m.ReceiveDelay = func(block *Block) int64 {
+			return int64(spec.ReceiveDelaySeconds * float64(sim.TicksPerSecond))
+		}
+	}
+	return m
+}
+
+// run runs the scenario in a new simulation, writing into <outRoot>/<name>.
+func (sc *Scenario) run(outRoot string, logf func(args ...interface{})) error {
+	sim := NewSimulation(sc.config())
+
+	plots := plotSet{}
+	for _, p := range sc.Plots {
+		plots[p] = true
+	}
+	return runPlotting(sim, sc.Name, filepath.Join(outRoot, sc.Name), sc.miners, plots, logf)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadScenario_Examples(t *testing.T) {
+	matches, err := filepath.Glob(filepath.Join("scenarios", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) == 0 {
+		t.Fatal("no example scenarios")
+	}
+	for _, m := range matches {
+		sc, err := loadScenario(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sc.Name == "" {
+			t.Errorf("%s: empty name", m)
+		}
+	}
+}
+
+func TestLoadScenario_YAMLJSONEquivalent(t *testing.T) {
+	dir := t.TempDir()
+
+	y := `
+name: example
+consensusAlgorithm: TDTABS
+globals:
+  tabsAdjustmentDenominator: 64
+population:
+  count: 4
+  hashrateDist: equal
+  balanceCap: 500
+miners:
+  - address: ff0000
+    hashrate: 0.5
+    balance: 0
+    strategySkipRandom: true
+plots: [tabs, reorgs]
+`
+	j := `{
+  "name": "example",
+  "consensusAlgorithm": "TDTABS",
+  "globals": {"tabsAdjustmentDenominator": 64},
+  "population": {"count": 4, "hashrateDist": "equal", "balanceCap": 500},
+  "miners": [{"address": "ff0000", "hashrate": 0.5, "balance": 0, "strategySkipRandom": true}],
+  "plots": ["tabs", "reorgs"]
+}`
+	yPath, jPath := filepath.Join(dir, "example.yaml"), filepath.Join(dir, "example.json")
+	ioutil.WriteFile(yPath, []byte(y), os.ModePerm)
+	ioutil.WriteFile(jPath, []byte(j), os.ModePerm)
+
+	scY, err := loadScenario(yPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scJ, err := loadScenario(jPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(scY, scJ) {
+		t.Fatalf("yaml and json scenarios differ:\n%+v\n%+v", scY, scJ)
+	}
+	if scY.Miners[0].Balance == nil || *scY.Miners[0].Balance != 0 {
+		t.Fatal("explicit zero balance was lost")
+	}
+}
+
+func TestLoadScenario_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"unknown_field.yaml":  "consensusAlgorithm: TD\npopulation: {count: 2, hashrateDist: equal}\nbogus: 1\n",
+		"bad_consensus.yaml":  "consensusAlgorithm: PoS\npopulation: {count: 2, hashrateDist: equal}\n",
+		"bad_address.json":    `{"consensusAlgorithm": "TD", "miners": [{"address": "attacker", "hashrate": 1}]}`,
+		"no_miners.json":      `{"consensusAlgorithm": "TD"}`,
+		"bad_plot.yaml":       "consensusAlgorithm: TD\npopulation: {count: 2, hashrateDist: equal}\nplots: [pie]\n",
+		"bad_difficulty.yaml": "consensusAlgorithm: TD\npopulation: {count: 2, hashrateDist: equal}\nglobals: {difficulty: frontier}\n",
+		"bad_adjustment.yaml": "consensusAlgorithm: TD\npopulation: {count: 2, hashrateDist: equal}\nglobals: {tabsAdjustment: \"ema:0\"}\n",
+	} {
+		p := filepath.Join(dir, name)
+		ioutil.WriteFile(p, []byte(content), os.ModePerm)
+		if _, err := loadScenario(p); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+// TestScenario_MinersLikeMinersNormal checks that a population builds the same miners as minersNormal.
+func TestScenario_MinersLikeMinersNormal(t *testing.T) {
+	sc := defaultScenario()
+	sc.ConsensusAlgorithm = TDTABS.String()
+	sc.Miners = append(sc.Miners, attackMinerSpec)
+
+	sim := NewSimulation(sc.config())
+	got, err := sc.miners(sim, make(chan minerEvent))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := testPlottingMiners(func(m *Miner) {
+		m.ConsensusAlgorithm = TDTABS
+	}, true)(sim, make(chan minerEvent))
+
+	if len(got) != len(want) {
+		t.Fatalf("got %d miners, want %d", len(got), len(want))
+	}
+	for i := range want {
+		g, w := got[i], want[i]
+		if g.Index != w.Index || g.Address != w.Address || g.Hashrate != w.Hashrate ||
+			g.HashesPerTick != w.HashesPerTick || g.Balance != w.Balance ||
+			g.ConsensusAlgorithm != w.ConsensusAlgorithm || g.head != sim.genesisBlock {
+			t.Errorf("miner %d: got %+v, want %+v", i, g, w)
+		}
+		if g.SendDelay(sim.genesisBlock) != w.SendDelay(sim.genesisBlock) {
+			t.Errorf("miner %d: send delay differs", i)
+		}
+	}
+}
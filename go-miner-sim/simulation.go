@@ -0,0 +1,225 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	tabsadjust "github.com/whilei/go-tabs-adjust"
+	exprand "golang.org/x/exp/rand"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// SimulationConfig holds the values that parameterize a simulation.
+type SimulationConfig struct {
+	// Seed determines all of the simulation's randomness.
+	// Zero means seed from the clock; the seed used is then recorded in the simulation's config.
+	Seed int64
+
+	// Engine is the simulation engine: EngineTick (default) or EngineEvent.
+	Engine string
+
+	TicksPerSecond    int64
+	TickSamples       int64
+	CountMiners       int64
+	HashrateDist      HashrateDistType
+	MinerNeighborRate float64
+	BlockReward       int64
+
+	Topology TopologyConfig
+
+	LatencySeconds          float64
+	LatencyDist             string  // latency spec of every link (see parseLatencyDist); empty means LatencySeconds
+	BandwidthBytesPerSecond float64 // link bandwidth; blocks take size/bandwidth extra to propagate. Zero is unlimited.
+	DelaySeconds            float64 // miner hesitancy to broadcast solution
+	ReceivePostponeSeconds  float64
+
+	// Each hop, the receiving miner validates a block for ValidationSeconds plus its gas over ValidationGasPerSecond
+	// (if set) before importing and relaying it.
+	ValidationSeconds      float64
+	ValidationGasPerSecond float64
+
+	// Difficulty names the difficulty calculator (see DifficultySimple and friends); empty means simple.
+	// DifficultyStartNumber is the chain's block number at the simulation's genesis, which sets off the bombs.
+	Difficulty            string
+	DifficultyStartNumber int64
+
+	// TabsAdjustmentDenominator: 4096 is the 'equilibrium' value,
+	// lower values prefer richer miners more (devaluing hashrate).
+	TabsAdjustmentDenominator int64
+
+	// TabsAdjustment is a TABS adjustment algorithm spec (see tabsadjust.Parse), eg. "ema:64",
+	// which every miner uses in place of its fork choice's. Empty leaves the TABS to the fork choices.
+	TabsAdjustment string
+
+	// Mempool, if set, models the transaction pool (see MempoolConfig),
+	// in place of one normal draw of the pool's TAB per height.
+	Mempool *MempoolConfig
+
+	// Economics, if set, charges the miners' electricity and settles their balances (see EconomicsConfig).
+	Economics *EconomicsConfig
+
+	// NetworkEvents partition the miners or eclipse one of them during the run (see NetworkEvent).
+	NetworkEvents []NetworkEvent
+}
+
+// DefaultSimulationConfig returns the configuration used by TestPlotting.
+func DefaultSimulationConfig() SimulationConfig {
+	return SimulationConfig{
+		TicksPerSecond:    10,
+		TickSamples:       10 * int64((time.Hour * 6).Seconds()),
+		CountMiners:       12,
+		HashrateDist:      HashrateDistLongtail,
+		MinerNeighborRate: 0.5, // 0.7
+		BlockReward:       3,
+
+		LatencySeconds:         1,          // 1.23               // 2.5
+		DelaySeconds:           0,          // miner hesitancy to broadcast solution
+		ReceivePostponeSeconds: 100 / 1000, // 80 milliseconds, ish
+
+		TabsAdjustmentDenominator: 128, // 4096 <-- 4096 is the 'equilibrium' value
+	}
+}
+
+// Simulation owns the state shared by the miners of one run:
+// the configuration, the tx pool TAB table, the genesis block and the random streams.
+// Miners of different simulations share nothing, so simulations can run side by side.
+type Simulation struct {
+	SimulationConfig
+
+	networkLambda float64
+
+	// txPoolBlockTABs holds the TAB value of the tx pool at each height.
+	// Once set, all miners use it.
+	txPoolBlockTABs map[int64]int64
+
+	genesisBlock *Block
+
+	tabsAdjuster tabsadjust.TABSAdjuster // nil leaves the TABS to the fork choices
+	mempool      *mempool                // nil without the mempool model
+	settled      int64                   // the tick the miners last settled at, under the economics model
+	hashrate     float64                 // the miners' total
+	network      []*networkEvent         // the NetworkEvents of the run
+
+	// We'll use this for TAB score generation for each block.
+	// A normal distribution may not be the best fit. TODO.
+	normalDist distuv.Normal
+
+	rngs map[string]*rand.Rand
+
+	// now is the current tick. events is the event engine's queue; nil under the tick engine.
+	now    int64
+	events *eventQueue
+
+	// err stops the run: the first failure of a miner, which has no error to return.
+	err error
+}
+
+// NewSimulation creates a simulation with its own genesis block and random streams.
+// Two simulations with the same (non-zero) seed and miners produce the same results.
+func NewSimulation(config SimulationConfig) *Simulation {
+	if config.Seed == 0 {
+		config.Seed = time.Now().UnixNano()
+	}
+	s := &Simulation{
+		SimulationConfig: config,
+		networkLambda:    (float64(1) / float64(13)) / float64(config.TicksPerSecond),
+		txPoolBlockTABs:  make(map[int64]int64),
+		normalDist: distuv.Normal{
+			Mu:    float64(genesisBlockTABS),
+			Sigma: float64(genesisBlockTABS) / 4, // I just made this up. TODO.
+			Src:   exprand.NewSource(uint64(streamSeed(config.Seed, rngTxPool))),
+		},
+		rngs: make(map[string]*rand.Rand),
+	}
+	s.genesisBlock = &Block{
+		i:       0,
+		s:       0,
+		d:       genesisDifficulty,
+		td:      genesisDifficulty,
+		tab:     genesisBlockTABS,
+		tabs:    genesisBlockTABS,
+		ttdtabs: genesisBlockTABS * genesisDifficulty,
+		miner:   "00F00F",
+		h:       fmt.Sprintf("%08x", s.rng(rngBlockHashes).Int63()),
+		ph:      "00000000",
+	}
+	if config.Mempool != nil {
+		s.mempool = newMempool(s, *config.Mempool)
+	}
+	if config.TabsAdjustment != "" {
+		s.tabsAdjuster, _ = tabsadjust.Parse(config.TabsAdjustment) // validated by the scenario
+	}
+	return s
+}
+
+// run connects the miners and ticks them for TickSamples ticks, or, with the event engine,
+// runs them through the events of TickSamples ticks.
+// If afterTick is not nil, it is called after every tick (event); an error stops the run.
+func (s *Simulation) run(miners []*Miner, afterTick func(tick int64) error) error {
+	if err := s.checkDifficulty(); err != nil {
+		return err
+	}
+	if err := s.connect(miners); err != nil {
+		return err
+	}
+	for _, m := range miners {
+		s.hashrate += m.Hashrate
+	}
+	if err := s.setupNetwork(miners); err != nil {
+		return err
+	}
+	defer s.finishNetwork(miners)
+
+	if s.Engine == EngineEvent {
+		return s.runEvents(miners, afterTick)
+	}
+
+	for tick := int64(1); tick <= s.TickSamples; tick++ {
+
+		// for _, m := range miners {
+		// 	m.doTick(tick)
+		// }
+
+		// Randomize miner ticking.
+		// This shouldn't do much, but should help a little smoothing any influence that
+		// the arbitrary assignment ordering would have on block discovery outcomes.
+		s.changeNetwork(miners, tick)
+		s.setNow(miners, tick)
+		for _, i := range s.rng(rngTicks).Perm(len(miners)) {
+			miners[i].doTick(tick)
+		}
+		if s.err != nil {
+			return s.err
+		}
+
+		s.settle(miners, tick)
+		s.observeNetwork(miners)
+
+		if afterTick != nil {
+			if err := afterTick(tick); err != nil {
+				return err
+			}
+		}
+
+		// TODO: measure network graphs? eg. bifurcation tally?
+	}
+	return nil
+}
+
+// fail stops the run with the error, unless it has failed already.
+func (s *Simulation) fail(err error) {
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+// setNow moves the clock, the simulation's and every miner's, to the tick.
+// A block relayed without delay is processed by its receiver at once, which must check it against the time it is,
+// not against the time of its own last tick or event.
+func (s *Simulation) setNow(miners []*Miner, tick int64) {
+	s.now = tick
+	for _, m := range miners {
+		m.tick = tick
+	}
+}
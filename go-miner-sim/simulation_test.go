@@ -0,0 +1,72 @@
+package main
+
+import (
+	"sync"
+	"testing"
+)
+
+// TestSimulation_SideBySide runs two differently configured simulations concurrently
+// and checks that neither leaks state into the other.
+func TestSimulation_SideBySide(t *testing.T) {
+	denominators := []int64{64, 4096}
+	sims := make([]*Simulation, len(denominators))
+	miners := make([][]*Miner, len(denominators))
+
+	var wg sync.WaitGroup
+	for i, den := range denominators {
+		config := DefaultSimulationConfig()
+		config.TabsAdjustmentDenominator = den
+		config.CountMiners = 4
+		config.TickSamples = config.TicksPerSecond * 60 * 30
+		sims[i] = NewSimulation(config)
+		miners[i] = minersNormal(sims[i], nil, func(m *Miner) {
+			m.ConsensusAlgorithm = TDTABS
+		})
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := sims[i].run(miners[i], nil); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if sims[0].genesisBlock == sims[1].genesisBlock {
+		t.Fatal("simulations share a genesis block")
+	}
+	for i, sim := range sims {
+		if got, want := sim.getTABS(genesisBlockTABS, genesisBlockTABS*2), genesisBlockTABS*(denominators[i]+1)/denominators[i]; got != want {
+			t.Errorf("sim %d: getTABS got %d, want %d", i, got, want)
+		}
+		for _, m := range miners[i] {
+			if m.sim != sim {
+				t.Fatalf("sim %d: miner %s belongs to another simulation", i, m.Address)
+			}
+			if g := m.canon.GetBlockByNumber(0); g != sim.genesisBlock {
+				t.Fatalf("sim %d: miner %s has a foreign genesis", i, m.Address)
+			}
+			if m.head.i == 0 {
+				t.Errorf("sim %d: miner %s mined nothing", i, m.Address)
+			}
+			// The miner's canonical index must be the chain to its head.
+			for _, b := range m.Blocks.CanonicalChain(m.head) {
+				if !m.canon.Has(b) {
+					t.Fatalf("sim %d: miner %s: block %s on its chain is not canonical", i, m.Address, b)
+				}
+			}
+			// Every canonical block must build on this simulation's chain.
+			for b := m.head; b.i > 0; b = m.Blocks.GetParent(b) {
+				if b.i > 1 && m.Blocks.GetParent(b) == nil {
+					t.Fatalf("sim %d: block %s has no parent", i, b)
+				}
+			}
+		}
+		for n := range sim.txPoolBlockTABs {
+			if n > Miners(miners[i]).headMax()+1 {
+				t.Errorf("sim %d: tx pool TAB for unreachable height %d", i, n)
+			}
+		}
+	}
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Strategy is how a miner plays the game: which of its blocks it withholds and when it publishes them,
+// which head it mines on, how long it postpones others' blocks, and what TAB it brings to its blocks.
+// A miner's own blocks are withheld (see Miner.withheld) until its strategy publishes them.
+// Strategies are registered by name (see RegisterStrategy); they keep their state on the miner.
+type Strategy interface {
+	String() string
+
+	// Prefer arbitrates between the miner's head a and a block b before the miner's fork choice does;
+	// nil leaves it to the fork choice.
+	Prefer(m *Miner, a, b *Block) *Block
+
+	// Mined is called once the miner has imported a block it mined, and Received once it has imported another miner's.
+	// They return the withheld blocks to publish, in order.
+	Mined(m *Miner, b *Block) Blocks
+	Received(m *Miner, b *Block) Blocks
+
+	// Postpone is how many ticks the miner postpones importing another miner's block, on top of its ReceiveDelay.
+	Postpone(m *Miner, b *Block) int64
+
+	// TAB is the TAB the miner brings to a block of its own, for which the pool and its balance make tab.
+	TAB(m *Miner, tab int64) int64
+}
+
+var (
+	// Honest publishes its blocks as it mines them and follows its fork choice.
+	Honest Strategy = honest{}
+
+	// SM1 is Eyal and Sirer's selfish mining: it keeps a private branch, publishing just enough of it
+	// to match or override each public block.
+	SM1 Strategy = &selfish{name: "sm1"}
+
+	// Stubborn is lead-stubborn mining (Nayak et al.), which matches where SM1 would override.
+	// "stubborn:<variant>" combines L (lead), F (equal-fork) and Tj (trail by up to j blocks), eg. "stubborn:LFT1".
+	Stubborn Strategy = &selfish{name: "stubborn", lead: true}
+
+	// Postpone is the TABS postpone attack: it postpones others' blocks for a second when it could beat their TABS,
+	// giving itself time to mine a competitor. "postpone:<seconds>" postpones for that long.
+	Postpone Strategy = &postpone{name: "postpone", seconds: 1}
+
+	// Private mines a private chain, bringing ten times its balance to its blocks' TAB (as if borrowed for the attack).
+	// Like SM1 it keeps its lead private, and publishes once the public chain is within a block of it,
+	// but it does so whenever its chain beats the public chain by the miner's fork choice, however short it is;
+	// it gives up on a private chain a hundred blocks behind. "private:<balance multiple>:<give up>" sets both.
+	Private Strategy = &private{name: "private", multiple: 10, giveUp: 100}
+)
+
+func init() {
+	RegisterStrategy(Honest)
+	RegisterStrategy(SM1)
+	RegisterStrategy(Stubborn)
+	RegisterStrategy(Postpone)
+	RegisterStrategy(Private)
+	RegisterStrategy(DoubleSpend)
+	RegisterStrategy(Sybil)
+}
+
+var (
+	strategies    = map[string]Strategy{}
+	strategyNames []string // in registration order
+)
+
+// RegisterStrategy makes the strategy available by its name.
+// It panics if the name is taken.
+func RegisterStrategy(s Strategy) {
+	name := s.String()
+	if _, ok := strategies[name]; ok {
+		panic(fmt.Sprintf("strategy %q registered twice", name))
+	}
+	strategies[name] = s
+	strategyNames = append(strategyNames, name)
+}
+
+func parseStrategy(s string) (Strategy, error) {
+	if st, ok := strategies[s]; ok {
+		return st, nil
+	}
+	fields := strings.Split(s, ":")
+	bad := func() (Strategy, error) {
+		return nil, fmt.Errorf("strategy %q: bad parameters", s)
+	}
+	switch fields[0] {
+	case "stubborn":
+		if len(fields) != 2 || fields[1] == "" {
+			return bad()
+		}
+		st := &selfish{name: s}
+		v := fields[1]
+		for v != "" {
+			switch v[0] {
+			case 'L':
+				st.lead, v = true, v[1:]
+			case 'F':
+				st.equalFork, v = true, v[1:]
+			case 'T':
+				j := len(v[1:]) - len(strings.TrimLeft(v[1:], "0123456789"))
+				trail, err := strconv.ParseInt(v[1:1+j], 10, 64)
+				if err != nil || trail < 1 {
+					return bad()
+				}
+				st.trail, v = trail, v[1+j:]
+			default:
+				return bad()
+			}
+		}
+		return st, nil
+	case "postpone":
+		if len(fields) != 2 {
+			return bad()
+		}
+		seconds, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil || seconds < 0 {
+			return bad()
+		}
+		return &postpone{name: s, seconds: seconds}, nil
+	case "private":
+		st := &private{name: s, multiple: 10, giveUp: 100}
+		if len(fields) > 3 {
+			return bad()
+		}
+		var err error
+		if st.multiple, err = strconv.ParseInt(fields[1], 10, 64); err != nil || st.multiple < 1 {
+			return bad()
+		}
+		if len(fields) == 3 {
+			if st.giveUp, err = strconv.ParseInt(fields[2], 10, 64); err != nil || st.giveUp < 0 {
+				return bad()
+			}
+		}
+		return st, nil
+	case "doublespend":
+		return parseDoubleSpend(s, fields)
+	case "sybil":
+		return parseSybil(s, fields)
+	}
+	return nil, fmt.Errorf("unknown strategy: %q (want one of %s)", s, strings.Join(strategyNames, ", "))
+}
+
+func (m *Miner) strategy() Strategy {
+	if m.Strategy == nil {
+		return Honest
+	}
+	return m.Strategy
+}
+
+// publish releases the withheld blocks to the miner's neighbors, after its SendDelay.
+func (m *Miner) publish(blocks Blocks) {
+	blocks = append(Blocks{}, blocks...) // blocks may be m.withheld
+	for _, b := range blocks {
+		for i, w := range m.withheld {
+			if w == b {
+				m.withheld = append(m.withheld[:i], m.withheld[i+1:]...)
+				break
+			}
+		}
+		m.notePublic(b)
+		m.relay(b, 0, m.SendDelay(b))
+	}
+}
+
+// notePublic keeps the highest published block the miner knows of (the first seen of a height).
+func (m *Miner) notePublic(b *Block) {
+	if m.publicHead == nil || b.i > m.publicHead.i {
+		m.publicHead = b
+	}
+}
+
+func (m *Miner) publicHeight() int64 {
+	if m.publicHead == nil {
+		return 0
+	}
+	return m.publicHead.i
+}
+
+// privateTip is the miner's highest withheld block, or nil.
+func (m *Miner) privateTip() *Block {
+	if len(m.withheld) == 0 {
+		return nil
+	}
+	return m.withheld[len(m.withheld)-1]
+}
+
+// honest is the Strategy of miners without one. The other strategies embed it for their defaults.
+type honest struct{}
+
+func (honest) String() string                      { return "honest" }
+func (honest) Prefer(m *Miner, a, b *Block) *Block { return nil }
+func (honest) Mined(m *Miner, b *Block) Blocks     { return m.withheld }
+func (honest) Received(m *Miner, b *Block) Blocks  { return nil }
+func (honest) Postpone(m *Miner, b *Block) int64   { return 0 }
+func (honest) TAB(m *Miner, tab int64) int64       { return tab }
+
+// selfish is selfish mining: SM1 and its stubborn variants.
+// Its lead is the height of its private branch over the public chain's.
+type selfish struct {
+	honest
+	name      string
+	lead      bool  // L: on a lead of one, match instead of overriding
+	equalFork bool  // F: in a tie race, keep the block mined on its own branch private
+	trail     int64 // Tj: keep mining the private branch trailing by up to j blocks
+}
+
+func (s *selfish) String() string { return s.name }
+
+// Prefer keeps the miner on its private branch (or its side of a tie race) while it isn't behind by more than it trails.
+func (s *selfish) Prefer(m *Miner, a, b *Block) *Block {
+	if b.miner != m.Address && a.miner == m.Address && (len(m.withheld) > 0 || m.race > 0) && b.i <= a.i+s.trail {
+		return a
+	}
+	return nil
+}
+
+func (s *selfish) Mined(m *Miner, b *Block) Blocks {
+	switch {
+	case m.race > 0:
+		// Mined on its side of a tie: publishing it wins the race.
+		m.race = 0
+		if !s.equalFork {
+			return m.withheld
+		}
+	case b.i == m.publicHeight():
+		// Caught up from behind: race.
+		m.race = b.i
+		return m.withheld
+	}
+	return nil
+}
+
+func (s *selfish) Received(m *Miner, b *Block) (publish Blocks) {
+	if b.i > m.race {
+		m.race = 0
+	}
+	tip := m.privateTip()
+	if tip == nil {
+		return nil
+	}
+	lead := tip.i - m.publicHeight()
+	switch {
+	case lead < -s.trail:
+		m.withheld = nil // given up; the fork choice has taken the public chain
+		return nil
+	case lead < 0:
+		return nil // trailing
+	case lead == 0:
+		m.race = tip.i
+	}
+	for _, w := range m.withheld {
+		if w.i <= m.publicHeight() || lead == 1 && !s.lead {
+			publish = append(publish, w)
+		}
+	}
+	return publish
+}
+
+// postpone is the TABS postpone attack, ported from TestPlotting's tdtabs_64_postpone_attack.
+type postpone struct {
+	honest
+	name    string
+	seconds float64
+}
+
+func (p *postpone) String() string { return p.name }
+
+// Postpone holds back a block whose TABS didn't rise when the miner's own TAB at its height would beat it.
+func (p *postpone) Postpone(m *Miner, b *Block) int64 {
+	if b.tabsCmp > 0 || m.Balance+m.poolTAB(b.i) <= b.tabs {
+		return 0
+	}
+	return int64(p.seconds * float64(m.sim.TicksPerSecond))
+}
+
+// poolTAB is the TAB the miner expects from the pool for a block at the height:
+// the height's draw, or, under the mempool model, the senders of the transactions it would take now.
+func (m *Miner) poolTAB(i int64) int64 {
+	if mp := m.sim.mempool; mp != nil {
+		return mp.senderTAB(m.selectTxs())
+	}
+	return m.sim.txPoolBlockTABs[i]
+}
+
+// private is the balance-inflated private chain attack.
+type private struct {
+	honest
+	name     string
+	multiple int64 // of the miner's balance, brought to its blocks' TAB
+	giveUp   int64 // blocks behind the public chain
+}
+
+func (p *private) String() string { return p.name }
+
+func (p *private) Prefer(m *Miner, a, b *Block) *Block {
+	if b.miner != m.Address && a.miner == m.Address && len(m.withheld) > 0 && b.i <= a.i+p.giveUp {
+		return a
+	}
+	return nil
+}
+
+// Mined publishes a block mined behind the public chain if it beats it; a block that leads stays private.
+func (p *private) Mined(m *Miner, b *Block) Blocks {
+	if m.publicHeight() >= b.i && m.privateBeats() {
+		return m.withheld
+	}
+	return nil
+}
+
+// Received publishes the private chain once the public chain is within a block of it, if it beats it,
+// and gives it up once the public chain is too far ahead.
+func (p *private) Received(m *Miner, b *Block) Blocks {
+	tip := m.privateTip()
+	if tip == nil {
+		return nil
+	}
+	if m.publicHeight() > tip.i+p.giveUp {
+		m.withheld = nil
+		return nil
+	}
+	if m.publicHeight() >= tip.i-1 && m.privateBeats() {
+		return m.withheld
+	}
+	return nil
+}
+
+// privateBeats tells whether the miner's private chain would win over the public chain by its fork choice (or height).
+func (m *Miner) privateBeats() bool {
+	tip, public := m.privateTip(), m.publicHead
+	if public == nil {
+		return true
+	}
+	if fc := m.ConsensusAlgorithm; fc != nil {
+		return fc.Compare(m.Blocks, tip, public) > 0
+	}
+	return tip.i > public.i
+}
+
+func (p *private) TAB(m *Miner, tab int64) int64 {
+	return tab + (p.multiple-1)*m.Balance
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParseStrategy(t *testing.T) {
+	for s, want := range map[string]string{
+		"honest":          "honest",
+		"sm1":             "sm1",
+		"stubborn":        "stubborn",
+		"stubborn:LFT2":   "stubborn:LFT2",
+		"stubborn:T12":    "stubborn:T12",
+		"postpone:0.5":    "postpone:0.5",
+		"private:3":       "private:3",
+		"private:3:20":    "private:3:20",
+		"stubborn:":       "",
+		"stubborn:X":      "",
+		"stubborn:T":      "",
+		"stubborn:T0":     "",
+		"postpone:-1":     "",
+		"postpone:1:2":    "",
+		"private:0":       "",
+		"private:2:-1":    "",
+		"doublespend:3":   "doublespend:3",
+		"doublespend:3:9": "doublespend:3:9",
+		"doublespend:0":   "",
+		"doublespend":     "doublespend",
+		"sybil":           "sybil",
+		"sybil:2":         "sybil:2",
+		"sybil:4:0":       "sybil:4:0",
+		"sybil:0":         "",
+		"sybil:2:3":       "",
+		"sm2":             "",
+	} {
+		st, err := parseStrategy(s)
+		if want == "" {
+			if err == nil {
+				t.Errorf("%q: want error, got %v", s, st)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", s, err)
+			continue
+		}
+		if st.String() != want {
+			t.Errorf("%q: got %q", s, st)
+		}
+	}
+	st, _ := parseStrategy("stubborn:LFT2")
+	if s := st.(*selfish); !s.lead || !s.equalFork || s.trail != 2 {
+		t.Errorf("stubborn:LFT2: %+v", s)
+	}
+}
+
+// strategyMiner is a lone TD miner with the strategy, and a way to give it honest blocks.
+func strategyMiner(t *testing.T, strategy string) (m *Miner, honest func(parent *Block) *Block) {
+	sim := NewSimulation(DefaultSimulationConfig())
+	m = newMiner(sim, 0, "aaaaaa", 0.5, 0, nil)
+	m.ConsensusAlgorithm = TD
+	m.Strategy, _ = parseStrategy(strategy)
+	m.processBlock(sim.genesisBlock)
+
+	n := 0
+	honest = func(parent *Block) *Block {
+		n++
+		b := &Block{i: parent.i + 1, d: parent.d, td: parent.td + parent.d, miner: "bbbbbb", ph: parent.h, h: fmt.Sprintf("bbbb%04d", n),
+			tabs: parent.tabs, ttdtabs: parent.ttdtabs + parent.tabs*parent.d}
+		m.importBlock(b, 1)
+		return b
+	}
+	return m, honest
+}
+
+// mine has the miner mine a block on its head.
+func mine(m *Miner) *Block {
+	m.tick += 13 * m.sim.TicksPerSecond
+	m.mineBlock()
+	return m.head
+}
+
+func TestSelfish(t *testing.T) {
+	g := func(m *Miner) *Block { return m.sim.genesisBlock }
+	check := func(name string, m *Miner, head *Block, withheld int, race int64) {
+		t.Helper()
+		if m.head != head || len(m.withheld) != withheld || m.race != race {
+			t.Errorf("%s: head %s, %d withheld, race %d; want %s, %d, %d", name, m.head, len(m.withheld), m.race, head, withheld, race)
+		}
+	}
+
+	// SM1 overrides a block with a lead of two.
+	m, honest := strategyMiner(t, "sm1")
+	mine(m)
+	a2 := mine(m)
+	check("sm1 lead 2", m, a2, 2, 0)
+	honest(g(m))
+	check("sm1 override", m, a2, 0, 0)
+
+	// Lead-stubborn matches it instead, then races.
+	m, honest = strategyMiner(t, "stubborn")
+	mine(m)
+	a2 = mine(m)
+	h1 := honest(g(m))
+	check("stubborn match", m, a2, 1, 0)
+	honest(h1)
+	check("stubborn race", m, a2, 0, 2)
+	a3 := mine(m)
+	check("stubborn win", m, a3, 0, 0)
+
+	// Equal-fork stubborn keeps the block that would win the race.
+	m, honest = strategyMiner(t, "stubborn:F")
+	a1 := mine(m)
+	honest(g(m))
+	check("F race", m, a1, 0, 1)
+	a2 = mine(m)
+	check("F keep", m, a2, 1, 0)
+
+	// SM1 loses a race to a longer chain, and starts over on it.
+	m, honest = strategyMiner(t, "sm1")
+	mine(m)
+	h1 = honest(g(m))
+	h2 := honest(h1)
+	check("sm1 lose", m, h2, 0, 0)
+
+	// Trail-stubborn sticks to its side of the race one block behind, and races again when it catches up.
+	m, honest = strategyMiner(t, "stubborn:T1")
+	a1 = mine(m)
+	h1 = honest(g(m))
+	honest(h1)
+	check("T1 trail", m, a1, 0, 0)
+	a2 = mine(m)
+	check("T1 catch up", m, a2, 0, 2)
+}
+
+func TestPrivate(t *testing.T) {
+	// Under TD, a private block no heavier than the public one stays private, and is given up once the public chain is too far ahead.
+	m, honest := strategyMiner(t, "private:10:1")
+	a1 := mine(m)
+	h := honest(m.sim.genesisBlock)
+	if TD.Compare(m.Blocks, a1, h) > 0 {
+		t.Fatal("mined block heavier than the honest one")
+	}
+	h = honest(h)
+	if m.head != a1 || len(m.withheld) != 1 {
+		t.Errorf("TD: head %s, %d withheld", m.head, len(m.withheld))
+	}
+	h = honest(h)
+	if m.head != h || len(m.withheld) != 0 {
+		t.Errorf("TD given up: head %s, %d withheld", m.head, len(m.withheld))
+	}
+
+	// Under TDTABS, the inflated TAB beats the public block.
+	m, honest = strategyMiner(t, "private:10:1")
+	m.ConsensusAlgorithm = TDTABS
+	m.Balance = 1000
+	a1 = mine(m)
+	if a1.tab != 10*1000+m.sim.txPoolBlockTABs[1] || len(m.withheld) != 1 {
+		t.Errorf("TDTABS: tab %d, %d withheld", a1.tab, len(m.withheld))
+	}
+	honest(m.sim.genesisBlock)
+	if m.head != a1 || len(m.withheld) != 0 {
+		t.Errorf("TDTABS: head %s, %d withheld", m.head, len(m.withheld))
+	}
+}
+
+// TestStrategy_Honest checks that honest miners play as miners without a strategy.
+func TestStrategy_Honest(t *testing.T) {
+	heads := []string{}
+	for _, strategy := range []string{"", "honest"} {
+		sc := defaultScenario()
+		sc.Seed = 5
+		sc.Population.Count = 5
+		sc.Population.Strategy = strategy
+		sc.Globals.TickSamples = 10 * 60 * 60
+		miners, err := runReplicate(sc, sc.Seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		head := ""
+		for _, m := range miners {
+			head += m.head.h
+		}
+		heads = append(heads, head)
+	}
+	if heads[0] != heads[1] {
+		t.Error("honest strategy changed the run")
+	}
+}
@@ -0,0 +1,381 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/palette"
+	"gonum.org/v1/plot/plotter"
+)
+
+// sweepGrid holds the values to sweep for each parameter.
+// The sweep runs the Cartesian product of all of them.
+type sweepGrid struct {
+	Consensus                 []string
+	TabsAdjustmentDenominator []int64
+	LatencySeconds            []float64
+	CountMiners               []int64
+	MinerNeighborRate         []float64
+	ReceivePostponeSeconds    []float64
+}
+
+// sweepCell is one point of a sweepGrid.
+type sweepCell struct {
+	Consensus                 string
+	TabsAdjustmentDenominator int64
+	LatencySeconds            float64
+	CountMiners               int64
+	MinerNeighborRate         float64
+	ReceivePostponeSeconds    float64
+}
+
+func (g sweepGrid) cells() (cells []sweepCell) {
+	for _, c := range g.Consensus {
+		for _, den := range g.TabsAdjustmentDenominator {
+			for _, lat := range g.LatencySeconds {
+				for _, n := range g.CountMiners {
+					for _, nr := range g.MinerNeighborRate {
+						for _, pp := range g.ReceivePostponeSeconds {
+							cells = append(cells, sweepCell{
+								Consensus:                 c,
+								TabsAdjustmentDenominator: den,
+								LatencySeconds:            lat,
+								CountMiners:               n,
+								MinerNeighborRate:         nr,
+								ReceivePostponeSeconds:    pp,
+							})
+						}
+					}
+				}
+			}
+		}
+	}
+	return cells
+}
+
+func (c sweepCell) String() string {
+	return fmt.Sprintf("%s den=%d lat=%g miners=%d nr=%g postpone=%g",
+		c.Consensus, c.TabsAdjustmentDenominator, c.LatencySeconds, c.CountMiners, c.MinerNeighborRate, c.ReceivePostponeSeconds)
+}
+
+// scenario returns a copy of the base scenario with the cell's values installed.
+// The base scenario's population (or the default one) is resized to the cell's miner count.
+func (c sweepCell) scenario(base *Scenario) *Scenario {
+	sc := *base
+	sc.Name = fmt.Sprintf("%s_%s", base.Name, c)
+	sc.ConsensusAlgorithm = c.Consensus
+	sc.Globals.TabsAdjustmentDenominator = c.TabsAdjustmentDenominator
+	// Set as pointers, so that swept zeros are kept rather than replaced by the defaults.
+	sc.Globals.LatencySeconds = &c.LatencySeconds
+	sc.Globals.MinerNeighborRate = &c.MinerNeighborRate
+	sc.Globals.ReceivePostponeSeconds = &c.ReceivePostponeSeconds
+
+	pop := *defaultScenario().Population
+	if base.Population != nil {
+		pop = *base.Population
+	}
+	pop.Count = c.CountMiners
+	sc.Population = &pop
+	return &sc
+}
+
+// sweepMetrics are the network-wide results of a replicate, in output order.
+// Per-miner values are averaged over the miners.
+var sweepMetrics = []struct {
+	name  string
+	value func(miners []*Miner) float64
+}{
+	{"forkRate", func(miners []*Miner) float64 {
+		return meanOverMiners(miners, func(m *Miner) float64 { return m.Blocks.ForkRate() })
+	}},
+	{"kMean", func(miners []*Miner) float64 {
+		return meanOverMiners(miners, func(m *Miner) float64 { return m.results().KMean })
+	}},
+	{"intervalsMeanSeconds", func(miners []*Miner) float64 {
+		return meanOverMiners(miners, func(m *Miner) float64 { return m.results().IntervalsMeanSeconds })
+	}},
+	{"reorgMagnitudesMean", func(miners []*Miner) float64 {
+		return meanOverMiners(miners, func(m *Miner) float64 { return m.results().ReorgMagnitudesMean })
+	}},
+	{"decisiveArbitrationRate", func(miners []*Miner) float64 {
+		return meanOverMiners(miners, func(m *Miner) float64 { return m.results().DecisiveArbitrationRate })
+	}},
+	{"uncleRate", func(miners []*Miner) float64 {
+		return meanOverMiners(miners, func(m *Miner) float64 { return m.results().UncleRate })
+	}},
+	{"hopsMean", func(miners []*Miner) float64 {
+		return meanOverMiners(miners, func(m *Miner) float64 { return m.results().HopsMean })
+	}},
+	{"propagationSecondsMean", func(miners []*Miner) float64 {
+		return meanOverMiners(miners, func(m *Miner) float64 { return m.results().PropagationSecondsMean })
+	}},
+	{"headI", func(miners []*Miner) float64 {
+		return float64(Miners(miners).headMax())
+	}},
+	// The outcome of the first network event (see NetworkEvent), if any; the winner's mean is group 1's win rate.
+	{"networkReorgDepth", func(miners []*Miner) float64 {
+		return networkOutcomeOf(miners, func(o networkOutcome) float64 { return float64(o.ReorgDepth) })
+	}},
+	{"networkConvergenceSeconds", func(miners []*Miner) float64 {
+		return networkOutcomeOf(miners, func(o networkOutcome) float64 { return o.ConvergenceSeconds })
+	}},
+	{"networkWinner", func(miners []*Miner) float64 {
+		return networkOutcomeOf(miners, func(o networkOutcome) float64 {
+			if o.Winner < 0 {
+				return math.NaN()
+			}
+			return float64(o.Winner)
+		})
+	}},
+}
+
+// networkOutcomeOf is v of the outcome of the run's first network event, or NaN without one.
+func networkOutcomeOf(miners []*Miner, v func(o networkOutcome) float64) float64 {
+	if len(miners) == 0 || len(miners[0].sim.network) == 0 {
+		return math.NaN()
+	}
+	return v(miners[0].sim.network[0].networkOutcome)
+}
+
+// meanOverMiners averages v over the miners, ignoring undefined (NaN) values.
+// It is NaN if no miner has a value.
+func meanOverMiners(miners []*Miner, v func(m *Miner) float64) float64 {
+	sum, n := 0.0, 0
+	for _, m := range miners {
+		if x := v(m); !math.IsNaN(x) {
+			sum += x
+			n++
+		}
+	}
+	if n == 0 {
+		return math.NaN()
+	}
+	return sum / float64(n)
+}
+
+// sweepRow is the result of one replicate of one cell.
+type sweepRow struct {
+	sweepCell
+	Replicate int
+	Seed      int64
+	Metrics   map[string]float64
+}
+
+// runSweep runs n replicates of every cell of the grid on up to workers goroutines.
+// Replicate i of every cell is seeded with base.Seed+i, so cells are compared on common random numbers.
+// Rows are ordered by cell, then replicate.
+func runSweep(base *Scenario, grid sweepGrid, n, workers int, logf func(args ...interface{})) ([]sweepRow, error) {
+	cells := grid.cells()
+	rows := make([]sweepRow, len(cells)*n)
+	err := parallel(len(rows), workers, func(j int) error {
+		cell, i := cells[j/n], j%n
+		sc := cell.scenario(base)
+		if err := sc.validate(); err != nil {
+			return fmt.Errorf("%s: %w", cell, err)
+		}
+		seed := base.Seed + int64(i)
+		miners, err := runReplicate(sc, seed)
+		if err != nil {
+			return fmt.Errorf("%s replicate %d: %w", cell, i, err)
+		}
+		row := sweepRow{sweepCell: cell, Replicate: i, Seed: seed, Metrics: map[string]float64{}}
+		for _, metric := range sweepMetrics {
+			row.Metrics[metric.name] = metric.value(miners)
+		}
+		rows[j] = row
+		if logf != nil {
+			logf("Done", cell, "replicate", i)
+		}
+		return nil
+	})
+	return rows, err
+}
+
+var sweepCellHeader = []string{"consensus", "tabs_denominator", "latency_seconds", "miners", "neighbor_rate", "receive_postpone_seconds"}
+
+func (c sweepCell) record() []string {
+	return []string{
+		c.Consensus,
+		strconv.FormatInt(c.TabsAdjustmentDenominator, 10),
+		formatFloat(c.LatencySeconds),
+		strconv.FormatInt(c.CountMiners, 10),
+		formatFloat(c.MinerNeighborRate),
+		formatFloat(c.ReceivePostponeSeconds),
+	}
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func writeCSV(filename string, records [][]string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	w.WriteAll(records)
+	return w.Error()
+}
+
+// writeSweepCSV writes the tidy results table: one row per cell, replicate and metric.
+func writeSweepCSV(filename string, rows []sweepRow) error {
+	records := [][]string{append(append([]string{}, sweepCellHeader...), "replicate", "seed", "metric", "value")}
+	for _, row := range rows {
+		for _, metric := range sweepMetrics {
+			records = append(records, append(row.sweepCell.record(),
+				strconv.Itoa(row.Replicate), strconv.FormatInt(row.Seed, 10),
+				metric.name, formatFloat(row.Metrics[metric.name])))
+		}
+	}
+	return writeCSV(filename, records)
+}
+
+// writeSweepSummaryCSV writes the replicates' mean, standard deviation and 95% confidence interval
+// per cell and metric.
+func writeSweepSummaryCSV(filename string, rows []sweepRow) error {
+	records := [][]string{append(append([]string{}, sweepCellHeader...), "metric", "n", "mean", "sd", "ci95_low", "ci95_high")}
+	for start := 0; start < len(rows); {
+		end := start
+		for end < len(rows) && rows[end].sweepCell == rows[start].sweepCell {
+			end++
+		}
+		for _, metric := range sweepMetrics {
+			xs := []float64{}
+			for _, row := range rows[start:end] {
+				xs = append(xs, row.Metrics[metric.name])
+			}
+			s := summarizeMetric(xs)
+			records = append(records, append(rows[start].sweepCell.record(),
+				metric.name, strconv.Itoa(s.N), formatFloat(s.Mean), formatFloat(s.SD), formatFloat(s.CI95Low), formatFloat(s.CI95High)))
+		}
+		start = end
+	}
+	return writeCSV(filename, records)
+}
+
+// sweepHeatGrid is a plotter.GridXYZ of a metric over the denominator (columns) and latency (rows) indexes.
+type sweepHeatGrid struct {
+	z [][]float64 // [row][column]
+}
+
+func (g sweepHeatGrid) Dims() (c, r int)   { return len(g.z[0]), len(g.z) }
+func (g sweepHeatGrid) Z(c, r int) float64 { return g.z[r][c] }
+func (g sweepHeatGrid) X(c int) float64    { return float64(c) }
+func (g sweepHeatGrid) Y(r int) float64    { return float64(r) }
+
+// plotSweepHeatmaps draws, for each consensus algorithm and metric, a heatmap of the metric's mean
+// against TABS denominator and latency. Other swept parameters are averaged over.
+func plotSweepHeatmaps(outDir string, grid sweepGrid, rows []sweepRow) error {
+	for _, consensus := range grid.Consensus {
+		for _, metric := range sweepMetrics {
+			g := sweepHeatGrid{z: make([][]float64, len(grid.LatencySeconds))}
+			labels := plotter.XYLabels{}
+			for r, lat := range grid.LatencySeconds {
+				g.z[r] = make([]float64, len(grid.TabsAdjustmentDenominator))
+				for c, den := range grid.TabsAdjustmentDenominator {
+					xs := []float64{}
+					for _, row := range rows {
+						if row.Consensus == consensus && row.TabsAdjustmentDenominator == den && row.LatencySeconds == lat {
+							xs = append(xs, row.Metrics[metric.name])
+						}
+					}
+					mean := summarizeMetric(xs).Mean
+					g.z[r][c] = mean
+					labels.XYs = append(labels.XYs, plotter.XY{X: float64(c), Y: float64(r)})
+					labels.Labels = append(labels.Labels, fmt.Sprintf("%0.3f", mean))
+				}
+			}
+
+			p := plot.New()
+			p.Title.Text = fmt.Sprintf("%s: %s", consensus, metric.name)
+			p.X.Label.Text = "TABS denominator"
+			p.Y.Label.Text = "latency (seconds)"
+			xTicks, yTicks := plot.ConstantTicks{}, plot.ConstantTicks{}
+			for c, den := range grid.TabsAdjustmentDenominator {
+				xTicks = append(xTicks, plot.Tick{Value: float64(c), Label: strconv.FormatInt(den, 10)})
+			}
+			for r, lat := range grid.LatencySeconds {
+				yTicks = append(yTicks, plot.Tick{Value: float64(r), Label: formatFloat(lat)})
+			}
+			p.X.Tick.Marker, p.Y.Tick.Marker = xTicks, yTicks
+
+			p.Add(plotter.NewHeatMap(g, palette.Heat(32, 1)))
+			l, err := plotter.NewLabels(labels)
+			if err != nil {
+				return err
+			}
+			p.Add(l)
+
+			filename := filepath.Join(outDir, fmt.Sprintf("heatmap_%s_%s.png", metric.name, strings.ToLower(consensus)))
+			if err := p.Save(600, 450, filename); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// parseGrid parses a comma-separated list of values or start:stop:step ranges (stop inclusive),
+// eg. "64,128,4096" or "0.5:2:0.5".
+func parseGrid(s string) (values []float64, err error) {
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if !strings.Contains(part, ":") {
+			v, err := strconv.ParseFloat(part, 64)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+			continue
+		}
+		bounds := strings.Split(part, ":")
+		if len(bounds) != 3 {
+			return nil, fmt.Errorf("bad range %q, want start:stop:step", part)
+		}
+		var r [3]float64
+		for i, b := range bounds {
+			if r[i], err = strconv.ParseFloat(b, 64); err != nil {
+				return nil, err
+			}
+		}
+		if r[2] <= 0 {
+			return nil, fmt.Errorf("bad range %q: step must be positive", part)
+		}
+		// Count steps rather than accumulate, so float ranges hit their stop value.
+		for i := 0; ; i++ {
+			v := r[0] + float64(i)*r[2]
+			if v > r[1]+r[2]*1e-9 {
+				break
+			}
+			values = append(values, v)
+		}
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("empty grid")
+	}
+	return values, nil
+}
+
+func parseIntGrid(s string) ([]int64, error) {
+	fs, err := parseGrid(s)
+	if err != nil {
+		return nil, err
+	}
+	is := make([]int64, len(fs))
+	for i, f := range fs {
+		if f != math.Trunc(f) {
+			return nil, fmt.Errorf("%g is not an integer", f)
+		}
+		is[i] = int64(f)
+	}
+	return is, nil
+}
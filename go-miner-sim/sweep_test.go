@@ -0,0 +1,101 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseGrid(t *testing.T) {
+	cases := []struct {
+		in   string
+		want []float64
+	}{
+		{"1", []float64{1}},
+		{"64, 128,4096", []float64{64, 128, 4096}},
+		{"0.5:2:0.5", []float64{0.5, 1, 1.5, 2}},
+		{"0.1:0.3:0.1,1", []float64{0.1, 0.2, 0.30000000000000004, 1}},
+	}
+	for _, c := range cases {
+		got, err := parseGrid(c.in)
+		if err != nil {
+			t.Fatalf("%q: %v", c.in, err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%q: got %v, want %v", c.in, got, c.want)
+		}
+	}
+	for _, in := range []string{"", "a", "1:2", "1:2:0"} {
+		if _, err := parseGrid(in); err == nil {
+			t.Errorf("%q: expected error", in)
+		}
+	}
+	if _, err := parseIntGrid("1.5"); err == nil {
+		t.Error("expected error for non-integer")
+	}
+}
+
+func TestSweepCell_Zeros(t *testing.T) {
+	base := defaultScenario()
+	lat, nr, pp := 2.0, 0.5, 1.0
+	base.Globals.LatencySeconds, base.Globals.MinerNeighborRate, base.Globals.ReceivePostponeSeconds = &lat, &nr, &pp
+	c := sweepCell{Consensus: "TD", TabsAdjustmentDenominator: 4096, CountMiners: 4}
+	config := c.scenario(base).config()
+	if config.LatencySeconds != 0 || config.MinerNeighborRate != 0 || config.ReceivePostponeSeconds != 0 {
+		t.Errorf("swept zeros replaced: latency %v, neighbor rate %v, postpone %v",
+			config.LatencySeconds, config.MinerNeighborRate, config.ReceivePostponeSeconds)
+	}
+	if lat != 2 || nr != 0.5 || pp != 1 {
+		t.Error("base scenario changed")
+	}
+}
+
+func TestRunSweep(t *testing.T) {
+	base := defaultScenario()
+	base.Name = "sweep"
+	base.Seed = 3
+	base.Globals.TickSamples = 10 * 60 * 30
+
+	grid := sweepGrid{
+		Consensus:                 []string{"TD", "TDTABS"},
+		TabsAdjustmentDenominator: []int64{64, 4096},
+		LatencySeconds:            []float64{0.5, 2},
+		CountMiners:               []int64{4},
+		MinerNeighborRate:         []float64{0.5},
+		ReceivePostponeSeconds:    []float64{0},
+	}
+	if n := len(grid.cells()); n != 8 {
+		t.Fatalf("got %d cells, want 8", n)
+	}
+
+	rows, err := runSweep(base, grid, 2, 2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 16 {
+		t.Fatalf("got %d rows, want 16", len(rows))
+	}
+	for i, row := range rows {
+		if row.Replicate != i%2 || row.Seed != base.Seed+int64(i%2) {
+			t.Fatalf("row %d: replicate %d seed %d", i, row.Replicate, row.Seed)
+		}
+		if fr := row.Metrics["forkRate"]; fr < 0 || fr > 1 {
+			t.Errorf("row %d: bad fork rate %v", i, fr)
+		}
+	}
+
+	dir := t.TempDir()
+	if err := writeSweepCSV(filepath.Join(dir, "sweep.csv"), rows); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeSweepSummaryCSV(filepath.Join(dir, "sweep_summary.csv"), rows); err != nil {
+		t.Fatal(err)
+	}
+	if err := plotSweepHeatmaps(dir, grid, rows); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "heatmap_forkRate_tdtabs.png")); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Sybil spreads the miner's capital (its Balance) evenly over four addresses it controls, one of them its blocks' coinbase,
+// and has each of them send a self-transfer in each of its blocks. A block's TAB is the balances of its distinct senders
+// plus its miner's, so the capital counts once as senders' and the coinbase's share counts again as the miner's.
+// "sybil:<addresses>[:<self-transfers>]" sets how many addresses and how many of them send (all, by default);
+// spreading the capital without sending, eg. "sybil:4:0", loses TAB.
+var Sybil Strategy = &sybil{name: "sybil", addresses: 4, transfers: 4}
+
+// sybil is the balance-splitting, self-transferring TAB gaming strategy.
+// The self-transfers take their place in the miner's blocks (see selfTransfers), displacing the pool's transactions.
+type sybil struct {
+	honest
+	name      string
+	addresses int64
+	transfers int64
+}
+
+func (s *sybil) String() string { return s.name }
+
+// TAB swaps the miner's balance in tab for the coinbase's share and the senders' shares.
+func (s *sybil) TAB(m *Miner, tab int64) int64 {
+	return tab - m.Balance + m.Balance/s.addresses*(1+s.transfers)
+}
+
+func parseSybil(s string, fields []string) (Strategy, error) {
+	st := &sybil{name: s}
+	if len(fields) < 2 || len(fields) > 3 {
+		return nil, fmt.Errorf("strategy %q: want sybil:<addresses>[:<self-transfers>]", s)
+	}
+	var err error
+	if st.addresses, err = strconv.ParseInt(fields[1], 10, 64); err != nil || st.addresses < 1 {
+		return nil, fmt.Errorf("strategy %q: want at least one address", s)
+	}
+	st.transfers = st.addresses
+	if len(fields) == 3 {
+		if st.transfers, err = strconv.ParseInt(fields[2], 10, 64); err != nil || st.transfers < 0 || st.transfers > st.addresses {
+			return nil, fmt.Errorf("strategy %q: want 0 to %d self-transfers", s, st.addresses)
+		}
+	}
+	return st, nil
+}
+
+// selfTransfers is how many transactions of its own the miner puts in each of its blocks.
+func (m *Miner) selfTransfers() int64 {
+	if s, ok := m.strategy().(*sybil); ok {
+		return s.transfers
+	}
+	return 0
+}
+
+// honestTwin is the index of the first honest miner with the miner's fork choice, hashrate and starting capital, or -1.
+func honestTwin(miners []*Miner, m *Miner) int {
+	for i, t := range miners {
+		if t != m && t.strategy() == Honest && t.ConsensusAlgorithm == m.ConsensusAlgorithm &&
+			t.Hashrate == m.Hashrate && t.capital == m.capital {
+			return i
+		}
+	}
+	return -1
+}
+
+// resultsOf summarizes the miners' views of the finished simulation,
+// comparing the revenue of each miner playing a strategy with its honest twin's, if it has one.
+func resultsOf(miners []*Miner) []minerResults {
+	rs := make([]minerResults, len(miners))
+	for i, m := range miners {
+		rs[i] = m.results()
+	}
+	for i, m := range miners {
+		if m.strategy() == Honest {
+			continue
+		}
+		if j := honestTwin(miners, m); j >= 0 {
+			rs[i].Twin = miners[j].Address
+			rs[i].TwinAdvantage = rs[i].RelativeRevenue - rs[j].RelativeRevenue
+		}
+	}
+	return rs
+}
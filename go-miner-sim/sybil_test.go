@@ -0,0 +1,74 @@
+package main
+
+import "testing"
+
+func TestSybil_TAB(t *testing.T) {
+	for _, c := range []struct {
+		strategy string
+		brought  int64 // of a balance of 4000
+		txs      int64
+	}{
+		{"honest", 4000, 0},
+		{"sybil", 5000, 4},
+		{"sybil:1", 8000, 1},
+		{"sybil:4:0", 1000, 0},
+		{"sybil:4:2", 3000, 2},
+	} {
+		m, _ := strategyMiner(t, c.strategy)
+		m.Balance = 4000
+		b := mine(m)
+		pool := m.sim.txPoolBlockTABs[b.i]
+		if b.tab != pool+c.brought {
+			t.Errorf("%s: TAB %d, want %d", c.strategy, b.tab-pool, c.brought)
+		}
+		poolTxs := pool * presumeMinerShareBalancePerBlockDenominator / genesisBlockTABS
+		if poolTxs < 0 {
+			poolTxs = 0
+		}
+		if b.txs != poolTxs+c.txs {
+			t.Errorf("%s: %d txs, want %d", c.strategy, b.txs, poolTxs+c.txs)
+		}
+		if r := m.results(); r.TABRel != float64(b.tab)/float64(pool+4000) {
+			t.Errorf("%s: TAB rel %v", c.strategy, r.TABRel)
+		}
+	}
+}
+
+func TestResultsOf_Twin(t *testing.T) {
+	sc := defaultScenario()
+	sc.Seed = 1
+	sc.ConsensusAlgorithm = TDTABS.String()
+	sc.Population.Count = 4
+	sc.Globals.TickSamples = 10 * 60 * 60
+	sc.Miners = []ScenarioMiner{
+		{Address: "5b1100", Hashrate: 0.1, Strategy: "sybil:1"},
+		{Address: "7a1700", Hashrate: 0.1},
+		{Address: "5e1f00", Hashrate: 0.2, Strategy: "sm1"},
+	}
+	if err := sc.validate(); err != nil {
+		t.Fatal(err)
+	}
+	sim := NewSimulation(sc.config())
+	miners, err := sc.miners(sim, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sim.run(miners, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	rs := resultsOf(miners)
+	sybil, twin, sm1 := rs[4], rs[5], rs[6]
+	if sybil.Twin != "7a1700" || sybil.TwinAdvantage != sybil.RelativeRevenue-twin.RelativeRevenue {
+		t.Errorf("sybil: twin %q, advantage %v", sybil.Twin, sybil.TwinAdvantage)
+	}
+	if sybil.TABRel <= 1 || twin.TABRel != 1 {
+		t.Errorf("TAB rel: sybil %v, twin %v", sybil.TABRel, twin.TABRel)
+	}
+	// Honest miners, and miners without an honest twin, have no advantage.
+	for _, r := range append(rs[:4:4], twin, sm1) {
+		if r.Twin != "" || r.TwinAdvantage != 0 {
+			t.Errorf("%s: twin %q, advantage %v", r.Address, r.Twin, r.TwinAdvantage)
+		}
+	}
+}
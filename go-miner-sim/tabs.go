@@ -0,0 +1,63 @@
+package main
+
+import (
+	"math"
+	"math/big"
+
+	tabsadjust "github.com/whilei/go-tabs-adjust"
+)
+
+// The TABS adjustment algorithms are go-tabs-adjust's, shared with go-block-step and extrapolate-tabs.
+// The fork choices use getTABS and getTABS_step by default (see ForkChoice.BlockTABS);
+// SimulationConfig.TabsAdjustment replaces them with any of the library's.
+
+func (s *Simulation) getTABS(parentTabs, localTAB int64) (tabs int64) {
+	a, _ := tabsadjust.Sign(s.TabsAdjustmentDenominator)
+	return adjustTABS(a, tabsParent{tabs: parentTabs}, localTAB)
+}
+
+func (s *Simulation) getTABS_step(parentTabs, tabFallCount, localTAB int64) (tabs int64) {
+	a, _ := tabsadjust.Step(s.TabsAdjustmentDenominator, 9)
+	// The parent's own count is one short of a falling block's.
+	return adjustTABS(a, tabsParent{tabs: parentTabs, falls: tabFallCount - 1}, localTAB)
+}
+
+// adjustTABS is the TABS of a block with the TAB built on parent, capped to int64.
+func adjustTABS(a tabsadjust.TABSAdjuster, parent tabsadjust.Header, tab int64) int64 {
+	tabs := a.Adjust(parent, big.NewInt(tab))
+	if !tabs.IsInt64() {
+		if tabs.Sign() < 0 {
+			return math.MinInt64
+		}
+		return math.MaxInt64
+	}
+	return tabs.Int64()
+}
+
+// tabsHeader is a block of a miner's tree, as the TABS adjustment algorithms see it.
+type tabsHeader struct {
+	bt *BlockTree
+	b  *Block
+}
+
+func (h tabsHeader) TAB() *big.Int    { return big.NewInt(h.b.tab) }
+func (h tabsHeader) TABS() *big.Int   { return big.NewInt(h.b.tabs) }
+func (h tabsHeader) TABSFalls() int64 { return h.b.tabsFallCount }
+
+func (h tabsHeader) Parent() tabsadjust.Header {
+	p := h.bt.GetParent(h.b)
+	if p == nil {
+		return nil
+	}
+	return tabsHeader{h.bt, p}
+}
+
+// tabsParent is a parent known only by its TABS and count of falls, as getTABS and getTABS_step have it.
+type tabsParent struct {
+	tabs, falls int64
+}
+
+func (p tabsParent) TAB() *big.Int             { return nil }
+func (p tabsParent) TABS() *big.Int            { return big.NewInt(p.tabs) }
+func (p tabsParent) TABSFalls() int64          { return p.falls }
+func (p tabsParent) Parent() tabsadjust.Header { return nil }
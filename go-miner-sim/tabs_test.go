@@ -0,0 +1,57 @@
+package main
+
+import (
+	"testing"
+
+	tabsadjust "github.com/whilei/go-tabs-adjust"
+)
+
+// TestSimulation_TabsAdjustment runs each of the library's algorithms in place of the fork choice's,
+// and checks that every canonical block's TABS is the algorithm's, given the block's ancestry.
+func TestSimulation_TabsAdjustment(t *testing.T) {
+	for _, name := range tabsadjust.Names {
+		sc := defaultScenario()
+		sc.Seed = 5
+		sc.ConsensusAlgorithm = TDTABS.String()
+		sc.Population.Count = 4
+		sc.Globals.TickSamples = 10 * 60 * 30
+		sc.Globals.TabsAdjustment = name
+		if err := sc.validate(); err != nil {
+			t.Fatal(err)
+		}
+
+		miners, err := runReplicate(sc, sc.Seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		a, _ := tabsadjust.Parse(name)
+		m := miners[0]
+		if m.head.i == 0 {
+			t.Fatalf("%s: nothing mined", name)
+		}
+		for b := m.head; b.i > 0; b = m.Blocks.GetParent(b) {
+			parent := m.Blocks.GetParent(b)
+			if want := adjustTABS(a, tabsHeader{m.Blocks, parent}, b.tab); b.tabs != want {
+				t.Fatalf("%s: block %s: TABS %d, want %d", name, b, b.tabs, want)
+			}
+		}
+	}
+}
+
+func TestSimulation_GetTABS_Step(t *testing.T) {
+	config := DefaultSimulationConfig()
+	config.TabsAdjustmentDenominator = 128
+	sim := NewSimulation(config)
+
+	tabs := genesisBlockTABS
+	for falls := int64(1); falls <= 20; falls++ {
+		got := sim.getTABS_step(tabs, falls, 0)
+		if want := tabs + tabs*(-1-falls/9)/128; got != want {
+			t.Errorf("fall %d: got %d, want %d", falls, got, want)
+		}
+		tabs = got
+	}
+	if got, want := sim.getTABS_step(tabs, 0, tabs*2), tabs+tabs/128; got != want {
+		t.Errorf("rise: got %d, want %d", got, want)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// allowedFutureBlockSeconds is how far ahead of a miner's clock a block's timestamp may be, as in geth's ethash.
+// Blocks stamped later than that are rejected; blocks stamped ahead of the clock, but within it,
+// are future blocks, which the miner holds until its clock catches up with them.
+const allowedFutureBlockSeconds = 15
+
+// TimestampPolicy is how a miner stamps its blocks. Both TD (by the difficulty's interval term) and TDTABS
+// reward short intervals, so a miner may forge its timestamps, within the validity rules:
+// after the parent's, and no more than allowedFutureBlockSeconds ahead of the clock.
+// Policies are registered by name (see RegisterTimestampPolicy).
+type TimestampPolicy interface {
+	String() string
+
+	// Timestamp is the tick the miner stamps a block it mines on the parent with.
+	// The miner rounds it down to a whole second, and stamps it after the parent's if it isn't.
+	Timestamp(m *Miner, parent *Block) int64
+}
+
+var (
+	// HonestTimestamp stamps the time the block was mined.
+	HonestTimestamp TimestampPolicy = &timestampOffset{"honest", 0}
+
+	// Earliest stamps the block a second after its parent, the shortest interval there is, raising its difficulty.
+	// "earliest:<seconds>" stamps it at most that long after its parent (eg. just under the difficulty's 9 seconds),
+	// which looks less like forgery.
+	Earliest TimestampPolicy = &timestampEarliest{"earliest", 1}
+)
+
+func init() {
+	RegisterTimestampPolicy(HonestTimestamp)
+	RegisterTimestampPolicy(Earliest)
+}
+
+var (
+	timestampPolicies    = map[string]TimestampPolicy{}
+	timestampPolicyNames []string // in registration order
+)
+
+// RegisterTimestampPolicy makes the policy available by its name.
+// It panics if the name is taken.
+func RegisterTimestampPolicy(p TimestampPolicy) {
+	name := p.String()
+	if _, ok := timestampPolicies[name]; ok {
+		panic(fmt.Sprintf("timestamp policy %q registered twice", name))
+	}
+	timestampPolicies[name] = p
+	timestampPolicyNames = append(timestampPolicyNames, name)
+}
+
+func parseTimestampPolicy(s string) (TimestampPolicy, error) {
+	if p, ok := timestampPolicies[s]; ok {
+		return p, nil
+	}
+	if v := strings.TrimPrefix(s, "earliest:"); v != s {
+		seconds, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || seconds < 1 {
+			return nil, fmt.Errorf("timestamp policy %q: want at least 1 second", s)
+		}
+		return &timestampEarliest{s, seconds}, nil
+	}
+	// "offset:<seconds>" stamps the time the block was mined plus the offset:
+	// a negative offset backdates it (to no earlier than its parent), a positive one postdates it.
+	if v := strings.TrimPrefix(s, "offset:"); v != s {
+		seconds, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || seconds > allowedFutureBlockSeconds {
+			return nil, fmt.Errorf("timestamp policy %q: want an offset of at most %d seconds", s, allowedFutureBlockSeconds)
+		}
+		return &timestampOffset{s, seconds}, nil
+	}
+	return nil, fmt.Errorf("unknown timestamp policy: %q (want one of %s)", s, strings.Join(timestampPolicyNames, ", "))
+}
+
+// timestampOffset stamps the time the block was mined, offset by some seconds.
+type timestampOffset struct {
+	name    string
+	seconds int64
+}
+
+func (p *timestampOffset) String() string { return p.name }
+
+func (p *timestampOffset) Timestamp(m *Miner, parent *Block) int64 {
+	return m.tick + p.seconds*m.sim.TicksPerSecond
+}
+
+// timestampEarliest stamps the block at most some seconds after its parent, and no later than it was mined.
+type timestampEarliest struct {
+	name    string
+	seconds int64
+}
+
+func (p *timestampEarliest) String() string { return p.name }
+
+func (p *timestampEarliest) Timestamp(m *Miner, parent *Block) int64 {
+	if s := parent.s + p.seconds*m.sim.TicksPerSecond; s < m.tick {
+		return s
+	}
+	return m.tick
+}
+
+func (m *Miner) timestampPolicy() TimestampPolicy {
+	if m.TimestampPolicy == nil {
+		return HonestTimestamp
+	}
+	return m.TimestampPolicy
+}
+
+// validTimestamp tells whether the block's timestamp is valid by the miner's clock:
+// after its parent's, which the miner must have, and no more than allowedFutureBlockSeconds ahead.
+// Like geth, it compares the clock in whole seconds.
+func (m *Miner) validTimestamp(b *Block) bool {
+	parent := m.Blocks.GetBlockByHash(b.ph)
+	return parent != nil && b.s > parent.s &&
+		b.s/m.sim.TicksPerSecond <= m.tick/m.sim.TicksPerSecond+allowedFutureBlockSeconds
+}
+
+// futureBlock tells whether the block's timestamp is ahead of the miner's clock.
+func (m *Miner) futureBlock(b *Block) bool {
+	return b.s/m.sim.TicksPerSecond > m.tick/m.sim.TicksPerSecond
+}
@@ -0,0 +1,115 @@
+package main
+
+import "testing"
+
+func TestParseTimestampPolicy(t *testing.T) {
+	for _, s := range []string{"honest", "earliest", "earliest:8", "offset:-5", "offset:15"} {
+		if p, err := parseTimestampPolicy(s); err != nil || p.String() != s {
+			t.Errorf("%s: %v, %v", s, p, err)
+		}
+	}
+	for _, s := range []string{"", "forge", "earliest:0", "earliest:x", "offset:16", "offset:"} {
+		if _, err := parseTimestampPolicy(s); err == nil {
+			t.Errorf("%s: want an error", s)
+		}
+	}
+}
+
+func TestTimestampPolicies(t *testing.T) {
+	for _, c := range []struct {
+		policy string
+		s      int64 // seconds; the block is mined at 13
+	}{
+		{"honest", 13},
+		{"earliest", 1},
+		{"earliest:8", 8},
+		{"earliest:20", 13},
+		{"offset:-5", 8},
+		{"offset:15", 28},
+	} {
+		m, _ := strategyMiner(t, "honest")
+		m.TimestampPolicy, _ = parseTimestampPolicy(c.policy)
+		b := mine(m)
+		if b.s != c.s*m.sim.TicksPerSecond || b.si != b.s {
+			t.Errorf("%s: stamped %d (interval %d), want %ds", c.policy, b.s, b.si, c.s)
+		}
+	}
+
+	// Backdating stops short of the parent.
+	m, _ := strategyMiner(t, "honest")
+	m.TimestampPolicy, _ = parseTimestampPolicy("offset:-15")
+	mine(m)
+	if b := mine(m); b.si < 1 {
+		t.Errorf("backdated to %d after its parent", b.si)
+	}
+}
+
+func TestFutureBlocks(t *testing.T) {
+	m, _ := strategyMiner(t, "honest")
+	m.HashesPerTick = 0
+	tps := m.sim.TicksPerSecond
+	g := m.sim.genesisBlock
+	child := func(parent *Block, h string, seconds int64) *Block {
+		return &Block{i: parent.i + 1, s: seconds * tps, si: seconds*tps - parent.s, d: parent.d, td: parent.td + parent.d,
+			miner: "bbbbbb", ph: parent.h, h: h}
+	}
+	receive := func(b *Block) {
+		m.receiveBlock(&delivery{block: b, hops: 1, sent: m.tick})
+	}
+	m.doTick(100 * tps)
+
+	// A block 10 seconds ahead is held until the miner's clock reaches it.
+	future := child(g, "future", 110)
+	receive(future)
+	if m.head == future || len(m.deliveries[future.s]) != 1 {
+		t.Fatalf("future block not held: head %s, deliveries %v", m.head, m.deliveries)
+	}
+	m.doTick(future.s - 1)
+	if m.head == future {
+		t.Fatal("future block imported early")
+	}
+	m.doTick(future.s)
+	if m.head != future {
+		t.Fatalf("future block not imported on time: head %s", m.head)
+	}
+
+	// A block more than allowedFutureBlockSeconds ahead is rejected.
+	far := child(g, "far", 111+allowedFutureBlockSeconds)
+	receive(far)
+	if m.Blocks.GetBlockByHash(far.h) != nil || !m.rejected[far.h] {
+		t.Errorf("far future block not rejected")
+	}
+
+	// It stays rejected: a later copy of it, and its child, are dropped, though the clock has caught up with them.
+	farChild := child(far, "farChild", 135)
+	m.doTick(140 * tps)
+	receive(far)
+	receive(farChild)
+	if m.Blocks.GetBlockByHash(far.h) != nil || m.Blocks.GetBlockByHash(farChild.h) != nil || !m.rejected[farChild.h] {
+		t.Errorf("rejected block or its child imported later")
+	}
+
+	// A block stamped no later than its parent is rejected, whatever interval it claims.
+	early := child(future, "early", 110)
+	early.si = tps
+	receive(early)
+	if m.Blocks.GetBlockByHash(early.h) != nil {
+		t.Errorf("block stamped with its parent's time imported")
+	}
+
+	// An orphan waits for its parent.
+	parent := child(future, "parent", 120)
+	orphan := child(parent, "orphan", 125)
+	receive(orphan)
+	if m.Blocks.GetBlockByHash(orphan.h) != nil || m.rejected[orphan.h] {
+		t.Fatal("orphan imported (or rejected) before its parent")
+	}
+	receive(parent)
+	if m.head != orphan || len(m.orphans) != 0 {
+		t.Errorf("orphan not imported after its parent: head %s", m.head)
+	}
+
+	if r := m.results(); r.Rejected != 3 || r.TimestampPolicy != HonestTimestamp {
+		t.Errorf("results: %d rejected, policy %s", r.Rejected, r.TimestampPolicy)
+	}
+}
@@ -0,0 +1,430 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Topologies, ie. how miners are wired to their neighbors.
+const (
+	TopologyCoinFlip      = "coinflip"       // each directed pair with probability MinerNeighborRate (legacy)
+	TopologyComplete      = "complete"       // everyone peers with everyone
+	TopologyErdosRenyi    = "erdos-renyi"    // each pair with probability MinerNeighborRate, then joined up if disconnected
+	TopologyRandomRegular = "random-regular" // everyone has Degree peers, like geth's maxpeers
+	TopologySmallWorld    = "small-world"    // Watts-Strogatz: a ring of Degree nearest peers, rewired with probability Rewire
+	TopologyScaleFree     = "scale-free"     // Barabási-Albert: newcomers attach to Degree/2 peers, preferring well-connected ones
+	TopologyFile          = "file"           // an explicit edge list, see loadEdges
+)
+
+// TopologyConfig describes the neighbor graph.
+// All topologies but coinflip are undirected: a peer relays to us as we relay to it.
+type TopologyConfig struct {
+	// Kind is one of the Topology* constants. Empty means coinflip.
+	Kind string
+
+	// Degree is the number of peers per miner for random-regular and small-world,
+	// and twice the number of peers a newcomer attaches to for scale-free.
+	Degree int
+
+	// Rewire is the small-world probability of rewiring each ring edge.
+	Rewire float64
+
+	// EdgesFile is the edge list file of the file topology.
+	EdgesFile string
+}
+
+func (c TopologyConfig) validate(countMiners int64) error {
+	n := int(countMiners)
+	switch c.Kind {
+	case "", TopologyCoinFlip, TopologyComplete, TopologyErdosRenyi:
+	case TopologyRandomRegular:
+		if c.Degree < 1 || c.Degree >= n {
+			return fmt.Errorf("random-regular degree must be in [1, %d)", n)
+		}
+		if n*c.Degree%2 != 0 {
+			return fmt.Errorf("random-regular needs an even miners*degree, got %d*%d", n, c.Degree)
+		}
+	case TopologySmallWorld:
+		if c.Degree < 2 || c.Degree%2 != 0 || c.Degree >= n {
+			return fmt.Errorf("small-world degree must be even and in [2, %d)", n)
+		}
+		if c.Rewire < 0 || c.Rewire > 1 {
+			return fmt.Errorf("small-world rewire must be in [0, 1]")
+		}
+	case TopologyScaleFree:
+		if c.Degree < 2 || c.Degree/2 >= n {
+			return fmt.Errorf("scale-free degree must be in [2, %d)", 2*n)
+		}
+	case TopologyFile:
+		if c.EdgesFile == "" {
+			return fmt.Errorf("file topology needs an edges file")
+		}
+	default:
+		return fmt.Errorf("unknown topology: %q", c.Kind)
+	}
+	return nil
+}
+
+// link is a miner's connection to a neighbor.
+// Each link carries its own latency.
+type link struct {
+	to      *Miner
+	latency func() int64 // ticks
+
+	// carries, if not nil, tells which blocks the link carries, eg. during an eclipse (see NetworkEvent).
+	carries func(b *Block) bool
+}
+
+// edge is a connection between miners i and j.
+// latency, if not nil, overrides the simulation's link latency on the edge.
+type edge struct {
+	i, j    int
+	latency latencyDist
+}
+
+// topologyEdges generates the edges of the configured topology for n miners.
+// directed reports whether the edges are one-way (coinflip) or go both ways.
+func (s *Simulation) topologyEdges(miners []*Miner) (edges []edge, directed bool, err error) {
+	c := s.Topology
+	n := len(miners)
+	r := s.rng(rngNeighbors)
+
+	switch c.Kind {
+	case "", TopologyCoinFlip:
+		return coinFlipEdges(r, n, s.MinerNeighborRate), true, nil
+	case TopologyComplete:
+		return completeEdges(n), false, nil
+	case TopologyErdosRenyi:
+		return erdosRenyiEdges(r, n, s.MinerNeighborRate), false, nil
+	case TopologyRandomRegular:
+		edges, err := randomRegularEdges(r, n, c.Degree)
+		return edges, false, err
+	case TopologySmallWorld:
+		return smallWorldEdges(r, n, c.Degree, c.Rewire), false, nil
+	case TopologyScaleFree:
+		return scaleFreeEdges(r, n, c.Degree/2), false, nil
+	case TopologyFile:
+		edges, err := loadEdges(c.EdgesFile, miners)
+		return edges, false, err
+	}
+	return nil, false, fmt.Errorf("unknown topology: %q", c.Kind)
+}
+
+func coinFlipEdges(r *rand.Rand, n int, rate float64) (edges []edge) {
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			if i == j {
+				continue
+			}
+			if r.Float64() < rate {
+				edges = append(edges, edge{i: i, j: j})
+			}
+		}
+	}
+	return edges
+}
+
+func completeEdges(n int) (edges []edge) {
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			edges = append(edges, edge{i: i, j: j})
+		}
+	}
+	return edges
+}
+
+// erdosRenyiEdges connects each pair with probability p.
+// If that leaves the graph in pieces, each piece is joined to the first by a random edge.
+func erdosRenyiEdges(r *rand.Rand, n int, p float64) (edges []edge) {
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			if r.Float64() < p {
+				edges = append(edges, edge{i: i, j: j})
+			}
+		}
+	}
+
+	components := graphComponents(n, edges)
+	for _, c := range components[1:] {
+		a := components[0][r.Intn(len(components[0]))]
+		b := c[r.Intn(len(c))]
+		edges = append(edges, edge{i: a, j: b})
+	}
+	return edges
+}
+
+// randomRegularEdges pairs up k stubs per node at random (the configuration model),
+// then repairs the pairing's self-loops and duplicate edges by degree-preserving switches:
+// a bad edge ab and a random edge cd become ac and bd (or ad and bc) if neither is taken.
+// Retrying until a pairing comes out simple would almost never succeed at geth-like degrees.
+// Graphs denser than half complete are the complements of sparse ones, which switch more easily.
+func randomRegularEdges(r *rand.Rand, n, k int) ([]edge, error) {
+	if 2*k > n-1 {
+		sparse, err := randomRegularEdges(r, n, n-1-k)
+		if err != nil {
+			return nil, err
+		}
+		has := map[[2]int]bool{}
+		for _, e := range sparse {
+			has[[2]int{e.i, e.j}] = true
+		}
+		edges := []edge{}
+		for i := 0; i < n; i++ {
+			for j := i + 1; j < n; j++ {
+				if !has[[2]int{i, j}] {
+					edges = append(edges, edge{i: i, j: j})
+				}
+			}
+		}
+		return edges, nil
+	}
+
+	stubs := make([]int, 0, n*k)
+	for i := 0; i < n; i++ {
+		for s := 0; s < k; s++ {
+			stubs = append(stubs, i)
+		}
+	}
+	r.Shuffle(len(stubs), func(a, b int) { stubs[a], stubs[b] = stubs[b], stubs[a] })
+
+	key := func(a, b int) [2]int {
+		if a > b {
+			a, b = b, a
+		}
+		return [2]int{a, b}
+	}
+	pairs := make([][2]int, 0, len(stubs)/2)
+	count := map[[2]int]int{}
+	for s := 0; s < len(stubs); s += 2 {
+		p := key(stubs[s], stubs[s+1])
+		pairs = append(pairs, p)
+		count[p]++
+	}
+	bad := func(p [2]int) bool { return p[0] == p[1] || count[p] > 1 }
+
+	next := 0 // pairs before next are good; a switch never makes a good pair bad
+	for tries := 0; tries < 100*len(pairs)+1000; tries++ {
+		for next < len(pairs) && !bad(pairs[next]) {
+			next++
+		}
+		if next == len(pairs) {
+			edges := make([]edge, len(pairs))
+			for i, p := range pairs {
+				edges[i] = edge{i: p[0], j: p[1]}
+			}
+			return edges, nil
+		}
+		y := r.Intn(len(pairs))
+		e, f := pairs[next], pairs[y]
+		if y == next || bad(f) {
+			continue
+		}
+		c, d := f[0], f[1]
+		if r.Intn(2) == 0 {
+			c, d = d, c
+		}
+		g, h := key(e[0], c), key(e[1], d)
+		if g[0] == g[1] || h[0] == h[1] || g == h || count[g] > 0 || count[h] > 0 {
+			continue
+		}
+		count[e]--
+		count[f]--
+		count[g]++
+		count[h]++
+		pairs[next], pairs[y] = g, h
+	}
+	return nil, fmt.Errorf("could not generate a %d-regular graph on %d miners", k, n)
+}
+
+// smallWorldEdges builds a ring where everyone peers with their k nearest neighbors,
+// then rewires each edge's far end to a random miner with probability beta (Watts-Strogatz).
+func smallWorldEdges(r *rand.Rand, n, k int, beta float64) (edges []edge) {
+	has := map[[2]int]bool{}
+	key := func(a, b int) [2]int {
+		if a > b {
+			a, b = b, a
+		}
+		return [2]int{a, b}
+	}
+	for i := 0; i < n; i++ {
+		for d := 1; d <= k/2; d++ {
+			has[key(i, (i+d)%n)] = true
+		}
+	}
+	for d := 1; d <= k/2; d++ {
+		for i := 0; i < n; i++ {
+			j := (i + d) % n
+			if r.Float64() >= beta {
+				continue
+			}
+			candidates := []int{}
+			for c := 0; c < n; c++ {
+				if c != i && !has[key(i, c)] {
+					candidates = append(candidates, c)
+				}
+			}
+			if len(candidates) == 0 {
+				continue
+			}
+			delete(has, key(i, j))
+			has[key(i, candidates[r.Intn(len(candidates))])] = true
+		}
+	}
+	// Visit edges in a fixed order so the result doesn't depend on map iteration.
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			if has[[2]int{i, j}] {
+				edges = append(edges, edge{i: i, j: j})
+			}
+		}
+	}
+	return edges
+}
+
+// scaleFreeEdges grows a Barabási-Albert graph: it starts from a complete graph of m+1 miners,
+// then each newcomer attaches to m distinct miners chosen with probability proportional to their degree.
+func scaleFreeEdges(r *rand.Rand, n, m int) (edges []edge) {
+	if m < 1 {
+		m = 1
+	}
+	seed := m + 1
+	if seed > n {
+		seed = n
+	}
+	// targets lists each node once per edge end, so a uniform pick from it is degree-proportional.
+	targets := []int{}
+	for i := 0; i < seed; i++ {
+		for j := i + 1; j < seed; j++ {
+			edges = append(edges, edge{i: i, j: j})
+			targets = append(targets, i, j)
+		}
+	}
+	for i := seed; i < n; i++ {
+		chosen := map[int]bool{}
+		picks := []int{}
+		for len(picks) < m {
+			t := targets[r.Intn(len(targets))]
+			if !chosen[t] {
+				chosen[t] = true
+				picks = append(picks, t)
+			}
+		}
+		for _, t := range picks {
+			edges = append(edges, edge{i: t, j: i})
+			targets = append(targets, t, i)
+		}
+	}
+	return edges
+}
+
+// loadEdges reads an edge list: one edge per line, "<a> <b> [latency]",
+// where a and b are miner addresses or indexes and latency is a latency spec, eg. 0.5 or uniform:0.2:1. Blank lines and lines starting with '#' are skipped.
+func loadEdges(path string, miners []*Miner) (edges []edge, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	index := map[string]int{}
+	for i, m := range miners {
+		index[m.Address] = i
+		index[strconv.Itoa(i)] = i
+	}
+
+	sc := bufio.NewScanner(f)
+	for line := 1; sc.Scan(); line++ {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) < 2 || len(fields) > 3 {
+			return nil, fmt.Errorf("%s:%d: want <a> <b> [latency]", path, line)
+		}
+		e := edge{}
+		var ok bool
+		if e.i, ok = index[fields[0]]; !ok {
+			return nil, fmt.Errorf("%s:%d: unknown miner %q", path, line, fields[0])
+		}
+		if e.j, ok = index[fields[1]]; !ok {
+			return nil, fmt.Errorf("%s:%d: unknown miner %q", path, line, fields[1])
+		}
+		if e.i == e.j {
+			return nil, fmt.Errorf("%s:%d: self-loop", path, line)
+		}
+		if len(fields) == 3 {
+			if e.latency, err = parseLatencyDist(fields[2]); err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+			}
+		}
+		edges = append(edges, e)
+	}
+	return edges, sc.Err()
+}
+
+// graphComponents returns the connected components of an undirected graph, ordered by lowest member.
+func graphComponents(n int, edges []edge) (components [][]int) {
+	adjacent := make([][]int, n)
+	for _, e := range edges {
+		adjacent[e.i] = append(adjacent[e.i], e.j)
+		adjacent[e.j] = append(adjacent[e.j], e.i)
+	}
+	seen := make([]bool, n)
+	for i := 0; i < n; i++ {
+		if seen[i] {
+			continue
+		}
+		component := []int{}
+		stack := []int{i}
+		seen[i] = true
+		for len(stack) > 0 {
+			v := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			component = append(component, v)
+			for _, w := range adjacent[v] {
+				if !seen[w] {
+					seen[w] = true
+					stack = append(stack, w)
+				}
+			}
+		}
+		components = append(components, component)
+	}
+	return components
+}
+
+// connect wires up the miners' neighbors according to the topology.
+// Links sample the simulation's latency distribution, or use the sending miner's Latency if there is none,
+// unless their edge has its own.
+func (s *Simulation) connect(miners []*Miner) error {
+	edges, directed, err := s.topologyEdges(miners)
+	if err != nil {
+		return err
+	}
+	var dist latencyDist
+	if s.LatencyDist != "" {
+		if dist, err = parseLatencyDist(s.LatencyDist); err != nil {
+			return err
+		}
+	}
+	addLink := func(from, to *Miner, edgeDist latencyDist) {
+		l := &link{to: to, latency: from.Latency}
+		if edgeDist != nil {
+			l.latency = s.latencyFunc(edgeDist)
+		} else if dist != nil {
+			l.latency = s.latencyFunc(dist)
+		}
+		from.neighbors = append(from.neighbors, l)
+	}
+	for _, e := range edges {
+		addLink(miners[e.i], miners[e.j], e.latency)
+		if !directed {
+			addLink(miners[e.j], miners[e.i], e.latency)
+		}
+	}
+	return nil
+}
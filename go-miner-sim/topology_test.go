@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func degrees(n int, edges []edge) []int {
+	ds := make([]int, n)
+	for _, e := range edges {
+		ds[e.i]++
+		ds[e.j]++
+	}
+	return ds
+}
+
+func checkSimpleGraph(t *testing.T, name string, n int, edges []edge) {
+	t.Helper()
+	seen := map[[2]int]bool{}
+	for _, e := range edges {
+		a, b := e.i, e.j
+		if a > b {
+			a, b = b, a
+		}
+		if a == b {
+			t.Fatalf("%s: self-loop at %d", name, a)
+		}
+		if seen[[2]int{a, b}] {
+			t.Fatalf("%s: duplicate edge %d-%d", name, a, b)
+		}
+		seen[[2]int{a, b}] = true
+	}
+	if c := graphComponents(n, edges); len(c) != 1 {
+		t.Fatalf("%s: %d components", name, len(c))
+	}
+}
+
+func TestTopologies(t *testing.T) {
+	const n = 24
+	r := rand.New(rand.NewSource(1))
+
+	complete := completeEdges(n)
+	checkSimpleGraph(t, "complete", n, complete)
+	if len(complete) != n*(n-1)/2 {
+		t.Errorf("complete: %d edges", len(complete))
+	}
+
+	// A low rate leaves Erdős–Rényi graphs in pieces, which must be joined up.
+	for i := 0; i < 20; i++ {
+		checkSimpleGraph(t, "erdos-renyi", n, erdosRenyiEdges(r, n, 0.02))
+	}
+
+	regular, err := randomRegularEdges(r, n, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSimpleGraph(t, "random-regular", n, regular)
+	for i, d := range degrees(n, regular) {
+		if d != 4 {
+			t.Errorf("random-regular: miner %d has degree %d", i, d)
+		}
+	}
+
+	// Geth-like degrees, where a configuration model pairing is almost never simple, and a near-complete graph.
+	for _, c := range []struct{ n, k int }{{50, 8}, {50, 25}, {60, 25}, {200, 50}, {50, 49}} {
+		regular, err := randomRegularEdges(r, c.n, c.k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := fmt.Sprintf("%d-regular on %d", c.k, c.n)
+		checkSimpleGraph(t, name, c.n, regular)
+		for i, d := range degrees(c.n, regular) {
+			if d != c.k {
+				t.Errorf("%s: miner %d has degree %d", name, i, d)
+			}
+		}
+	}
+
+	ring := smallWorldEdges(r, n, 4, 0)
+	checkSimpleGraph(t, "small-world", n, ring)
+	for i, d := range degrees(n, ring) {
+		if d != 4 {
+			t.Errorf("small-world ring: miner %d has degree %d", i, d)
+		}
+	}
+	if rewired := smallWorldEdges(r, n, 4, 0.3); len(rewired) != len(ring) {
+		t.Errorf("small-world: rewiring changed the edge count from %d to %d", len(ring), len(rewired))
+	}
+
+	scaleFree := scaleFreeEdges(r, n, 2)
+	checkSimpleGraph(t, "scale-free", n, scaleFree)
+	if want := 3 + (n-3)*2; len(scaleFree) != want {
+		t.Errorf("scale-free: %d edges, want %d", len(scaleFree), want)
+	}
+}
+
+func TestSimulation_ConnectFile(t *testing.T) {
+	config := DefaultSimulationConfig()
+	config.CountMiners = 3
+	sim := NewSimulation(config)
+	miners := minersNormal(sim, nil, func(m *Miner) {})
+
+	path := filepath.Join(t.TempDir(), "edges.txt")
+	ioutil.WriteFile(path, []byte("# a line topology\n0 1\n"+miners[1].Address+" 2 2.5\n"), os.ModePerm)
+	sim.Topology = TopologyConfig{Kind: TopologyFile, EdgesFile: path}
+	if err := sim.connect(miners); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(miners[0].neighbors) != 1 || len(miners[1].neighbors) != 2 || len(miners[2].neighbors) != 1 {
+		t.Fatalf("bad neighbor counts: %d %d %d", len(miners[0].neighbors), len(miners[1].neighbors), len(miners[2].neighbors))
+	}
+	if got := miners[0].neighbors[0].latency(); got != miners[0].Latency() {
+		t.Errorf("default link latency: got %d, want the miner's %d", got, miners[0].Latency())
+	}
+	if got := miners[2].neighbors[0].latency(); got != 25 {
+		t.Errorf("edge latency: got %d ticks, want 25", got)
+	}
+
+	ioutil.WriteFile(path, []byte("0 7\n"), os.ModePerm)
+	if _, err := loadEdges(path, miners); err == nil {
+		t.Error("expected an error for an unknown miner")
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+// Uncle inclusion follows Ethereum: a block may reference up to two uncles,
+// blocks off its own chain whose parents are among its last seven ancestors,
+// which haven't been referenced by an ancestor already.
+// The uncle's miner earns (8 - depth)/8 of the block reward, depth being how far below the including block the uncle is;
+// the including (nephew) block's miner earns an extra 1/32 of the block reward per uncle.
+const (
+	maxUncles     = 2
+	maxUncleDepth = 6
+)
+
+// selectUncles chooses the uncles for a block built on parent from the miner's tree,
+// preferring the highest (best paid) and then those seen first.
+func (m *Miner) selectUncles(parent *Block) (uncles Blocks) {
+	ancestors := map[string]bool{}
+	included := map[string]bool{}
+	a := parent
+	for k := 0; a != nil && k <= maxUncleDepth; k++ {
+		ancestors[a.h] = true
+		for _, u := range a.uncles {
+			included[u.h] = true
+		}
+		a = m.Blocks.GetParent(a)
+	}
+
+	for i := parent.i; i > parent.i-maxUncleDepth && i > 0; i-- {
+		for _, b := range m.Blocks.GetBlocksByNumber(i) {
+			if ancestors[b.h] || included[b.h] || !ancestors[b.ph] {
+				continue
+			}
+			uncles = append(uncles, b)
+			if len(uncles) == maxUncles {
+				return uncles
+			}
+		}
+	}
+	return uncles
+}
+
+// blockRewards is what the address earns from the block being canonical:
+// the block reward and nephew rewards if it mined the block, and uncle rewards for any of its blocks the block references.
+func (s *Simulation) blockRewards(b *Block, address string) float64 {
+	l := s.blockLedger(b, address)
+	return l.Rewards + l.UncleRewards
+}
+
+// GHOST is the greedy heaviest-observed subtree rule: from the fork between two blocks,
+// the head is found by walking down to the child whose subtree holds the most difficulty, level by level,
+// so that the work of forked blocks counts toward their branch.
+var GHOST ForkChoice = ghost{}
+
+func init() {
+	RegisterForkChoice(GHOST)
+}
+
+type ghost struct{}
+
+func (ghost) String() string { return "GHOST" }
+
+// Score is the difficulty of the block's ancestry plus that of its subtree.
+func (ghost) Score(bt *BlockTree, b *Block) int64 {
+	return b.td - b.d + bt.subtreeDifficulty(b)
+}
+
+// Compare prefers the block that stays longer on the walk from the fork down the heaviest subtrees.
+func (ghost) Compare(bt *BlockTree, a, b *Block) int {
+	ancestor := bt.CommonAncestor(a, b)
+	switch {
+	case ancestor == nil:
+		// No shared history the miner knows of; weigh the chains.
+		return compareScores(TD, bt, a, b)
+	case ancestor.h == b.h:
+		return 1 // a builds on b
+	case ancestor.h == a.h:
+		return -1
+	}
+	tip := bt.heaviestTip(ancestor, nil)
+	ta, tb := bt.CommonAncestor(tip, a), bt.CommonAncestor(tip, b)
+	if ta.i > tb.i {
+		return 1
+	} else if tb.i > ta.i {
+		return -1
+	}
+	return compareScores(TD, bt, a, b)
+}
+
+// Tip is the end of the walk from the blocks' fork down the heaviest subtrees, which may be neither of them.
+// Of equally heavy subtrees, it keeps to the incumbent a's.
+func (g ghost) Tip(bt *BlockTree, a, b *Block) *Block {
+	ancestor := bt.CommonAncestor(a, b)
+	if ancestor == nil {
+		if compareScores(TD, bt, a, b) < 0 {
+			return b
+		}
+		return a
+	}
+	return bt.heaviestTip(ancestor, a)
+}
+
+func (ghost) TieBreak(a, b *Block) (*Block, string) { return nil, "" }
+
+func (ghost) BlockTABS(s *Simulation, parent *Block, _, localTAB int64) int64 {
+	return s.getTABS(parent.tabs, localTAB)
+}
+
+// subtreeDifficulty is the difficulty of the block and all its known descendants.
+// The tree keeps them from the first call on, updating them as blocks are added (see addWeight).
+func (bt *BlockTree) subtreeDifficulty(b *Block) int64 {
+	if bt.weights == nil {
+		bt.weights = make(map[string]int64, len(bt.byHash))
+		var weigh func(b *Block) int64
+		weigh = func(b *Block) int64 {
+			w := b.d
+			for _, child := range bt.GetChildren(b) {
+				w += weigh(child)
+			}
+			bt.weights[b.h] = w
+			return w
+		}
+		for _, b := range bt.byHash {
+			if bt.GetParent(b) == nil {
+				weigh(b)
+			}
+		}
+	}
+	return bt.weights[b.h]
+}
+
+// addWeight adds a new block's subtree, itself and any children that came before it, to its ancestors'.
+func (bt *BlockTree) addWeight(b *Block) {
+	w := b.d
+	for _, child := range bt.GetChildren(b) {
+		w += bt.weights[child.h]
+	}
+	bt.weights[b.h] = w
+	for a := bt.GetParent(b); a != nil; a = bt.GetParent(a) {
+		bt.weights[a.h] += w
+	}
+}
+
+// heaviestTip walks down from the block to a tip, taking the child with the heaviest subtree at each level.
+// Of equally heavy children, it takes the one toward prefer if there is one, else the first the miner got.
+func (bt *BlockTree) heaviestTip(b, prefer *Block) *Block {
+	toward := map[string]bool{}
+	for p := prefer; p != nil && p.i > b.i; p = bt.GetParent(p) {
+		toward[p.h] = true
+	}
+	for {
+		var next *Block
+		for _, child := range bt.GetChildren(b) {
+			if next == nil {
+				next = child
+				continue
+			}
+			wc, wn := bt.subtreeDifficulty(child), bt.subtreeDifficulty(next)
+			if wc > wn || wc == wn && toward[child.h] {
+				next = child
+			}
+		}
+		if next == nil {
+			return b
+		}
+		b = next
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"testing"
+)
+
+// testTree adds blocks named by their letter and number, eg. "b3" at number 3, to the miner's tree.
+// Each block has difficulty 1.
+func testTree(t *testing.T, m *Miner, parents map[string]string) map[string]*Block {
+	blocks := map[string]*Block{"g": m.sim.genesisBlock}
+	var add func(name string) *Block
+	add = func(name string) *Block {
+		if b, ok := blocks[name]; ok {
+			return b
+		}
+		parent := add(parents[name])
+		b := &Block{i: parent.i + 1, d: 1, td: parent.td + 1, miner: m.Address, ph: parent.h, h: name + "0000000"}
+		if b.i != int64(name[1]-'0') {
+			t.Fatalf("%s: at number %d", name, b.i)
+		}
+		blocks[name] = b
+		m.Blocks.AppendBlockByNumber(b)
+		return b
+	}
+	for name := range parents {
+		add(name)
+	}
+	return blocks
+}
+
+func TestSelectUncles(t *testing.T) {
+	sim := NewSimulation(DefaultSimulationConfig())
+	m := newMiner(sim, 0, "aaaaaa", 1, 0, nil)
+	parents := map[string]string{
+		"b1": "g", "c2": "b1", "b3": "a2", "b6": "a5", "b7": "a6",
+	}
+	for i, p := 1, "g"; i <= 8; i++ {
+		name := string([]byte{'a', byte('0' + i)})
+		parents[name], p = p, name
+	}
+	blocks := testTree(t, m, parents)
+	blocks["a4"].uncles = Blocks{blocks["b3"]}
+
+	for _, c := range []struct {
+		parent string
+		want   []string
+	}{
+		{"a8", []string{"b7", "b6"}}, // the highest, two at most
+		{"a5", []string{"b1"}},       // b3 is taken by a4, c2's parent is off the chain
+		{"a7", []string{"b7", "b6"}}, // an uncle can be the parent's sibling
+		{"a1", []string{"b1"}},
+		{"g", nil},
+	} {
+		got := m.selectUncles(blocks[c.parent])
+		if len(got) != len(c.want) {
+			t.Errorf("on %s: got %v, want %v", c.parent, got, c.want)
+			continue
+		}
+		for i, u := range got {
+			if u != blocks[c.want[i]] {
+				t.Errorf("on %s: got %v, want %v", c.parent, got, c.want)
+			}
+		}
+	}
+	// With b6 taken, there's room for b1, but it's too deep for a block on a7.
+	blocks["a6"].uncles = Blocks{blocks["b6"]}
+	if got := m.selectUncles(blocks["a7"]); len(got) != 1 || got[0] != blocks["b7"] {
+		t.Errorf("on a7: got %v, want [b7]", got)
+	}
+}
+
+func TestSimulation_BlockRewards(t *testing.T) {
+	sim := NewSimulation(DefaultSimulationConfig())
+	sim.BlockReward = 100
+	b := &Block{i: 10, miner: "xxxxxx", uncles: Blocks{
+		{i: 9, miner: "yyyyyy"},
+		{i: 8, miner: "xxxxxx"},
+	}}
+	// Fractions of the reward are kept: 100/32 and 700/8 aren't whole.
+	for address, want := range map[string]float64{
+		"xxxxxx": 100 + 2*100.0/32 + 6*100.0/8,
+		"yyyyyy": 7 * 100.0 / 8,
+		"zzzzzz": 0,
+	} {
+		if got := sim.blockRewards(b, address); got != want {
+			t.Errorf("%s: got %v, want %v", address, got, want)
+		}
+	}
+}
+
+// TestGHOST_Compare checks that GHOST prefers the heavier subtree over the longer chain:
+//
+//	g - a1 - a2 - a3
+//	 \
+//	  b1 - b2, c2, d2
+func TestGHOST_Compare(t *testing.T) {
+	sim := NewSimulation(DefaultSimulationConfig())
+	m := newMiner(sim, 0, "aaaaaa", 1, 0, nil)
+	blocks := testTree(t, m, map[string]string{
+		"a1": "g", "a2": "a1", "a3": "a2",
+		"b1": "g", "b2": "b1", "c2": "b1", "d2": "b1",
+	})
+	a3, b2 := blocks["a3"], blocks["b2"]
+
+	if got := GHOST.Compare(m.Blocks, a3, b2); got >= 0 {
+		t.Errorf("GHOST: got %d, want b2 preferred", got)
+	}
+	if got := TD.Compare(m.Blocks, a3, b2); got <= 0 {
+		t.Errorf("TD: got %d, want a3 preferred", got)
+	}
+	if got := GHOST.Compare(m.Blocks, a3, blocks["a2"]); got <= 0 {
+		t.Errorf("GHOST: got %d, want a descendant preferred", got)
+	}
+	if got, want := GHOST.Score(m.Blocks, blocks["b1"]), sim.genesisBlock.td+4; got != want {
+		t.Errorf("GHOST score: got %d, want %d", got, want)
+	}
+}
+
+// TestGHOST_Tip checks that GHOST walks down the heaviest subtrees from the fork,
+// past the branch's light leaf b2 to the tip of its heavier c branch, with the weights kept as blocks come,
+// even before their parents:
+//
+//	g - a1 - a2 - a3
+//	 \
+//	  b1 - b2
+//	     \
+//	      c2 - c3 - c4
+func TestGHOST_Tip(t *testing.T) {
+	sim := NewSimulation(DefaultSimulationConfig())
+	m := newMiner(sim, 0, "aaaaaa", 1, 0, nil)
+	m.ConsensusAlgorithm = GHOST
+	blocks := testTree(t, m, map[string]string{
+		"a1": "g", "a2": "a1", "a3": "a2",
+		"b1": "g", "b2": "b1", "c2": "b1",
+	})
+	a3, b2 := blocks["a3"], blocks["b2"]
+	if got := m.arbitrateBlocks(a3, b2); got != a3 {
+		t.Errorf("before c3: got %s, want a3 on a tie", got)
+	}
+
+	c3 := &Block{i: 3, d: 1, td: blocks["c2"].td + 1, ph: blocks["c2"].h, h: "c30000000"}
+	c4 := &Block{i: 4, d: 1, td: c3.td + 1, ph: c3.h, h: "c40000000"}
+	m.Blocks.AppendBlockByNumber(c4)
+	m.Blocks.AppendBlockByNumber(c3)
+
+	fresh := *m.Blocks
+	fresh.weights = nil
+	for h, b := range m.Blocks.byHash {
+		if got, want := m.Blocks.weights[h], fresh.subtreeDifficulty(b); got != want {
+			t.Errorf("%s: kept weight %d, want %d", h, got, want)
+		}
+	}
+
+	if got := m.arbitrateBlocks(a3, b2); got != c4 {
+		t.Errorf("got %s, want c4", got)
+	}
+	if got := GHOST.Compare(m.Blocks, a3, b2); got >= 0 {
+		t.Errorf("GHOST: got %d, want b2 preferred", got)
+	}
+	if got := GHOST.Compare(m.Blocks, b2, c4); got >= 0 {
+		t.Errorf("GHOST: got %d, want c4 preferred", got)
+	}
+}
+
+// TestUncles_Run checks that a run of GHOST miners includes uncles that aren't on the chain.
+func TestUncles_Run(t *testing.T) {
+	sc := defaultScenario()
+	sc.Seed = 5
+	sc.ConsensusAlgorithm = GHOST.String()
+	sc.Population.Count = 8
+	sc.Globals.BlockReward = 256
+	sc.Globals.TickSamples = 10 * 60 * 60
+
+	miners, err := runReplicate(sc, sc.Seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range miners {
+		r := m.results()
+		if r.UncleRate == 0 {
+			t.Errorf("%s: no uncles", m.Address)
+		}
+		for _, b := range m.canon {
+			if len(b.uncles) > maxUncles {
+				t.Fatalf("%s: block %s has %d uncles", m.Address, b, len(b.uncles))
+			}
+			for _, u := range b.uncles {
+				if m.canon.Has(u) {
+					t.Fatalf("%s: block %s references canonical block %s", m.Address, b, u)
+				}
+				if d := b.i - u.i; d < 1 || d > maxUncleDepth {
+					t.Fatalf("%s: block %s references uncle %s at depth %d", m.Address, b, u, d)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package tabsadjust
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+)
+
+// Sign moves TABS by 1/denominator of itself toward the TAB: up if the TAB is above it, down if below.
+// It is go-miner-sim's getTABS, go-block-step's getTABS (128) and extrapolate-tabs' chain extrapolation (2049).
+func Sign(denominator int64) (TABSAdjuster, error) {
+	if denominator <= 0 {
+		return nil, fmt.Errorf("sign: denominator must be positive")
+	}
+	return sign{denominator}, nil
+}
+
+type sign struct{ denominator int64 }
+
+func (a sign) String() string { return fmt.Sprintf("sign:%d", a.denominator) }
+
+func (a sign) Adjust(parent Header, tab *big.Int) *big.Int {
+	return scale(parent.TABS(), int64(tab.Cmp(parent.TABS())), a.denominator)
+}
+
+// Step is Sign, but falls steepen: the numerator of a fall is -1 - n/falls,
+// n being how many blocks in sequence (this one included) have had falling TABS.
+// It is go-miner-sim's getTABS_step (falls 9).
+func Step(denominator, falls int64) (TABSAdjuster, error) {
+	if denominator <= 0 || falls <= 0 {
+		return nil, fmt.Errorf("step: denominator and falls must be positive")
+	}
+	return step{denominator, falls}, nil
+}
+
+type step struct{ denominator, falls int64 }
+
+func (a step) String() string { return fmt.Sprintf("step:%d:%d", a.denominator, a.falls) }
+
+func (a step) Adjust(parent Header, tab *big.Int) *big.Int {
+	numerator := int64(tab.Cmp(parent.TABS()))
+	if numerator < 0 {
+		numerator -= Falls(parent, tab) / a.falls // floor divide
+	}
+	return scale(parent.TABS(), numerator, a.denominator)
+}
+
+// Ceil moves TABS by (1 - ⌈TABS/TAB⌉)/denominator of itself, the numerator clamped to [min, max].
+// It is extrapolate-tabs' TestTABSAdjustment1 (2049, -2, 1).
+// Since a ratio of less than one rounds up to one, a positive TAB never raises TABS;
+// the numerator is 0 for a TAB at or above TABS, and falls with the ratio below it.
+func Ceil(denominator, min, max int64) (TABSAdjuster, error) {
+	if denominator <= 0 {
+		return nil, fmt.Errorf("ceil: denominator must be positive")
+	}
+	if min > max {
+		return nil, fmt.Errorf("ceil: min must not be above max")
+	}
+	return ceil{denominator, min, max}, nil
+}
+
+type ceil struct{ denominator, min, max int64 }
+
+func (a ceil) String() string { return fmt.Sprintf("ceil:%d:%d:%d", a.denominator, a.min, a.max) }
+
+func (a ceil) Adjust(parent Header, tab *big.Int) *big.Int {
+	var numerator int64
+	switch tab.Sign() {
+	case 0:
+		numerator = a.min // the ratio is infinite
+	case -1:
+		numerator = a.max // the ratio is negative
+	default:
+		// ⌈x/y⌉ = -⌊-x/y⌋, and Div floors for positive y.
+		ratio := new(big.Int).Neg(parent.TABS())
+		ratio.Div(ratio, tab).Neg(ratio)
+		n := new(big.Int).Sub(big.NewInt(1), ratio)
+		switch {
+		case n.Cmp(big.NewInt(a.max)) > 0:
+			numerator = a.max
+		case n.Cmp(big.NewInt(a.min)) < 0:
+			numerator = a.min
+		default:
+			numerator = n.Int64()
+		}
+	}
+	return scale(parent.TABS(), numerator, a.denominator)
+}
+
+// EMA is the exponential moving average of TAB, weighing each block by 2/(span+1).
+func EMA(span int64) (TABSAdjuster, error) {
+	if span <= 0 {
+		return nil, fmt.Errorf("ema: span must be positive")
+	}
+	return ema{span}, nil
+}
+
+type ema struct{ span int64 }
+
+func (a ema) String() string { return fmt.Sprintf("ema:%d", a.span) }
+
+func (a ema) Adjust(parent Header, tab *big.Int) *big.Int {
+	delta := new(big.Int).Sub(tab, parent.TABS())
+	delta.Mul(delta, big.NewInt(2)).Quo(delta, big.NewInt(a.span+1))
+	return delta.Add(delta, parent.TABS())
+}
+
+// Median is the median TAB of the block and its ancestors, window blocks in all
+// (or fewer, where the ancestry is shorter). An even count takes the mean of the middle two.
+func Median(window int64) (TABSAdjuster, error) {
+	if window <= 0 {
+		return nil, fmt.Errorf("median: window must be positive")
+	}
+	return median{window}, nil
+}
+
+type median struct{ window int64 }
+
+func (a median) String() string { return fmt.Sprintf("median:%d", a.window) }
+
+func (a median) Adjust(parent Header, tab *big.Int) *big.Int {
+	tabs := []*big.Int{tab}
+	for h := parent; h != nil && int64(len(tabs)) < a.window; h = h.Parent() {
+		if t := h.TAB(); t != nil {
+			tabs = append(tabs, t)
+		}
+	}
+	sort.Slice(tabs, func(i, j int) bool { return tabs[i].Cmp(tabs[j]) < 0 })
+
+	mid := len(tabs) / 2
+	if len(tabs)%2 == 1 {
+		return new(big.Int).Set(tabs[mid])
+	}
+	m := new(big.Int).Add(tabs[mid-1], tabs[mid])
+	return m.Quo(m, big.NewInt(2))
+}
+
+// Proportional is a proportional controller: TABS moves 1/gain of its distance to the TAB,
+// clamped to 1/limit of itself per block.
+func Proportional(gain, limit int64) (TABSAdjuster, error) {
+	if gain <= 0 || limit <= 0 {
+		return nil, fmt.Errorf("proportional: gain and limit must be positive")
+	}
+	return proportional{gain, limit}, nil
+}
+
+type proportional struct{ gain, limit int64 }
+
+func (a proportional) String() string { return fmt.Sprintf("proportional:%d:%d", a.gain, a.limit) }
+
+func (a proportional) Adjust(parent Header, tab *big.Int) *big.Int {
+	tabs := parent.TABS()
+	delta := new(big.Int).Sub(tab, tabs)
+	delta.Quo(delta, big.NewInt(a.gain))
+
+	bound := new(big.Int).Quo(tabs, big.NewInt(a.limit))
+	bound.Abs(bound)
+	if delta.CmpAbs(bound) > 0 {
+		delta.Set(bound)
+		if tab.Cmp(tabs) < 0 {
+			delta.Neg(delta)
+		}
+	}
+	return delta.Add(delta, tabs)
+}
+
+// scale returns tabs + tabs*numerator/denominator, the quotient truncated toward zero.
+func scale(tabs *big.Int, numerator, denominator int64) *big.Int {
+	delta := new(big.Int).Mul(tabs, big.NewInt(numerator))
+	delta.Quo(delta, big.NewInt(denominator))
+	return delta.Add(delta, tabs)
+}
@@ -0,0 +1,3 @@
+module github.com/whilei/go-tabs-adjust
+
+go 1.16
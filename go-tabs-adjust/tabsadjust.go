@@ -0,0 +1,133 @@
+// Package tabsadjust implements TABS adjustment algorithms:
+// how the TABS (total active balance synthesis) of a block follows from its parent's TABS and its own TAB (total active balance).
+//
+// The arithmetic is on integers (wei, or whatever the caller counts balances in),
+// so that the simulations, the experiments and the chain extrapolations get the same numbers from the same algorithm.
+package tabsadjust
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Header is what an algorithm knows about a block.
+type Header interface {
+	// TAB is the block's total active balance; nil if unknown (eg. genesis).
+	TAB() *big.Int
+	// TABS is the block's total active balance synthesis.
+	TABS() *big.Int
+	// Parent is the block's parent, or nil if there is none or it is unknown.
+	Parent() Header
+}
+
+// FallCounter is implemented by headers which keep their count of TABS falls (see Falls),
+// sparing a walk back through their ancestry.
+type FallCounter interface {
+	TABSFalls() int64
+}
+
+// TABSAdjuster is a TABS adjustment algorithm.
+type TABSAdjuster interface {
+	// String returns the algorithm's spec, which Parse reads back, eg. "sign:128".
+	String() string
+
+	// Adjust returns the TABS of a block with the TAB built on parent.
+	Adjust(parent Header, tab *big.Int) *big.Int
+}
+
+// Falls is how many blocks in sequence, a block with the TAB built on parent included, have had falling TABS:
+// a block's TABS falls when its TAB is below its parent's TABS.
+func Falls(parent Header, tab *big.Int) (n int64) {
+	for parent != nil && tab != nil && tab.Cmp(parent.TABS()) < 0 {
+		n++
+		if fc, ok := parent.(FallCounter); ok {
+			return n + fc.TABSFalls()
+		}
+		tab, parent = parent.TAB(), parent.Parent()
+	}
+	return n
+}
+
+// Block is a Header for callers without blocks of their own: a TAB and TABS linked to the parent.
+type Block struct {
+	tab, tabs *big.Int
+	parent    *Block
+}
+
+// Genesis returns a block with the TABS and no parent or TAB.
+func Genesis(tabs *big.Int) *Block {
+	return &Block{tabs: new(big.Int).Set(tabs)}
+}
+
+// Next returns the block with the TAB built on b, its TABS adjusted by the algorithm.
+func (b *Block) Next(a TABSAdjuster, tab *big.Int) *Block {
+	return &Block{tab: new(big.Int).Set(tab), tabs: a.Adjust(b, tab), parent: b}
+}
+
+func (b *Block) TAB() *big.Int  { return b.tab }
+func (b *Block) TABS() *big.Int { return b.tabs }
+
+func (b *Block) Parent() Header {
+	if b.parent == nil {
+		return nil // not a nil *Block
+	}
+	return b.parent
+}
+
+// Names lists the algorithms Parse knows. Their specs:
+//
+//	sign:denominator            ±1/denominator by the TAB's side of the parent TABS (go-miner-sim's getTABS)
+//	step:denominator:falls      sign, with the fall growing by 1/denominator every so many falls in a row (getTABS_step)
+//	ceil:denominator:min:max    (1 - ⌈TABS/TAB⌉)/denominator, the numerator clamped to [min, max]
+//	ema:span                    the exponential moving average of TAB over about span blocks
+//	median:window               the median TAB of the block and its ancestors in the window
+//	proportional:gain:limit     TABS moves 1/gain of the way to TAB, at most 1/limit of itself per block
+var Names = []string{"sign", "step", "ceil", "ema", "median", "proportional"}
+
+var defaults = map[string][]int64{
+	"sign":         {128},
+	"step":         {128, 9},
+	"ceil":         {2049, -2, 1},
+	"ema":          {128},
+	"median":       {15},
+	"proportional": {1024, 128},
+}
+
+// Parse returns the algorithm by its spec: a name and its parameters separated by colons, eg. "step:4096:9".
+// Missing trailing parameters take their defaults: sign:128, step:128:9, ceil:2049:-2:1, ema:128, median:15, proportional:1024:128.
+func Parse(spec string) (TABSAdjuster, error) {
+	fields := strings.Split(spec, ":")
+	name := fields[0]
+	def, ok := defaults[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown TABS adjustment: %q (want one of %s)", name, strings.Join(Names, ", "))
+	}
+	if len(fields)-1 > len(def) {
+		return nil, fmt.Errorf("TABS adjustment %q: too many parameters (want at most %d)", spec, len(def))
+	}
+	p := append([]int64{}, def...)
+	for i, f := range fields[1:] {
+		v, err := strconv.ParseInt(f, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("TABS adjustment %q: %w", spec, err)
+		}
+		p[i] = v
+	}
+
+	switch name {
+	case "sign":
+		return Sign(p[0])
+	case "step":
+		return Step(p[0], p[1])
+	case "ceil":
+		return Ceil(p[0], p[1], p[2])
+	case "ema":
+		return EMA(p[0])
+	case "median":
+		return Median(p[0])
+	default:
+		return Proportional(p[0], p[1])
+	}
+}
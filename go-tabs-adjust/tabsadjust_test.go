@@ -0,0 +1,185 @@
+package tabsadjust
+
+import (
+	"math"
+	"math/big"
+	"testing"
+)
+
+func mustParse(t *testing.T, spec string) TABSAdjuster {
+	t.Helper()
+	a, err := Parse(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+// chain builds blocks on a genesis with the TABS, one per TAB, returning the head.
+func chain(a TABSAdjuster, genesis int64, tabs ...int64) *Block {
+	b := Genesis(big.NewInt(genesis))
+	for _, tab := range tabs {
+		b = b.Next(a, big.NewInt(tab))
+	}
+	return b
+}
+
+func TestParse(t *testing.T) {
+	for spec, want := range map[string]string{
+		"sign":              "sign:128",
+		"sign:2049":         "sign:2049",
+		"step:4096":         "step:4096:9",
+		"ceil":              "ceil:2049:-2:1",
+		"ema:64":            "ema:64",
+		"median":            "median:15",
+		"proportional::256": "", // empty parameter
+		"proportional:2:3":  "proportional:2:3",
+		"sign:0":            "",
+		"ceil:2049:1:-2":    "",
+		"median:3:4":        "",
+		"tdtabs":            "",
+	} {
+		a, err := Parse(spec)
+		if want == "" {
+			if err == nil {
+				t.Errorf("%q: want error, got %v", spec, a)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", spec, err)
+			continue
+		}
+		if got := a.String(); got != want {
+			t.Errorf("%q: got %q, want %q", spec, got, want)
+		}
+		if b := mustParse(t, a.String()); b != a {
+			t.Errorf("%q: spec doesn't read back: %v", spec, b)
+		}
+	}
+}
+
+func TestSign(t *testing.T) {
+	a := mustParse(t, "sign:128")
+	for _, c := range []struct{ tabs, tab, want int64 }{
+		{10_000, 20_000, 10_078},
+		{10_000, 5_000, 9_922}, // 10000 - 10000/128, truncated toward zero
+		{10_000, 10_000, 10_000},
+	} {
+		if got := a.Adjust(Genesis(big.NewInt(c.tabs)), big.NewInt(c.tab)); got.Int64() != c.want {
+			t.Errorf("%d, %d: got %v, want %d", c.tabs, c.tab, got, c.want)
+		}
+	}
+
+	// extrapolate-tabs, from ETC block 14961856.
+	ether := new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)
+	tabs := new(big.Int).Mul(big.NewInt(7000), ether)
+	tab, _ := new(big.Int).SetString("6094560344849006393342", 10)
+	got := mustParse(t, "sign:2049").Adjust(Genesis(tabs), tab)
+	if got.String() != "6996583699365544167887" {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestStep(t *testing.T) {
+	a := mustParse(t, "step:128:9")
+
+	tabs := []int64{}
+	for i := 0; i < 20; i++ {
+		tabs = append(tabs, 1)
+	}
+	b := chain(a, 1_000_000, tabs...)
+	if got := Falls(b.parent, b.tab); got != 20 {
+		t.Fatalf("falls: got %d, want 20", got)
+	}
+
+	// Falls 1-8 fall by 1/128, 9-17 by 2/128, 18-20 by 3/128.
+	b = Genesis(big.NewInt(1_000_000))
+	for n := int64(1); n <= 20; n++ {
+		next := b.Next(a, big.NewInt(1))
+		numerator := -1 - n/9
+		if want := b.tabs.Int64() + b.tabs.Int64()*numerator/128; next.tabs.Int64() != want {
+			t.Errorf("fall %d: got %v, want %d", n, next.tabs, want)
+		}
+		b = next
+	}
+
+	// A rise resets the count.
+	b = b.Next(a, big.NewInt(math.MaxInt64))
+	if got := Falls(b, big.NewInt(1)); got != 1 {
+		t.Errorf("falls after rise: got %d, want 1", got)
+	}
+}
+
+type countedHeader struct {
+	*Block
+	falls int64
+}
+
+func (h countedHeader) TABSFalls() int64 { return h.falls }
+
+func TestFalls_Counter(t *testing.T) {
+	parent := countedHeader{Genesis(big.NewInt(100)), 41}
+	if got := Falls(parent, big.NewInt(99)); got != 42 {
+		t.Errorf("got %d, want 42", got)
+	}
+	if got := Falls(parent, big.NewInt(100)); got != 0 {
+		t.Errorf("got %d, want 0", got)
+	}
+}
+
+// TestCeil compares against the floating point original in extrapolate-tabs.
+func TestCeil(t *testing.T) {
+	a := mustParse(t, "ceil:2049:-2:1")
+	for _, tab := range []int64{1, 300_000, 499_999, 500_000, 999_999, 1_000_000, 1_000_001, 5_000_000} {
+		tabs := 1_000_000.0
+		ratio := math.Ceil(tabs / float64(tab))
+		numerator := math.Max(math.Min(1-ratio, 1), -2)
+		want := int64(tabs + math.Trunc(tabs*numerator/2049))
+
+		if got := a.Adjust(Genesis(big.NewInt(1_000_000)), big.NewInt(tab)); got.Int64() != want {
+			t.Errorf("tab %d: got %v, want %d", tab, got, want)
+		}
+	}
+	if got := a.Adjust(Genesis(big.NewInt(2049)), big.NewInt(0)); got.Int64() != 2047 {
+		t.Errorf("tab 0: got %v, want 2047", got)
+	}
+}
+
+func TestEMA(t *testing.T) {
+	a := mustParse(t, "ema:3") // weight 1/2
+	b := chain(a, 1000, 2000, 2000, 0)
+	if got := b.tabs.Int64(); got != 875 { // 1500, 1750, 875
+		t.Errorf("got %d, want 875", got)
+	}
+}
+
+func TestMedian(t *testing.T) {
+	a := mustParse(t, "median:3")
+	b := chain(a, 1000, 5)
+	if got := b.tabs.Int64(); got != 5 {
+		t.Errorf("one TAB: got %d, want 5", got) // genesis has no TAB
+	}
+	b = b.Next(a, big.NewInt(10))
+	if got := b.tabs.Int64(); got != 7 {
+		t.Errorf("two TABs: got %d, want 7", got)
+	}
+	b = b.Next(a, big.NewInt(100)).Next(a, big.NewInt(1))
+	if got := b.tabs.Int64(); got != 10 { // 10, 100, 1
+		t.Errorf("window: got %d, want 10", got)
+	}
+}
+
+func TestProportional(t *testing.T) {
+	a := mustParse(t, "proportional:4:100")
+	for _, c := range []struct{ tabs, tab, want int64 }{
+		{10_000, 10_200, 10_050},
+		{10_000, 20_000, 10_100}, // clamped
+		{10_000, 0, 9_900},       // clamped
+		{10_000, 9_999, 10_000},  // rounds to no change
+	} {
+		if got := a.Adjust(Genesis(big.NewInt(c.tabs)), big.NewInt(c.tab)); got.Int64() != c.want {
+			t.Errorf("%d, %d: got %v, want %d", c.tabs, c.tab, got, c.want)
+		}
+	}
+}